@@ -2,9 +2,12 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +31,8 @@ type userReturn struct {
 	Image     []byte    `json:"image"`
 	Status    []byte    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
+	// EmailVerified is false until the link mailed on sign up is opened.
+	EmailVerified bool `json:"email_verified"`
 }
 
 func newUserReturn(user db.User) userReturn {
@@ -43,6 +48,8 @@ func newUserReturn(user db.User) userReturn {
 		Image:     str,
 		Status:    ustr,
 		CreatedAt: user.CreatedAt,
+
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 }
 
@@ -53,6 +60,11 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
+	if err := util.ValidatePassword(req.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	hashedPw, err := util.HashPassword(req.Password)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -78,6 +90,8 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
+	server.sendVerificationAsync(user.ID, user.Email)
+
 	ret := newUserReturn(user)
 
 	ctx.JSON(http.StatusOK, ret)
@@ -94,6 +108,10 @@ type GetUserReturn struct {
 	Image     string    `json:"image"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
+	HideEmail bool      `json:"hide_email"`
+	Role      string    `json:"role"`
+
+	EmailVerified bool `json:"email_verified"`
 }
 
 func (server *Server) getUser(ctx *gin.Context) {
@@ -125,6 +143,10 @@ func (server *Server) getUser(ctx *gin.Context) {
 		Name:      user.Name,
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt,
+		HideEmail: user.HideEmail,
+		Role:      user.Role,
+
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 	nullStrs := map[string]NullString{
 		"Image":  NullString(user.Image),
@@ -149,14 +171,39 @@ type ListUserRequest struct {
 	PageSize int32 `form:"page_size" binding:"required,min=5,max=20"`
 }
 
+// ListUserAcc leaves Email empty for users who chose to hide it.
 type ListUserAcc struct {
 	ID     int64  `json:"id"`
 	Name   string `json:"name"`
-	Email  string `json:"email"`
+	Email  string `json:"email,omitempty"`
 	Image  string `json:"image"`
 	Status string `json:"status"`
 }
 
+func newListUserAcc(id int64, name, email string, image, status sql.NullString, hideEmail bool) ListUserAcc {
+	item := ListUserAcc{
+		ID:   id,
+		Name: name,
+	}
+	if !hideEmail {
+		item.Email = email
+	}
+	nullStrs := map[string]NullString{
+		"Image":  NullString(image),
+		"Status": NullString(status),
+	}
+	for key, nstring := range nullStrs {
+		str := nstring.NullStrToString()
+		switch key {
+		case "Image":
+			item.Image = str
+		case "Status":
+			item.Status = str
+		}
+	}
+	return item
+}
+
 func (server *Server) listUser(ctx *gin.Context) {
 	var req ListUserRequest
 
@@ -178,30 +225,60 @@ func (server *Server) listUser(ctx *gin.Context) {
 
 	var listRet []ListUserAcc
 	for _, user := range users {
-		item := ListUserAcc{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-		}
-		nullStrs := map[string]NullString{
-			"Image":  NullString(user.Image),
-			"Status": NullString(user.Status),
-		}
-		for key, nstring := range nullStrs {
-			str := nstring.NullStrToString()
-			switch key {
-			case "Image":
-				item.Image = str
-			case "Status":
-				item.Status = str
-			}
-		}
+		item := newListUserAcc(user.ID, user.Name, user.Email, user.Image, user.Status, user.HideEmail)
 		listRet = append(listRet, item)
 
 	}
 	ctx.JSON(http.StatusOK, listRet)
 }
 
+const defaultUserSearch = 10
+
+type searchUsersRequest struct {
+	Q     string `form:"q" binding:"required,max=100"`
+	Limit int32  `form:"limit" binding:"omitempty,min=1,max=25"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchUsers matches q against the start of names and emails, then falls
+// back to trigram similarity so typos still find people. Hidden emails are
+// neither searched nor returned.
+func (server *Server) searchUsers(ctx *gin.Context) {
+	var req searchUsersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	q := strings.TrimSpace(req.Q)
+	if q == "" {
+		err := errors.New("q cannot be blank")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultUserSearch
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	users, err := server.store.SearchUsers(ctx, db.SearchUsersParams{
+		UserID: auth.User,
+		Prefix: likeEscaper.Replace(q) + "%",
+		Query:  q,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := []ListUserAcc{}
+	for _, user := range users {
+		ret = append(ret, newListUserAcc(user.ID, user.Name, user.Email, user.Image, user.Status, user.HideEmail))
+	}
+	ctx.JSON(http.StatusOK, ret)
+}
+
 type ToBeNullString string
 
 func (s *ToBeNullString) Scan(value interface{}) sql.NullString {
@@ -223,14 +300,25 @@ func (s *ToBeNullString) ToNstring() sql.NullString {
 	return sql.NullString{String: string(*s), Valid: true}
 }
 
+// UpdateUserRequest.CurrentPassword is only needed to change the email, the
+// address password resets go to. Password is never accepted here, it is only
+// read to turn the request away.
 type UpdateUserRequest struct {
-	Name     ToBeNullString `json:"name"`
-	Email    ToBeNullString `json:"email"`
-	Image    ToBeNullString `json:"image"`
-	Status   ToBeNullString `json:"status"`
-	Password string         `json:"password"`
+	Name      ToBeNullString `json:"name"`
+	Email     ToBeNullString `json:"email"`
+	Image     ToBeNullString `json:"image"`
+	Status    ToBeNullString `json:"status"`
+	HideEmail *bool          `json:"hide_email"`
+
+	CurrentPassword string          `json:"current_password"`
+	Password        json.RawMessage `json:"password"`
 }
 
+var (
+	errPasswordNotHere         = errors.New("passwords are changed through PUT /account/password")
+	errCurrentPasswordRequired = errors.New("current_password is required to change the email")
+)
+
 type UpdateUserReturn struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
@@ -238,6 +326,9 @@ type UpdateUserReturn struct {
 	Image     string    `json:"image"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
+	HideEmail bool      `json:"hide_email"`
+
+	EmailVerified bool `json:"email_verified"`
 }
 
 func (server *Server) updateUser(g *gin.Context) {
@@ -248,37 +339,33 @@ func (server *Server) updateUser(g *gin.Context) {
 		return
 	}
 
+	if req.Password != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(errPasswordNotHere))
+		return
+	}
+
 	auth := g.MustGet(authPayloadKey).(*token.Payload)
 
-	var arg db.UpdateUserInfoParams
-	if len(req.Password) == 0 {
-		nPw := ToBeNullString(req.Password)
-		arg = db.UpdateUserInfoParams{
-			Name:     req.Name.Scan(req.Name),
-			Email:    req.Email.Scan(req.Email),
-			Image:    req.Image.Scan(req.Image),
-			Status:   req.Status.Scan(req.Status),
-			HashedPw: nPw.ToNstring(),
-			ID:       auth.User,
+	if len(req.Email) != 0 {
+		if req.CurrentPassword == "" {
+			g.JSON(http.StatusBadRequest, errorResponse(errCurrentPasswordRequired))
+			return
 		}
-	} else {
-
-		hashed, err := util.HashPassword(req.Password)
-		if err != nil {
-			g.JSON(http.StatusInternalServerError, errorResponse(err))
+		if !server.requirePassword(g, auth.User, req.CurrentPassword) {
 			return
 		}
+	}
 
-		nHash := ToBeNullString(hashed)
+	arg := db.UpdateUserInfoParams{
+		Name:   req.Name.Scan(req.Name),
+		Email:  req.Email.Scan(req.Email),
+		Image:  req.Image.Scan(req.Image),
+		Status: req.Status.Scan(req.Status),
+		ID:     auth.User,
+	}
 
-		arg = db.UpdateUserInfoParams{
-			Name:     req.Name.Scan(req.Name),
-			Email:    req.Email.Scan(req.Email),
-			Image:    req.Image.Scan(req.Image),
-			Status:   req.Status.Scan(req.Status),
-			HashedPw: nHash.Scan(nHash),
-			ID:       auth.User,
-		}
+	if req.HideEmail != nil {
+		arg.HideEmail = sql.NullBool{Bool: *req.HideEmail, Valid: true}
 	}
 
 	user, err := server.store.UpdateUserInfo(g, arg)
@@ -286,6 +373,12 @@ func (server *Server) updateUser(g *gin.Context) {
 		g.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+
+	// changing the address unverifies it until the new one is confirmed
+	if arg.Email.Valid && !user.EmailVerifiedAt.Valid {
+		server.sendVerificationAsync(user.ID, user.Email)
+	}
+
 	nullStrs := map[string]NullString{
 		"Image":  NullString(user.Image),
 		"Status": NullString(user.Status),
@@ -295,6 +388,9 @@ func (server *Server) updateUser(g *gin.Context) {
 		Name:      user.Name,
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt,
+		HideEmail: user.HideEmail,
+
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
 	for key, nstring := range nullStrs {
@@ -325,34 +421,92 @@ type loginUserResponse struct {
 	User                  userReturn `json:"user"`
 }
 
+var errBadCredentials = errors.New("invalid email or password")
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = util.HashPassword(util.RandomString(16))
+	})
+	return dummyHash
+}
+
 func (server *Server) loginUser(ctx *gin.Context) {
 	var req loginUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	account, ip := accountKey(req.Email), ctx.ClientIP()
+	wait := server.accountGuard.retryAfter(account)
+	if ipWait := server.ipGuard.retryAfter(ip); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		tooManyRequests(ctx, wait)
+		return
+	}
+
 	user, err := server.store.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
+	if err != nil && err != sql.ErrNoRows {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	err = util.CheckPassword(req.Password, user.HashedPw)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	// unknown emails still pay for a bcrypt comparison so neither the status
+	// nor the timing tells them apart from a wrong password
+	hashed := user.HashedPw
+	if err == sql.ErrNoRows {
+		hashed = dummyPasswordHash()
+	}
+	if pwErr := util.CheckPassword(req.Password, hashed); pwErr != nil || err == sql.ErrNoRows {
+		server.accountGuard.fail(account)
+		server.ipGuard.fail(ip)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errBadCredentials))
+		return
+	}
+	server.accountGuard.reset(account)
+
+	if !user.EmailVerifiedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
 		return
 	}
-	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.ID, server.config.AccessTokenDuration)
+
+	server.finishLogin(ctx, user)
+}
+
+// finishLogin logs in a user whose first factor checked out, or hands out a
+// challenge when they have two-factor on.
+func (server *Server) finishLogin(ctx *gin.Context, user db.User) {
+	totp, err := server.store.GetUserTOTP(ctx, user.ID)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		server.mfaChallenge(ctx, user.ID)
+		return
+	}
+
+	server.issueLogin(ctx, user)
+}
+
+// issueLogin starts a session for a user who has fully authenticated.
+func (server *Server) issueLogin(ctx *gin.Context, user db.User) {
+	scopes := token.ScopesForRole(user.Role)
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
+		user.ID, user.Role, scopes, server.config.AccessTokenDuration,
+	)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
-		user.ID, server.config.RefreshTokenDuration,
+		user.ID, user.Role, scopes, server.config.RefreshTokenDuration,
 	)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -368,6 +522,8 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		ClientIp:     ctx.ClientIP(),
 		IsBlocked:    false,
 		ExpiresAt:    refreshPayload.Expires,
+		CreatedAt:    refreshPayload.CreatedAt,
+		FamilyID:     refreshPayload.ID,
 	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -383,5 +539,4 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		User:                  newUserReturn(user),
 	}
 	ctx.JSON(http.StatusOK, res)
-
 }
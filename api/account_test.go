@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -142,6 +143,21 @@ func TestCreateUser(t *testing.T) {
 			checkRes: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
+		}, {
+			name: "weak password",
+			body: gin.H{
+				"name":     user.Name,
+				"email":    user.Email,
+				"password": "12345678",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
 		}, {
 			name: "invalid email",
 			body: gin.H{
@@ -394,6 +410,126 @@ func TestListUsers(t *testing.T) {
 
 }
 
+func TestSearchUsers(t *testing.T) {
+	user, _ := randomDBUser(t)
+
+	found := make([]db.SearchUsersRow, 3)
+	for i := range found {
+		found[i] = db.SearchUsersRow{
+			ID:     util.RandomInt(1, 1000),
+			Name:   util.RandomUserGen(),
+			Email:  util.RandomEmail(),
+			Image:  util.NullStrGen(10),
+			Status: util.NullStrGen(15),
+		}
+	}
+	found[2].HideEmail = true
+
+	testCases := []struct {
+		name       string
+		query      string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "q=jo&limit=3",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Eq(db.SearchUsersParams{
+						UserID: user.ID,
+						Prefix: "jo%",
+						Query:  "jo",
+						Limit:  3,
+					})).
+					Times(1).
+					Return(found, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				data, err := ioutil.ReadAll(recorder.Body)
+				require.NoError(t, err)
+				var got []ListUserAcc
+				require.NoError(t, json.Unmarshal(data, &got))
+				require.Len(t, got, 3)
+				require.Equal(t, found[0].Email, got[0].Email)
+				require.Equal(t, found[1].Name, got[1].Name)
+				require.Empty(t, got[2].Email)
+			},
+		}, {
+			name:  "Escapes Wildcards",
+			query: "q=" + url.QueryEscape(`50%_off\`),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Eq(db.SearchUsersParams{
+						UserID: user.ID,
+						Prefix: `50\%\_off\\%`,
+						Query:  `50%_off\`,
+						Limit:  defaultUserSearch,
+					})).
+					Times(1).
+					Return([]db.SearchUsersRow{}, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, "[]", recorder.Body.String())
+			},
+		}, {
+			name:  "Missing Query",
+			query: "limit=3",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		}, {
+			name:  "Limit Too High",
+			query: "q=jo&limit=100",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		}, {
+			name:  "Internal Server Err",
+			query: "q=jo",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/account/search?"+tc.query, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
 func requireUserBody(t *testing.T, res *bytes.Buffer, user db.GetUserRow) {
 	data, err := ioutil.ReadAll(res)
 	require.NoError(t, err)
@@ -423,7 +559,7 @@ func randomUser() db.GetUserRow {
 }
 
 func randomDBUser(t *testing.T) (user db.User, password string) {
-	password = util.RandomHashedPW()
+	password = util.RandomPassword()
 	hashed, err := util.HashPassword(password)
 	require.NoError(t, err)
 
@@ -438,6 +574,8 @@ func randomDBUser(t *testing.T) (user db.User, password string) {
 		Image:     sql.NullString{String: util.RandomString(10), Valid: true},
 		Status:    sql.NullString{String: util.RandomString(10), Valid: true},
 		CreatedAt: time,
+
+		EmailVerifiedAt: sql.NullTime{Time: time, Valid: true},
 	}
 	return
 }
@@ -477,36 +615,9 @@ func listMatch(t *testing.T, res *bytes.Buffer, list []db.ListUsersRow) {
 	}
 }
 
-type eqUpdateUserParamsMatcher struct {
-	arg      db.UpdateUserInfoParams
-	password string
-}
-
-func (e eqUpdateUserParamsMatcher) Matches(x interface{}) bool {
-	arg, ok := x.(db.UpdateUserInfoParams)
-
-	if !ok {
-		return false
-	}
-	err := util.CheckPassword(e.password, arg.HashedPw.String)
-	if err != nil {
-		return false
-	}
-	e.arg.HashedPw = arg.HashedPw
-	return reflect.DeepEqual(e.arg, arg)
-}
-
-func (e eqUpdateUserParamsMatcher) String() string {
-	return fmt.Sprintf("Matches arg %v and password %v", e.arg, e.password)
-}
-
-func EqUpdateParamsUser(arg db.UpdateUserInfoParams, password string) gomock.Matcher {
-	return eqUpdateUserParamsMatcher{arg, password}
-}
-
 func TestUpdateUser(t *testing.T) {
 
-	user, _ := randomDBUser(t)
+	user, password := randomDBUser(t)
 	now := time.Now()
 
 	name := ToBeNullString(util.RandomUserGen())
@@ -527,16 +638,20 @@ func TestUpdateUser(t *testing.T) {
 			name: "OK",
 			uId:  user.ID,
 			body: gin.H{
-				"name":     name,
-				"email":    email,
-				"image":    image,
-				"status":   status,
-				"password": newpw,
+				"name":             name,
+				"email":            email,
+				"image":            image,
+				"status":           status,
+				"current_password": password,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore, uId int64) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(uId)).
+					Times(1).
+					Return(user.HashedPw, nil)
 				arg := db.UpdateUserInfoParams{
 					Name:   name.ToNstring(),
 					Email:  email.ToNstring(),
@@ -546,7 +661,7 @@ func TestUpdateUser(t *testing.T) {
 				}
 
 				store.EXPECT().
-					UpdateUserInfo(gomock.Any(), EqUpdateParamsUser(arg, string(newpw))).
+					UpdateUserInfo(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
 					Return(db.UpdateUserInfoRow{
 						ID:        uId,
@@ -559,27 +674,74 @@ func TestUpdateUser(t *testing.T) {
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, req gin.H, uID int64) {
 				require.Equal(t, http.StatusAccepted, recorder.Code)
-				requireUserUpdateBody(t, recorder.Body, req, uID)
+				requireUserUpdateBody(t, recorder.Body, req, uID, now)
 			},
 		}, {
-			name: "No Pw Ok",
+			// passwords only change through PUT /account/password
+			name: "Password Rejected",
 			uId:  user.ID,
 			body: gin.H{
-				"name":   name,
-				"email":  email,
-				"image":  image,
-				"status": status,
+				"name":     name,
+				"password": newpw,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, uId int64) {
+				store.EXPECT().UpdateUserInfo(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, req gin.H, uID int64) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		}, {
+			name: "Email Without Password",
+			uId:  user.ID,
+			body: gin.H{
+				"email": email,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, uId int64) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateUserInfo(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, req gin.H, uID int64) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		}, {
+			name: "Email Wrong Password",
+			uId:  user.ID,
+			body: gin.H{
+				"email":            email,
+				"current_password": "wrong" + password,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, uId int64) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(uId)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().UpdateUserInfo(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, req gin.H, uID int64) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		}, {
+			name: "Hide Email",
+			uId:  user.ID,
+			body: gin.H{
+				"hide_email": true,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore, uId int64) {
 				arg := db.UpdateUserInfoParams{
-					Name:   name.ToNstring(),
-					Email:  email.ToNstring(),
-					Image:  image.ToNstring(),
-					Status: status.ToNstring(),
-					ID:     uId,
+					HideEmail: sql.NullBool{Bool: true, Valid: true},
+					ID:        uId,
 				}
 
 				store.EXPECT().
@@ -587,16 +749,20 @@ func TestUpdateUser(t *testing.T) {
 					Times(1).
 					Return(db.UpdateUserInfoRow{
 						ID:        uId,
-						Name:      string(name),
-						Email:     string(email),
-						Image:     image.ToNstring(),
-						Status:    status.ToNstring(),
+						Name:      user.Name,
+						Email:     user.Email,
 						CreatedAt: now,
+						HideEmail: true,
 					}, nil)
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, req gin.H, uID int64) {
 				require.Equal(t, http.StatusAccepted, recorder.Code)
-				requireUserUpdateBody(t, recorder.Body, req, uID)
+
+				data, err := ioutil.ReadAll(recorder.Body)
+				require.NoError(t, err)
+				var got UpdateUserReturn
+				require.NoError(t, json.Unmarshal(data, &got))
+				require.True(t, got.HideEmail)
 			},
 		}, {
 			name: "token error",
@@ -638,16 +804,20 @@ func TestUpdateUser(t *testing.T) {
 			name: "Internal Server Err",
 			uId:  user.ID,
 			body: gin.H{
-				"name":     name,
-				"email":    email,
-				"image":    image,
-				"status":   status,
-				"password": newpw,
+				"name":             name,
+				"email":            email,
+				"image":            image,
+				"status":           status,
+				"current_password": password,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore, uId int64) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(uId)).
+					Times(1).
+					Return(user.HashedPw, nil)
 				arg := db.UpdateUserInfoParams{
 					Name:   name.ToNstring(),
 					Email:  email.ToNstring(),
@@ -657,7 +827,7 @@ func TestUpdateUser(t *testing.T) {
 				}
 
 				store.EXPECT().
-					UpdateUserInfo(gomock.Any(), EqUpdateParamsUser(arg, string(newpw))).
+					UpdateUserInfo(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
 					Return(db.UpdateUserInfoRow{
 						ID:        uId,
@@ -701,10 +871,11 @@ func TestUpdateUser(t *testing.T) {
 
 }
 
-func requireUserUpdateBody(t *testing.T, res *bytes.Buffer, req gin.H, uID int64) {
+// requireUserUpdateBody compares against the stubbed createdAt, the request
+// checks a bcrypt hash and can take well over a second under -race.
+func requireUserUpdateBody(t *testing.T, res *bytes.Buffer, req gin.H, uID int64, createdAt time.Time) {
 	data, err := ioutil.ReadAll(res)
 	require.NoError(t, err)
-	now := time.Now()
 
 	var user UpdateUserReturn
 	err = json.Unmarshal(data, &user)
@@ -715,7 +886,7 @@ func requireUserUpdateBody(t *testing.T, res *bytes.Buffer, req gin.H, uID int64
 	require.Equal(t, string(req["status"].(ToBeNullString)), user.Status)
 	require.Equal(t, string(req["image"].(ToBeNullString)), user.Image)
 	require.Equal(t, uID, user.ID)
-	require.WithinDuration(t, now, user.CreatedAt, time.Second)
+	require.WithinDuration(t, createdAt, user.CreatedAt, time.Second)
 }
 
 func TestLoginUser(t *testing.T) {
@@ -738,6 +909,10 @@ func TestLoginUser(t *testing.T) {
 					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
 				store.EXPECT().
 					CreateSession(gomock.Any(), gomock.Any()).
 					Times(1)
@@ -746,6 +921,78 @@ func TestLoginUser(t *testing.T) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
+		{
+			desc: "Pending 2FA Ignored",
+			body: gin.H{
+				"email":    user.Email,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{UserID: user.ID, Secret: "PENDING"}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "access_token")
+			},
+		},
+		{
+			desc: "2FA Required",
+			body: gin.H{
+				"email":    user.Email,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{UserID: user.ID, ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got mfaChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.True(t, got.MFARequired)
+				require.NotEmpty(t, got.MFAToken)
+				require.NotContains(t, recorder.Body.String(), "access_token")
+			},
+		},
+		{
+			desc: "2FA Lookup Err",
+			body: gin.H{
+				"email":    user.Email,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
 		{
 			desc: "Bad Request",
 			body: gin.H{
@@ -776,7 +1023,9 @@ func TestLoginUser(t *testing.T) {
 
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				// indistinguishable from a wrong password
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errBadCredentials.Error())
 			},
 		},
 		{
@@ -811,6 +1060,29 @@ func TestLoginUser(t *testing.T) {
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errBadCredentials.Error())
+			},
+		},
+		{
+			desc: "Email Not Verified",
+			body: gin.H{
+				"email":    user.Email,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				unverified := user
+				unverified.EmailVerifiedAt = sql.NullTime{}
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(unverified, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errEmailNotVerified.Error())
 			},
 		},
 	}
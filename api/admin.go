@@ -0,0 +1,66 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+)
+
+type updateRoleUri struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type updateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type updateRoleReturn struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// updateUserRole promotes or demotes a user. Tokens carry the role they were
+// issued with, so the user's existing tokens and sessions are revoked and the
+// new role applies from their next login.
+func (server *Server) updateUserRole(ctx *gin.Context) {
+	var uri updateRoleUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req updateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.UpdateUserRoleTx(ctx, db.UpdateUserRoleParams{
+		ID:   uri.ID,
+		Role: req.Role,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.revoker.RevokeUser(ctx, user.ID, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, updateRoleReturn{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUserRole(t *testing.T) {
+	admin, _ := randomDBUser(t)
+	user, _ := randomDBUser(t)
+
+	addAdminAuth := func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+		addScopedAuth(t, request, tokenMaker, authTypeBearer, admin.ID, token.RoleAdmin, token.ScopesForRole(token.RoleAdmin), time.Minute)
+	}
+
+	testCases := []struct {
+		name       string
+		userID     int64
+		body       gin.H
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name:      "OK",
+		userID:    user.ID,
+		body:      gin.H{"role": token.RoleAdmin},
+		setupAuth: addAdminAuth,
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				UpdateUserRoleTx(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{ID: user.ID, Role: token.RoleAdmin})).
+				Times(1).
+				Return(db.UpdateUserRoleRow{ID: user.ID, Name: user.Name, Email: user.Email, Role: token.RoleAdmin}, nil)
+			store.EXPECT().
+				RevokeUserTokens(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ interface{}, arg db.RevokeUserTokensParams) error {
+					require.Equal(t, user.ID, arg.UserID)
+					return nil
+				})
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got updateRoleReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, user.ID, got.ID)
+			require.Equal(t, token.RoleAdmin, got.Role)
+		},
+	}, {
+		name:   "Not Admin",
+		userID: user.ID,
+		body:   gin.H{"role": token.RoleAdmin},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:      "Bad Role",
+		userID:    user.ID,
+		body:      gin.H{"role": "owner"},
+		setupAuth: addAdminAuth,
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:      "Not Found",
+		userID:    user.ID,
+		body:      gin.H{"role": token.RoleUser},
+		setupAuth: addAdminAuth,
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				UpdateUserRoleTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.UpdateUserRoleRow{}, sql.ErrNoRows)
+			store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:      "Int Server Err",
+		userID:    user.ID,
+		body:      gin.H{"role": token.RoleUser},
+		setupAuth: addAdminAuth,
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				UpdateUserRoleTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.UpdateUserRoleRow{}, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			url := fmt.Sprintf("/admin/account/%d/role", tc.userID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/rjriverac/messaging-server/db/sqlc"
@@ -27,16 +29,77 @@ func (s *NullString) NullStrToString() string {
 }
 
 type ConversationReturn struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Topic       string `json:"topic,omitempty"`
+	Description string `json:"description,omitempty"`
+	Kind        string `json:"kind,omitempty"`
 }
 
+func newConversationReturn(conv db.Conversation) ConversationReturn {
+	name := NullString(conv.Name)
+	topic := NullString(conv.Topic)
+	description := NullString(conv.Description)
+	return ConversationReturn{
+		ID:          conv.ID,
+		Name:        name.NullStrToString(),
+		Topic:       topic.NullStrToString(),
+		Description: description.NullStrToString(),
+		Kind:        conv.Kind,
+	}
+}
+
+type LastMessagePreview struct {
+	ID        int64     `json:"id"`
+	From      string    `json:"from"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ConvSummary is a conversation as it appears in the user's conversation
+// list. LastMessage is nil for conversations nobody has posted in yet, in
+// which case LastActivityAt is when the conversation was created.
+type ConvSummary struct {
+	ConversationReturn
+	UnreadCount       int64               `json:"unread_count"`
+	LastReadMessageID int64               `json:"last_read_message_id,omitempty"`
+	LastMessage       *LastMessagePreview `json:"last_message"`
+	LastActivityAt    time.Time           `json:"last_activity_at"`
+}
+
+func newConvSummary(row db.ListConvSummariesRow) ConvSummary {
+	ret := ConvSummary{
+		ConversationReturn: newConversationReturn(db.Conversation{
+			ID:          row.ID,
+			Name:        row.Name,
+			Topic:       row.Topic,
+			Description: row.Description,
+			Kind:        row.Kind,
+		}),
+		UnreadCount:       row.UnreadCount,
+		LastReadMessageID: row.LastReadMessageID.Int64,
+		LastActivityAt:    row.LastActivityAt,
+	}
+	if row.LastMessageID.Valid {
+		from := NullString(row.LastMessageFrom)
+		content := NullString(row.LastMessageContent)
+		ret.LastMessage = &LastMessagePreview{
+			ID:        row.LastMessageID.Int64,
+			From:      from.NullStrToString(),
+			Content:   content.NullStrToString(),
+			CreatedAt: row.LastMessageAt.Time,
+		}
+	}
+	return ret
+}
+
+// getConvos lists the user's conversations, most recently active first.
 func (server *Server) getConvos(g *gin.Context) {
-	var ret []ConversationReturn
+	var ret []ConvSummary
 
 	authPayload := g.MustGet(authPayloadKey).(*token.Payload)
 
-	convs, err := server.store.ListConvFromUser(context.Background(), authPayload.User)
+	convs, err := server.store.ListConvSummaries(context.Background(), authPayload.User)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			g.JSON(http.StatusNotFound, errorResponse(err))
@@ -46,9 +109,7 @@ func (server *Server) getConvos(g *gin.Context) {
 		return
 	}
 	for _, conv := range convs {
-		nstr := NullString(conv.Name)
-		str := nstr.NullStrToString()
-		ret = append(ret, ConversationReturn{ID: conv.ID, Name: str})
+		ret = append(ret, newConvSummary(conv))
 	}
 
 	g.JSON(http.StatusOK, ret)
@@ -59,20 +120,128 @@ type getConvDetailRequest struct {
 	ID int64 `uri:"id" binding:"required,min=1"`
 }
 
+const (
+	defaultMessagePage = 50
+	maxMessagePage     = 100
+)
+
+var errUnknownCursor = errors.New("cursor is not a message in this conversation")
+
+type convMessagesQuery struct {
+	Before int64 `form:"before" binding:"omitempty,min=1"`
+	After  int64 `form:"after" binding:"omitempty,min=1"`
+	Limit  int32 `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// ConvMessage carries the sender's current name and image rather than the
+// ones they had when the message was sent. SenderID is 0 when the sender's
+// account no longer exists.
+type ConvMessage struct {
+	SenderID    int64      `json:"senderID"`
+	From        string     `json:"from"`
+	SenderImage string     `json:"senderImage"`
+	Content     string     `json:"messageContent"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ID          int64      `json:"messageID"`
+	EditedAt    *time.Time `json:"editedAt"`
+	DeletedAt   *time.Time `json:"deletedAt"`
+	System      bool       `json:"system"`
+}
+
+// ConvMessagesReturn always lists messages oldest first. NextCursor is the
+// value to pass back as before= (or after= when paging forwards) to get the
+// following page, and is null once there is nothing left in that direction.
+type ConvMessagesReturn struct {
+	Messages   []ConvMessage `json:"messages"`
+	NextCursor *int64        `json:"next_cursor"`
+}
+
 func (server *Server) detailConvo(g *gin.Context) {
 	var req getConvDetailRequest
 	if err := g.ShouldBindUri(&req); err != nil {
 		g.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	var query convMessagesQuery
+	if err := g.ShouldBindQuery(&query); err != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if query.Before != 0 && query.After != 0 {
+		err := errors.New("before and after cannot be combined")
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultMessagePage
+	}
 	auth := g.MustGet(authPayloadKey).(*token.Payload)
+	// checked before the cursor so its conversation is not revealed
+	if _, ok := server.requireMember(g, auth.User, req.ID); !ok {
+		return
+	}
 
-	arg := db.ListConvMessagesParams{
-		ConvID: req.ID,
-		UserID: auth.User,
+	// a cursor from another conversation would silently page nothing
+	if cursor := query.Before + query.After; cursor != 0 {
+		msg, err := server.store.GetMessage(g, cursor)
+		if err != nil && err != sql.ErrNoRows {
+			g.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err == sql.ErrNoRows || msg.ConvID != req.ID {
+			g.JSON(http.StatusBadRequest, errorResponse(errUnknownCursor))
+			return
+		}
 	}
 
-	messages, err := server.store.ListConvMessages(context.Background(), arg)
+	// one extra row tells us whether another page exists
+	var messages []ConvMessage
+	var err error
+	if query.After != 0 {
+		var rows []db.ListConvMessagesAfterRow
+		rows, err = server.store.ListConvMessagesAfter(g, db.ListConvMessagesAfterParams{
+			ConvID: req.ID,
+			UserID: auth.User,
+			After:  query.After,
+			Limit:  query.Limit + 1,
+		})
+		for _, row := range rows {
+			image := NullString(row.SenderImage)
+			messages = append(messages, ConvMessage{
+				SenderID:    row.SenderID.Int64,
+				From:        row.SenderName,
+				SenderImage: image.NullStrToString(),
+				Content:     row.MessageContent,
+				CreatedAt:   row.CreatedAt,
+				ID:          row.MessageID,
+				EditedAt:    nullTime(row.EditedAt),
+				DeletedAt:   nullTime(row.DeletedAt),
+				System:      row.IsSystem,
+			})
+		}
+	} else {
+		var rows []db.ListConvMessagesBeforeRow
+		rows, err = server.store.ListConvMessagesBefore(g, db.ListConvMessagesBeforeParams{
+			ConvID: req.ID,
+			UserID: auth.User,
+			Before: sql.NullInt64{Int64: query.Before, Valid: query.Before != 0},
+			Limit:  query.Limit + 1,
+		})
+		for i := len(rows) - 1; i >= 0; i-- {
+			image := NullString(rows[i].SenderImage)
+			messages = append(messages, ConvMessage{
+				SenderID:    rows[i].SenderID.Int64,
+				From:        rows[i].SenderName,
+				SenderImage: image.NullStrToString(),
+				Content:     rows[i].MessageContent,
+				CreatedAt:   rows[i].CreatedAt,
+				ID:          rows[i].MessageID,
+				EditedAt:    nullTime(rows[i].EditedAt),
+				DeletedAt:   nullTime(rows[i].DeletedAt),
+				System:      rows[i].IsSystem,
+			})
+		}
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			g.JSON(http.StatusNotFound, errorResponse(err))
@@ -81,7 +250,23 @@ func (server *Server) detailConvo(g *gin.Context) {
 		g.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	g.JSON(http.StatusOK, messages)
+
+	ret := ConvMessagesReturn{Messages: []ConvMessage{}}
+	if len(messages) > int(query.Limit) {
+		var cursor int64
+		if query.After != 0 {
+			messages = messages[:query.Limit]
+			cursor = messages[len(messages)-1].ID
+		} else {
+			messages = messages[1:]
+			cursor = messages[0].ID
+		}
+		ret.NextCursor = &cursor
+	}
+	if messages != nil {
+		ret.Messages = messages
+	}
+	g.JSON(http.StatusOK, ret)
 }
 
 type createConvRequest struct {
@@ -117,7 +302,164 @@ func (server *Server) createConvo(g *gin.Context) {
 	ret := ConversationReturn{
 		ID:   conv.ID,
 		Name: conv.Name,
+		Kind: conv.Kind,
+	}
+	if !conv.Created {
+		// a direct conversation with this recipient already exists
+		g.JSON(http.StatusOK, ret)
+		return
 	}
+	server.publishToConv(g, conv.ID, Event{Type: EventMemberJoined, ConvID: conv.ID, Data: ret})
 
 	g.JSON(http.StatusAccepted, ret)
 }
+
+type openDMRequest struct {
+	UserID int64 `uri:"user_id" binding:"required,min=1"`
+}
+
+// openDM returns the caller's direct conversation with user_id, starting one
+// if they have never talked.
+func (server *Server) openDM(g *gin.Context) {
+	var req openDMRequest
+	if err := g.ShouldBindUri(&req); err != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := g.MustGet(authPayloadKey).(*token.Payload)
+	if req.UserID == auth.User {
+		err := errors.New("cannot open a direct conversation with yourself")
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if _, err := server.store.GetUser(g, req.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			g.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		g.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	conv, err := server.store.DirectConvTx(g, db.DirectConvParams{
+		UserID:  auth.User,
+		OtherID: req.UserID,
+	})
+	if err != nil {
+		g.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := ConversationReturn{
+		ID:   conv.ID,
+		Name: conv.Name,
+		Kind: conv.Kind,
+	}
+	if conv.Created {
+		server.publishToConv(g, conv.ID, Event{Type: EventMemberJoined, ConvID: conv.ID, Data: ret})
+	}
+	g.JSON(http.StatusOK, ret)
+}
+
+// requireOwner writes the error response itself, callers only need to
+// return when ok is false.
+func (server *Server) requireOwner(g *gin.Context, userID, convID int64) bool {
+	member, ok := server.requireMember(g, userID, convID)
+	if !ok {
+		return false
+	}
+	if member.Role != db.RoleOwner {
+		err := errors.New("only the conversation owner can do this")
+		g.JSON(http.StatusForbidden, errorResponse(err))
+		return false
+	}
+	return true
+}
+
+type updateConvRequest struct {
+	Name        ToBeNullString `json:"name"`
+	Topic       ToBeNullString `json:"topic"`
+	Description ToBeNullString `json:"description"`
+}
+
+func (server *Server) updateConvo(g *gin.Context) {
+	var uri getConvDetailRequest
+	if err := g.ShouldBindUri(&uri); err != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req updateConvRequest
+	if err := g.ShouldBindJSON(&req); err != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := g.MustGet(authPayloadKey).(*token.Payload)
+	if !server.requireOwner(g, auth.User, uri.ID) {
+		return
+	}
+
+	res, err := server.store.UpdateConvTx(g, db.UpdateConvParams{
+		ID:          uri.ID,
+		UserID:      auth.User,
+		Name:        req.Name.ToNstring(),
+		Topic:       req.Topic.ToNstring(),
+		Description: req.Description.ToNstring(),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			g.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		g.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := newConversationReturn(res.Conversation)
+	server.publishToConv(g, uri.ID, Event{Type: EventConvUpdated, ConvID: uri.ID, Data: ret})
+	if msg := res.SystemMessage; msg.ID != 0 {
+		server.publishToConv(g, uri.ID, Event{
+			Type:   EventMessage,
+			ID:     msg.ID,
+			ConvID: uri.ID,
+			Data: db.SendResult{
+				Timestamp: msg.CreatedAt,
+				MsgID:     msg.ID,
+				ConvID:    msg.ConvID,
+				SenderID:  msg.SenderID.Int64,
+				From:      msg.From,
+				Content:   msg.Content,
+				System:    true,
+			},
+		})
+	}
+	g.JSON(http.StatusOK, ret)
+}
+
+func (server *Server) deleteConvo(g *gin.Context) {
+	var uri getConvDetailRequest
+	if err := g.ShouldBindUri(&uri); err != nil {
+		g.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := g.MustGet(authPayloadKey).(*token.Payload)
+	if !server.requireOwner(g, auth.User, uri.ID) {
+		return
+	}
+
+	// read the members first, they are gone once the conversation is
+	members, err := server.store.ListConvMembers(g, uri.ID)
+	if err != nil {
+		g.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := server.store.DeleteConvTx(g, uri.ID); err != nil {
+		g.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.hub.Publish(members, Event{Type: EventConvDeleted, ConvID: uri.ID})
+	g.Status(http.StatusNoContent)
+}
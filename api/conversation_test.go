@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -24,15 +25,26 @@ func TestGetConvos(t *testing.T) {
 	user, _ := randomDBUser(t)
 
 	n := 5
-	convs := make([]db.Conversation, n)
+	convs := make([]db.ListConvSummariesRow, n)
 
 	for i := 0; i < n; i++ {
-		convs[i] = db.Conversation{
-			ID:   util.RandomInt(1, 1000),
-			Name: util.NullStrGen(6),
+		convs[i] = db.ListConvSummariesRow{
+			ID:                 util.RandomInt(1, 1000),
+			Name:               util.NullStrGen(6),
+			UnreadCount:        int64(i),
+			LastMessageID:      sql.NullInt64{Int64: util.RandomInt(1, 1000), Valid: true},
+			LastMessageContent: util.NullStrGen(20),
+			LastMessageFrom:    util.NullStrGen(6),
+			LastMessageAt:      sql.NullTime{Time: time.Now().Add(-time.Duration(i) * time.Minute), Valid: true},
+			LastActivityAt:     time.Now().Add(-time.Duration(i) * time.Minute),
 		}
 	}
 	convs[4].Name = sql.NullString{Valid: false}
+	// nobody has posted yet
+	convs[4].LastMessageID = sql.NullInt64{}
+	convs[4].LastMessageContent = sql.NullString{}
+	convs[4].LastMessageFrom = sql.NullString{}
+	convs[4].LastMessageAt = sql.NullTime{}
 
 	testCases := []struct {
 		name       string
@@ -44,7 +56,7 @@ func TestGetConvos(t *testing.T) {
 			name: "OK",
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListConvFromUser(gomock.Any(), gomock.Eq(user.ID)).
+					ListConvSummaries(gomock.Any(), gomock.Eq(user.ID)).
 					Times(1).
 					Return(convs, nil)
 			},
@@ -53,13 +65,26 @@ func TestGetConvos(t *testing.T) {
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+
+				data, err := ioutil.ReadAll(recorder.Body)
+				require.NoError(t, err)
+				var got []ConvSummary
+				require.NoError(t, json.Unmarshal(data, &got))
+				require.Len(t, got, n)
+				for i, conv := range got {
+					require.Equal(t, convs[i].ID, conv.ID)
+					require.Equal(t, convs[i].UnreadCount, conv.UnreadCount)
+					require.WithinDuration(t, convs[i].LastActivityAt, conv.LastActivityAt, time.Second)
+				}
+				require.Equal(t, convs[0].LastMessageContent.String, got[0].LastMessage.Content)
+				require.Nil(t, got[4].LastMessage)
 			},
 		},
 		{
 			name: "Not Found",
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListConvFromUser(gomock.Any(), gomock.Any()).
+					ListConvSummaries(gomock.Any(), gomock.Any()).
 					Times(1).
 					Return(convs, sql.ErrNoRows)
 			},
@@ -74,7 +99,7 @@ func TestGetConvos(t *testing.T) {
 			name: "Internal Server Error",
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					ListConvFromUser(gomock.Any(), gomock.Any()).
+					ListConvSummaries(gomock.Any(), gomock.Any()).
 					Times(1).
 					Return(convs, sql.ErrConnDone)
 			},
@@ -115,24 +140,38 @@ func TestConvDetail(t *testing.T) {
 
 	user, _ := randomDBUser(t)
 	conv := db.Conversation{
-		ID:   util.RandomInt(0, 1000),
+		ID:   util.RandomInt(1, 1000),
 		Name: util.NullStrGen(10),
 	}
+	member := membership(user.ID, conv.ID, db.RoleMember)
 	n := 20
 
-	messages := make([]db.ListConvMessagesRow, n)
+	// newest first, the way ListConvMessagesBefore returns them
+	before := make([]db.ListConvMessagesBeforeRow, n)
 	for i := 0; i < n; i++ {
-		messages[i] = db.ListConvMessagesRow{
-			From:           user.Name,
+		before[i] = db.ListConvMessagesBeforeRow{
+			SenderID:       sql.NullInt64{Int64: user.ID, Valid: true},
+			SenderName:     user.Name,
+			MessageContent: util.RandomString(10),
+			CreatedAt:      time.Now().Add(-time.Duration(i) * time.Minute),
+			MessageID:      int64(1000 - i),
+		}
+	}
+	after := make([]db.ListConvMessagesAfterRow, 6)
+	for i := range after {
+		after[i] = db.ListConvMessagesAfterRow{
+			SenderID:       sql.NullInt64{Int64: user.ID, Valid: true},
+			SenderName:     user.Name,
 			MessageContent: util.RandomString(10),
-			CreatedAt:      time.Now(),
-			MessageID:      util.RandomInt(1, 5000),
+			CreatedAt:      time.Now().Add(time.Duration(i) * time.Minute),
+			MessageID:      int64(11 + i),
 		}
 	}
 
 	testCases := []struct {
 		desc       string
 		convID     int64
+		query      string
 		buildStubs func(store *mockdb.MockStore)
 		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
@@ -141,21 +180,163 @@ func TestConvDetail(t *testing.T) {
 			desc:   "OK",
 			convID: conv.ID,
 			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				arg := db.ListConvMessagesBeforeParams{
+					ConvID: conv.ID,
+					UserID: user.ID,
+					Limit:  defaultMessagePage + 1,
+				}
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(before, nil)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				res := requireMessagesBody(t, recorder.Body)
+				require.Len(t, res.Messages, n)
+				require.Nil(t, res.NextCursor)
+				require.Equal(t, before[n-1].MessageID, res.Messages[0].ID)
+				require.Equal(t, before[0].MessageID, res.Messages[n-1].ID)
+			},
+		},
+		{
+			desc:   "OK Before With More",
+			convID: conv.ID,
+			query:  "?before=1001&limit=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				store.EXPECT().
+					GetMessage(gomock.Any(), gomock.Eq(int64(1001))).
+					Times(1).
+					Return(db.Message{ID: 1001, ConvID: conv.ID}, nil)
+				arg := db.ListConvMessagesBeforeParams{
+					ConvID: conv.ID,
+					UserID: user.ID,
+					Before: sql.NullInt64{Int64: 1001, Valid: true},
+					Limit:  6,
+				}
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(before[:6], nil)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
 
-				arg := db.ListConvMessagesParams{
+				res := requireMessagesBody(t, recorder.Body)
+				require.Len(t, res.Messages, 5)
+				require.NotNil(t, res.NextCursor)
+				require.Equal(t, before[4].MessageID, *res.NextCursor)
+				require.Equal(t, before[4].MessageID, res.Messages[0].ID)
+				require.Equal(t, before[0].MessageID, res.Messages[4].ID)
+			},
+		},
+		{
+			desc:   "OK After With More",
+			convID: conv.ID,
+			query:  "?after=10&limit=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				store.EXPECT().
+					GetMessage(gomock.Any(), gomock.Eq(int64(10))).
+					Times(1).
+					Return(db.Message{ID: 10, ConvID: conv.ID}, nil)
+				arg := db.ListConvMessagesAfterParams{
 					ConvID: conv.ID,
 					UserID: user.ID,
+					After:  10,
+					Limit:  6,
 				}
 				store.EXPECT().
-					ListConvMessages(gomock.Any(), gomock.Eq(arg)).
+					ListConvMessagesAfter(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(messages, nil)
+					Return(after, nil)
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
 			},
 			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+
+				res := requireMessagesBody(t, recorder.Body)
+				require.Len(t, res.Messages, 5)
+				require.NotNil(t, res.NextCursor)
+				require.Equal(t, after[4].MessageID, *res.NextCursor)
+				require.Equal(t, after[0].MessageID, res.Messages[0].ID)
+			},
+		},
+		{
+			desc:   "Unknown Cursor",
+			convID: conv.ID,
+			query:  "?before=5000",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				store.EXPECT().
+					GetMessage(gomock.Any(), gomock.Eq(int64(5000))).
+					Times(1).
+					Return(db.Message{}, sql.ErrNoRows)
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errUnknownCursor.Error())
+			},
+		},
+		{
+			desc:   "Cursor From Another Conversation",
+			convID: conv.ID,
+			query:  "?after=10",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				store.EXPECT().
+					GetMessage(gomock.Any(), gomock.Eq(int64(10))).
+					Times(1).
+					Return(db.Message{ID: 10, ConvID: conv.ID + 1}, nil)
+				store.EXPECT().
+					ListConvMessagesAfter(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc:   "Not Member",
+			convID: conv.ID,
+			query:  "?before=1001",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserConversation{}, sql.ErrNoRows)
+				store.EXPECT().GetMessage(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListConvMessagesBefore(gomock.Any(), gomock.Any()).Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), db.ErrNotMember.Error())
 			},
 		},
 		{
@@ -164,7 +345,7 @@ func TestConvDetail(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 
 				store.EXPECT().
-					ListConvMessages(gomock.Any(), gomock.Any()).
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
@@ -175,17 +356,50 @@ func TestConvDetail(t *testing.T) {
 			},
 		},
 		{
-			desc:   "NoRows",
+			desc:   "Bad Request Before And After",
 			convID: conv.ID,
+			query:  "?before=20&after=10",
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.ListConvMessagesParams{
-					ConvID: conv.ID,
-					UserID: user.ID,
-				}
 
 				store.EXPECT().
-					ListConvMessages(gomock.Any(), gomock.Eq(arg)).
-					Times(1).Return([]db.ListConvMessagesRow{}, sql.ErrNoRows)
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					ListConvMessagesAfter(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc:   "Bad Request Limit",
+			convID: conv.ID,
+			query:  "?limit=1000",
+			buildStubs: func(store *mockdb.MockStore) {
+
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc:   "NoRows",
+			convID: conv.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, member)
+				store.EXPECT().
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(1).Return([]db.ListConvMessagesBeforeRow{}, sql.ErrNoRows)
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
@@ -198,14 +412,10 @@ func TestConvDetail(t *testing.T) {
 			desc:   "Internal Server Err",
 			convID: conv.ID,
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.ListConvMessagesParams{
-					ConvID: conv.ID,
-					UserID: user.ID,
-				}
-
+				expectMember(store, member)
 				store.EXPECT().
-					ListConvMessages(gomock.Any(), gomock.Eq(arg)).
-					Times(1).Return([]db.ListConvMessagesRow{}, sql.ErrConnDone)
+					ListConvMessagesBefore(gomock.Any(), gomock.Any()).
+					Times(1).Return([]db.ListConvMessagesBeforeRow{}, sql.ErrConnDone)
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
@@ -224,7 +434,7 @@ func TestConvDetail(t *testing.T) {
 			tC.buildStubs(store)
 
 			server := newTestServer(t, store)
-			url := fmt.Sprintf("/conversation/%v", tC.convID)
+			url := fmt.Sprintf("/conversation/%v%s", tC.convID, tC.query)
 
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
@@ -240,6 +450,16 @@ func TestConvDetail(t *testing.T) {
 	}
 }
 
+func requireMessagesBody(t *testing.T, body *bytes.Buffer) ConvMessagesReturn {
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+
+	var res ConvMessagesReturn
+	err = json.Unmarshal(data, &res)
+	require.NoError(t, err)
+	return res
+}
+
 func TestCreateConvTxApi(t *testing.T) {
 
 	sender, _ := randomDBUser(t)
@@ -275,10 +495,15 @@ func TestCreateConvTxApi(t *testing.T) {
 					ToUsers: toUsers,
 					From:    sender.ID,
 				}
+				convID := util.RandomInt(1, 1000)
 				store.EXPECT().
 					CreateConvTx(gomock.Any(), arg).
 					Times(1).
-					Return(db.ConvReturn{Name: arg.Name.String, ID: util.RandomInt(1, 1000)}, nil)
+					Return(db.ConvReturn{Name: arg.Name.String, ID: convID, Kind: db.KindGroup, Created: true}, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return([]int64{sender.ID}, nil)
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuth(t, request, tokenMaker, authTypeBearer, sender.ID, time.Minute)
@@ -287,6 +512,34 @@ func TestCreateConvTxApi(t *testing.T) {
 				require.Equal(t, http.StatusAccepted, recorder.Code)
 			},
 		},
+		{
+			desc: "Existing Direct",
+			body: gin.H{
+				"conv_name":        name,
+				"recipient_emails": toUsers[:1],
+				"from":             sender.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateConvParams{
+					Name:    convName,
+					ToUsers: toUsers[:1],
+					From:    sender.ID,
+				}
+				store.EXPECT().
+					CreateConvTx(gomock.Any(), arg).
+					Times(1).
+					Return(db.ConvReturn{ID: util.RandomInt(1, 1000), Kind: db.KindDirect}, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, sender.ID, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
 		{
 			desc: "IntServerErr",
 			body: gin.H{
@@ -401,3 +654,333 @@ func TestCreateConvTxApi(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateConvo(t *testing.T) {
+	user, _ := randomDBUser(t)
+	conv := db.Conversation{
+		ID:    util.RandomInt(1, 1000),
+		Name:  util.NullStrGen(8),
+		Topic: util.NullStrGen(12),
+	}
+	renamed := util.RandomString(8)
+
+	testCases := []struct {
+		desc       string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{"name": renamed},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, conv.ID, db.RoleOwner))
+				updated := conv
+				updated.Name = sql.NullString{String: renamed, Valid: true}
+				store.EXPECT().
+					UpdateConvTx(gomock.Any(), gomock.Eq(db.UpdateConvParams{
+						ID:     conv.ID,
+						UserID: user.ID,
+						Name:   updated.Name,
+					})).
+					Times(1).
+					Return(db.UpdateConvResult{
+						Conversation: updated,
+						SystemMessage: db.Message{
+							ID:       util.RandomInt(1, 1000),
+							ConvID:   conv.ID,
+							Content:  renamed,
+							IsSystem: true,
+						},
+					}, nil)
+				// once for the update itself, once for the system message
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(conv.ID)).
+					Times(2).
+					Return([]int64{user.ID}, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				data, err := ioutil.ReadAll(recorder.Body)
+				require.NoError(t, err)
+				var got ConversationReturn
+				require.NoError(t, json.Unmarshal(data, &got))
+				require.Equal(t, renamed, got.Name)
+				require.Equal(t, conv.Topic.String, got.Topic)
+			},
+		},
+		{
+			desc: "Not Owner",
+			body: gin.H{"name": renamed},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, conv.ID, db.RoleAdmin))
+				store.EXPECT().UpdateConvTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc: "Empty Request",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc: "Not Found",
+			body: gin.H{"topic": util.RandomString(5)},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, conv.ID, db.RoleOwner))
+				store.EXPECT().
+					UpdateConvTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateConvResult{}, sql.ErrNoRows)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			desc: "IntServerErr",
+			body: gin.H{"description": util.RandomString(5)},
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, conv.ID, db.RoleOwner))
+				store.EXPECT().
+					UpdateConvTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateConvResult{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+			url := fmt.Sprintf("/conversation/%d", conv.ID)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(body))
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestDeleteConvo(t *testing.T) {
+	user, _ := randomDBUser(t)
+	other, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	testCases := []struct {
+		desc       string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, convID, db.RoleOwner))
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return([]int64{user.ID, other.ID}, nil)
+				store.EXPECT().
+					DeleteConvTx(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return(nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			desc: "Not Owner",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, convID, db.RoleMember))
+				store.EXPECT().DeleteConvTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc: "Not Member",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserConversation{}, sql.ErrNoRows)
+				store.EXPECT().DeleteConvTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc: "IntServerErr",
+			buildStubs: func(store *mockdb.MockStore) {
+				expectMember(store, membership(user.ID, convID, db.RoleOwner))
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return([]int64{user.ID}, nil)
+				store.EXPECT().
+					DeleteConvTx(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/conversation/%d", convID)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestOpenDM(t *testing.T) {
+	user, _ := randomDBUser(t)
+	other, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+	arg := db.DirectConvParams{UserID: user.ID, OtherID: other.ID}
+
+	testCases := []struct {
+		desc       string
+		otherID    int64
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:    "OK Created",
+			otherID: other.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(other.ID)).Times(1).Return(db.GetUserRow{ID: other.ID}, nil)
+				store.EXPECT().
+					DirectConvTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ConvReturn{ID: convID, Kind: db.KindDirect, Created: true}, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+					Times(1).
+					Return([]int64{user.ID, other.ID}, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				data, err := ioutil.ReadAll(recorder.Body)
+				require.NoError(t, err)
+				var got ConversationReturn
+				require.NoError(t, json.Unmarshal(data, &got))
+				require.Equal(t, ConversationReturn{ID: convID, Kind: db.KindDirect}, got)
+			},
+		},
+		{
+			desc:    "OK Existing",
+			otherID: other.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(other.ID)).Times(1).Return(db.GetUserRow{ID: other.ID}, nil)
+				store.EXPECT().
+					DirectConvTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ConvReturn{ID: convID, Kind: db.KindDirect}, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc:    "Self",
+			otherID: user.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DirectConvTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc:    "User Not Found",
+			otherID: other.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(other.ID)).Times(1).Return(db.GetUserRow{}, sql.ErrNoRows)
+				store.EXPECT().DirectConvTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			desc:    "IntServerErr",
+			otherID: other.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(other.ID)).Times(1).Return(db.GetUserRow{ID: other.ID}, nil)
+				store.EXPECT().
+					DirectConvTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ConvReturn{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/dm/%d", tC.otherID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkRes(t, recorder)
+		})
+	}
+}
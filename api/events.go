@@ -0,0 +1,184 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	sseKeepAlive      = 15 * time.Second
+	// sseReplayLimit caps how many missed messages a reconnect replays
+	sseReplayLimit = 100
+)
+
+type convEventsRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// requireMember writes the error response itself, callers only need to
+// return when ok is false.
+func (server *Server) requireMember(ctx *gin.Context, userID, convID int64) (db.UserConversation, bool) {
+	member, err := server.store.GetUser_conversation(ctx, db.GetUser_conversationParams{
+		UserID: userID,
+		ConvID: convID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusForbidden, errorResponse(db.ErrNotMember))
+			return member, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return member, false
+	}
+	return member, true
+}
+
+func parseLastEventID(ctx *gin.Context) (int64, error) {
+	raw := ctx.GetHeader(lastEventIDHeader)
+	if len(raw) == 0 {
+		raw = ctx.Query("last_event_id")
+	}
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid Last-Event-ID")
+	}
+	return id, nil
+}
+
+func renderEvent(w io.Writer, event Event) error {
+	sseEvent := sse.Event{
+		Event: event.Type,
+		Data:  event,
+	}
+	if event.ID != 0 {
+		sseEvent.Id = strconv.FormatInt(event.ID, 10)
+	}
+	return sse.Encode(w, sseEvent)
+}
+
+func (server *Server) streamConvEvents(ctx *gin.Context) {
+	var req convEventsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	lastID, err := parseLastEventID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if _, ok := server.requireMember(ctx, auth.User, req.ID); !ok {
+		return
+	}
+
+	// subscribe before reading the backlog so nothing falls between the two
+	sub := server.hub.subscribe(auth.User, req.ID)
+	defer server.hub.unsubscribe(sub)
+
+	var missed []db.ListConvMessagesSinceRow
+	if lastID > 0 {
+		missed, err = server.store.ListConvMessagesSince(ctx, db.ListConvMessagesSinceParams{
+			ConvID: req.ID,
+			ID:     lastID,
+			Limit:  sseReplayLimit + 1,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Writer.WriteHeader(http.StatusOK)
+
+	sentUpTo := lastID
+	// too far behind to replay, the client pages through the history instead
+	if len(missed) > sseReplayLimit {
+		renderEvent(ctx.Writer, Event{
+			Type:   EventResync,
+			ConvID: req.ID,
+			Data:   gin.H{"last_event_id": lastID},
+		})
+		missed = nil
+	}
+	for _, msg := range missed {
+		image := NullString(msg.SenderImage)
+		renderEvent(ctx.Writer, Event{
+			Type:   EventMessage,
+			ID:     msg.ID,
+			ConvID: msg.ConvID,
+			Data: db.SendResult{
+				Timestamp:   msg.CreatedAt,
+				MsgID:       msg.ID,
+				ConvID:      msg.ConvID,
+				SenderID:    msg.SenderID.Int64,
+				From:        msg.SenderName,
+				SenderImage: image.NullStrToString(),
+				Content:     msg.Content,
+				System:      msg.IsSystem,
+			},
+		})
+		sentUpTo = msg.ID
+	}
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.send:
+			if !ok {
+				return false
+			}
+			// already delivered as part of the backlog
+			if event.Type == EventMessage && event.ID <= sentUpTo {
+				return true
+			}
+			return renderEvent(w, event) == nil
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (server *Server) sendTyping(ctx *gin.Context) {
+	var req convEventsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if _, ok := server.requireMember(ctx, auth.User, req.ID); !ok {
+		return
+	}
+
+	server.publishToConv(ctx, req.ID, Event{
+		Type:   EventTyping,
+		ConvID: req.ID,
+		Data:   gin.H{"user_id": auth.User},
+	})
+	ctx.Status(http.StatusNoContent)
+}
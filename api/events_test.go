@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+type sseFrame struct {
+	id    string
+	event string
+	data  string
+}
+
+// readSSE collects n frames from an event stream, skipping comments.
+func readSSE(t *testing.T, scanner *bufio.Scanner, n int) []sseFrame {
+	var frames []sseFrame
+	var cur sseFrame
+	for len(frames) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if cur.event != "" {
+				frames = append(frames, cur)
+			}
+			cur = sseFrame{}
+		case strings.HasPrefix(line, "id:"):
+			cur.id = strings.TrimPrefix(line, "id:")
+		case strings.HasPrefix(line, "event:"):
+			cur.event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			cur.data = strings.TrimPrefix(line, "data:")
+		}
+	}
+	require.Len(t, frames, n)
+	return frames
+}
+
+func TestStreamConvEvents(t *testing.T) {
+	user, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	missed := make([]db.ListConvMessagesSinceRow, 3)
+	for i := range missed {
+		missed[i] = db.ListConvMessagesSinceRow{
+			ID:         int64(11 + i),
+			SenderID:   sql.NullInt64{Int64: user.ID, Valid: true},
+			SenderName: user.Name,
+			Content:    util.RandomString(10),
+			CreatedAt:  time.Now(),
+			ConvID:     convID,
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		convID      int64
+		lastEventID string
+		setupAuth   func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs  func(store *mockdb.MockStore)
+		checkRes    func(t *testing.T, res *http.Response)
+	}{
+		{
+			name:        "Resume",
+			convID:      convID,
+			lastEventID: "10",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Eq(db.GetUser_conversationParams{UserID: user.ID, ConvID: convID})).
+					Times(1).
+					Return(db.UserConversation{UserID: user.ID, ConvID: convID}, nil)
+				store.EXPECT().
+					ListConvMessagesSince(gomock.Any(), gomock.Eq(db.ListConvMessagesSinceParams{ConvID: convID, ID: 10, Limit: sseReplayLimit + 1})).
+					Times(1).
+					Return(missed, nil)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+				frames := readSSE(t, bufio.NewScanner(res.Body), len(missed))
+				for i, frame := range frames {
+					require.Equal(t, EventMessage, frame.event)
+					require.Equal(t, fmt.Sprint(missed[i].ID), frame.id)
+					require.Contains(t, frame.data, missed[i].Content)
+					require.Contains(t, frame.data, fmt.Sprintf(`"senderID":%d`, user.ID))
+				}
+			},
+		},
+		{
+			name:        "Too Far Behind",
+			convID:      convID,
+			lastEventID: "1",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserConversation{UserID: user.ID, ConvID: convID}, nil)
+				backlog := make([]db.ListConvMessagesSinceRow, sseReplayLimit+1)
+				for i := range backlog {
+					backlog[i] = db.ListConvMessagesSinceRow{ID: int64(2 + i), ConvID: convID}
+				}
+				store.EXPECT().
+					ListConvMessagesSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(backlog, nil)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusOK, res.StatusCode)
+
+				frames := readSSE(t, bufio.NewScanner(res.Body), 1)
+				require.Equal(t, EventResync, frames[0].event)
+				require.Contains(t, frames[0].data, `"last_event_id":1`)
+			},
+		},
+		{
+			name:   "Not Member",
+			convID: convID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserConversation{}, sql.ErrNoRows)
+				store.EXPECT().
+					ListConvMessagesSince(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusForbidden, res.StatusCode)
+			},
+		},
+		{
+			name:        "Bad Last-Event-ID",
+			convID:      convID,
+			lastEventID: "abc",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusBadRequest, res.StatusCode)
+			},
+		},
+		{
+			name:   "Internal Server Err",
+			convID: convID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserConversation{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+			},
+		},
+		{
+			name:   "No Auth",
+			convID: convID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser_conversation(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkRes: func(t *testing.T, res *http.Response) {
+				require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			ts := httptest.NewServer(server.router)
+			defer ts.Close()
+
+			reqCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			url := fmt.Sprintf("%s/conversation/%d/events", ts.URL, tc.convID)
+			request, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+			require.NoError(t, err)
+			if tc.lastEventID != "" {
+				request.Header.Set(lastEventIDHeader, tc.lastEventID)
+			}
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			res, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			tc.checkRes(t, res)
+		})
+	}
+}
+
+func TestStreamConvEventsLive(t *testing.T) {
+	user, _ := randomDBUser(t)
+	other, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		GetUser_conversation(gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(db.UserConversation{ConvID: convID}, nil)
+	store.EXPECT().
+		ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+		Times(1).
+		Return([]int64{user.ID, other.ID}, nil)
+
+	server := newTestServer(t, store)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	reqCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/conversation/%d/events", ts.URL, convID)
+	request, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+	res, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// events for other conversations must not leak into this stream
+	server.hub.Publish([]int64{user.ID}, Event{Type: EventTyping, ConvID: convID + 1})
+
+	typing, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/conversation/%d/typing", convID), nil)
+	require.NoError(t, err)
+	addAuth(t, typing, server.tokenMaker, authTypeBearer, other.ID, time.Minute)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, typing)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	frames := readSSE(t, bufio.NewScanner(res.Body), 1)
+	require.Equal(t, EventTyping, frames[0].event)
+	require.Empty(t, frames[0].id)
+	require.Contains(t, frames[0].data, fmt.Sprintf(`"conv_id":%d`, convID))
+	require.Contains(t, frames[0].data, fmt.Sprintf(`"user_id":%d`, other.ID))
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+const (
+	EventMessage        = "message"
+	EventMessageEdited  = "message_edited"
+	EventMessageDeleted = "message_deleted"
+	EventMemberJoined   = "member_joined"
+	EventMemberLeft     = "member_left"
+	EventTyping         = "typing"
+	EventConvUpdated    = "conversation_updated"
+	EventConvDeleted    = "conversation_deleted"
+	EventRead           = "read"
+	// EventResync tells a reconnecting client it missed more than the
+	// stream replays and should reload the history through detailConvo.
+	EventResync = "resync"
+
+	subscriberBuffer = 64
+)
+
+// Event is pushed to real-time clients. ID is only set for message events,
+// where it is the message id and doubles as the SSE resume cursor.
+type Event struct {
+	Type   string      `json:"type"`
+	ID     int64       `json:"id,omitempty"`
+	ConvID int64       `json:"conv_id"`
+	Data   interface{} `json:"data"`
+}
+
+// subscriber receives events for every conversation its user belongs to,
+// or only for convID when it is non-zero.
+type subscriber struct {
+	userID int64
+	convID int64
+	send   chan Event
+}
+
+type delivery struct {
+	users []int64
+	event Event
+}
+
+// Hub fans events out to every live connection of the users they are
+// addressed to. All subscriber bookkeeping happens on the Run goroutine.
+type Hub struct {
+	subscribers map[int64]map[*subscriber]bool
+	register    chan *subscriber
+	unregister  chan *subscriber
+	broadcast   chan delivery
+	quit        chan struct{}
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[int64]map[*subscriber]bool),
+		register:    make(chan *subscriber),
+		unregister:  make(chan *subscriber),
+		broadcast:   make(chan delivery, subscriberBuffer),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+func (h *Hub) Run() {
+	defer close(h.done)
+	for {
+		select {
+		case sub := <-h.register:
+			if h.subscribers[sub.userID] == nil {
+				h.subscribers[sub.userID] = make(map[*subscriber]bool)
+			}
+			h.subscribers[sub.userID][sub] = true
+		case sub := <-h.unregister:
+			h.remove(sub)
+		case d := <-h.broadcast:
+			for _, user := range d.users {
+				for sub := range h.subscribers[user] {
+					if sub.convID != 0 && sub.convID != d.event.ConvID {
+						continue
+					}
+					select {
+					case sub.send <- d.event:
+					default:
+						// slow consumer, drop it rather than stall everyone else
+						h.remove(sub)
+					}
+				}
+			}
+		case <-h.quit:
+			for _, subs := range h.subscribers {
+				for sub := range subs {
+					close(sub.send)
+				}
+			}
+			h.subscribers = make(map[int64]map[*subscriber]bool)
+			return
+		}
+	}
+}
+
+func (h *Hub) remove(sub *subscriber) {
+	subs, ok := h.subscribers[sub.userID]
+	if !ok || !subs[sub] {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.userID)
+	}
+	close(sub.send)
+}
+
+// subscribe always returns a subscriber; if the hub is already stopped its
+// send channel is closed straight away so the caller shuts down cleanly.
+func (h *Hub) subscribe(userID, convID int64) *subscriber {
+	sub := &subscriber{
+		userID: userID,
+		convID: convID,
+		send:   make(chan Event, subscriberBuffer),
+	}
+	select {
+	case h.register <- sub:
+	case <-h.done:
+		close(sub.send)
+	}
+	return sub
+}
+
+func (h *Hub) unsubscribe(sub *subscriber) {
+	select {
+	case h.unregister <- sub:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) Publish(users []int64, event Event) {
+	select {
+	case h.broadcast <- delivery{users: users, event: event}:
+	case <-h.done:
+	}
+}
+
+// Stop closes every subscriber and waits for the hub loop to exit.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() { close(h.quit) })
+	<-h.done
+}
+
+func (server *Server) publishToConv(ctx context.Context, convID int64, event Event) {
+	members, err := server.store.ListConvMembers(ctx, convID)
+	if err != nil {
+		log.Printf("cannot publish %s event to conversation %d: %v", event.Type, convID, err)
+		return
+	}
+	server.hub.Publish(members, event)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+)
+
+const (
+	janitorInterval = time.Hour
+	janitorTimeout  = time.Minute
+)
+
+// janitorJob deletes rows that stopped mattering once they expired. run is
+// usually a method expression such as db.Store.DeleteExpiredRevokedTokens.
+type janitorJob struct {
+	name string
+	run  func(store db.Store, ctx context.Context) error
+}
+
+// janitor sweeps expired rows on a timer so no request has to pay for a scan
+// of a whole table.
+type janitor struct {
+	store    db.Store
+	jobs     []janitorJob
+	interval time.Duration
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+func newJanitor(store db.Store, interval time.Duration, jobs ...janitorJob) *janitor {
+	return &janitor{
+		store:    store,
+		jobs:     jobs,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+func (j *janitor) Run() {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.sweep()
+		case <-j.quit:
+			return
+		}
+	}
+}
+
+// sweep runs every job once. A failing job is retried on the next tick and
+// does not hold up the others.
+func (j *janitor) sweep() {
+	for _, job := range j.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), janitorTimeout)
+		if err := job.run(j.store, ctx); err != nil {
+			log.Printf("cannot delete expired %s: %v", job.name, err)
+		}
+		cancel()
+	}
+}
+
+func (j *janitor) Stop() {
+	j.stopOnce.Do(func() { close(j.quit) })
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor(t *testing.T) {
+	failed := make(chan struct{}, 1)
+	swept := make(chan struct{}, 1)
+	j := newJanitor(nil, time.Millisecond,
+		janitorJob{"failing", func(db.Store, context.Context) error {
+			select {
+			case failed <- struct{}{}:
+			default:
+			}
+			return sql.ErrConnDone
+		}},
+		janitorJob{"working", func(db.Store, context.Context) error {
+			select {
+			case swept <- struct{}{}:
+			default:
+			}
+			return nil
+		}},
+	)
+	go j.Run()
+	defer j.Stop()
+
+	// one job failing does not stop the next from running
+	for _, ran := range []chan struct{}{failed, swept} {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			require.FailNow(t, "janitor job did not run")
+		}
+	}
+}
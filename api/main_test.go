@@ -1,12 +1,16 @@
 package api
 
 import (
+	"io"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
 	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
 	"github.com/rjriverac/messaging-server/util"
 	"github.com/stretchr/testify/require"
 )
@@ -21,7 +25,13 @@ func newTestServer(t *testing.T, store db.Store) *Server {
 		TokenSymmetricKey:   util.RandomString(32),
 		AccessTokenDuration: time.Minute,
 	}
+	// tokens are never revoked unless a test revokes them itself
+	if mock, ok := store.(*mockdb.MockStore); ok {
+		mock.EXPECT().IsTokenRevoked(gomock.Any(), gomock.Any()).AnyTimes().Return(false, nil)
+	}
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
+	// tests that read mail swap in their own mailer
+	server.mailer = mail.NewLogMailer(io.Discard, "")
 	return server
 }
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+var roleRank = map[string]int{
+	db.RoleMember: 1,
+	db.RoleAdmin:  2,
+	db.RoleOwner:  3,
+}
+
+// canManage reports whether actor may add or remove someone holding role.
+// Admins manage plain members, owners manage everyone else.
+func canManage(actor, role string) bool {
+	return roleRank[actor] >= roleRank[db.RoleAdmin] && roleRank[actor] > roleRank[role]
+}
+
+type MemberReturn struct {
+	UserID int64  `json:"user_id"`
+	ConvID int64  `json:"conv_id"`
+	Role   string `json:"role"`
+}
+
+func newMemberReturn(member db.UserConversation) MemberReturn {
+	return MemberReturn{
+		UserID: member.UserID,
+		ConvID: member.ConvID,
+		Role:   member.Role,
+	}
+}
+
+type addMemberRequest struct {
+	UserID int64  `json:"user_id" binding:"required,min=1"`
+	Role   string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+func (server *Server) addMember(ctx *gin.Context) {
+	var uri convEventsRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req addMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Role == "" {
+		req.Role = db.RoleMember
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	actor, ok := server.requireMember(ctx, auth.User, uri.ID)
+	if !ok {
+		return
+	}
+	if !canManage(actor.Role, req.Role) {
+		err := errors.New("insufficient role to add this member")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	conv, err := server.store.GetConversation(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if conv.Kind == db.KindDirect {
+		err := errors.New("direct conversations cannot have more members")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	if _, err := server.store.GetUser(ctx, req.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	member, err := server.store.CreateConvMember(ctx, db.CreateConvMemberParams{
+		UserID: req.UserID,
+		ConvID: uri.ID,
+		Role:   req.Role,
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "unique_violation":
+				ctx.JSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := newMemberReturn(member)
+	server.publishToConv(ctx, uri.ID, Event{Type: EventMemberJoined, ConvID: uri.ID, Data: ret})
+	ctx.JSON(http.StatusAccepted, ret)
+}
+
+type removeMemberRequest struct {
+	ID     int64 `uri:"id" binding:"required,min=1"`
+	UserID int64 `uri:"user_id" binding:"required,min=1"`
+}
+
+func (server *Server) removeMember(ctx *gin.Context) {
+	var uri removeMemberRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if uri.UserID == auth.User {
+		err := errors.New("use leave to remove yourself")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	actor, ok := server.requireMember(ctx, auth.User, uri.ID)
+	if !ok {
+		return
+	}
+
+	target, err := server.store.GetUser_conversation(ctx, db.GetUser_conversationParams{
+		UserID: uri.UserID,
+		ConvID: uri.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !canManage(actor.Role, target.Role) {
+		err := errors.New("insufficient role to remove this member")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	err = server.store.DeleteUser_conversation(ctx, db.DeleteUser_conversationParams{
+		UserID: uri.UserID,
+		ConvID: uri.ID,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := newMemberReturn(target)
+	event := Event{Type: EventMemberLeft, ConvID: uri.ID, Data: ret}
+	server.publishToConv(ctx, uri.ID, event)
+	// no longer a member, so publishToConv will not reach them
+	server.hub.Publish([]int64{uri.UserID}, event)
+	ctx.JSON(http.StatusOK, ret)
+}
+
+type leaveConvReturn struct {
+	MemberReturn
+	NewOwner int64 `json:"new_owner,omitempty"`
+}
+
+func (server *Server) leaveConvo(ctx *gin.Context) {
+	var uri convEventsRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	res, err := server.store.LeaveConvTx(ctx, db.LeaveConvParams{
+		UserID: auth.User,
+		ConvID: uri.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusForbidden, errorResponse(db.ErrNotMember))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := leaveConvReturn{
+		MemberReturn: newMemberReturn(res.Left),
+		NewOwner:     res.NewOwner.UserID,
+	}
+	server.publishToConv(ctx, uri.ID, Event{Type: EventMemberLeft, ConvID: uri.ID, Data: ret})
+	ctx.JSON(http.StatusOK, ret)
+}
+
+type markReadRequest struct {
+	MessageID int64 `json:"message_id" binding:"omitempty,min=1"`
+}
+
+type readReturn struct {
+	UserID            int64 `json:"user_id"`
+	ConvID            int64 `json:"conv_id"`
+	LastReadMessageID int64 `json:"last_read_message_id"`
+}
+
+// markConvRead moves the caller's read marker up to message_id, or to the
+// newest message when the body is empty. The marker never moves backwards.
+func (server *Server) markConvRead(ctx *gin.Context) {
+	var uri convEventsRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req markReadRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	member, err := server.store.MarkConvRead(ctx, db.MarkConvReadParams{
+		ConvID:    uri.ID,
+		MessageID: sql.NullInt64{Int64: req.MessageID, Valid: req.MessageID != 0},
+		UserID:    auth.User,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusForbidden, errorResponse(db.ErrNotMember))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := readReturn{
+		UserID:            member.UserID,
+		ConvID:            member.ConvID,
+		LastReadMessageID: member.LastReadMessageID.Int64,
+	}
+	server.publishToConv(ctx, uri.ID, Event{Type: EventRead, ConvID: uri.ID, Data: ret})
+	ctx.JSON(http.StatusOK, ret)
+}
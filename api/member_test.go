@@ -0,0 +1,545 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func membership(userID, convID int64, role string) db.UserConversation {
+	return db.UserConversation{
+		ID:     util.RandomInt(1, 1000),
+		UserID: userID,
+		ConvID: convID,
+		Role:   role,
+	}
+}
+
+func expectMember(store *mockdb.MockStore, member db.UserConversation) {
+	store.EXPECT().
+		GetUser_conversation(gomock.Any(), gomock.Eq(db.GetUser_conversationParams{UserID: member.UserID, ConvID: member.ConvID})).
+		Times(1).
+		Return(member, nil)
+}
+
+func TestAddMember(t *testing.T) {
+	user, _ := randomDBUser(t)
+	added, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	testCases := []struct {
+		name       string
+		arg        gin.H
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		arg:  gin.H{"user_id": added.ID, "role": db.RoleAdmin},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{ID: convID, Kind: db.KindGroup}, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(added.ID)).Times(1).Return(db.GetUserRow{ID: added.ID}, nil)
+			store.EXPECT().
+				CreateConvMember(gomock.Any(), gomock.Eq(db.CreateConvMemberParams{UserID: added.ID, ConvID: convID, Role: db.RoleAdmin})).
+				Times(1).
+				Return(membership(added.ID, convID, db.RoleAdmin), nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{user.ID, added.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusAccepted, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got MemberReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, MemberReturn{UserID: added.ID, ConvID: convID, Role: db.RoleAdmin}, got)
+		},
+	}, {
+		name: "Default Role",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleAdmin))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{ID: convID, Kind: db.KindGroup}, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(added.ID)).Times(1).Return(db.GetUserRow{ID: added.ID}, nil)
+			store.EXPECT().
+				CreateConvMember(gomock.Any(), gomock.Eq(db.CreateConvMemberParams{UserID: added.ID, ConvID: convID, Role: db.RoleMember})).
+				Times(1).
+				Return(membership(added.ID, convID, db.RoleMember), nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{user.ID, added.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusAccepted, recorder.Code)
+		},
+	}, {
+		name: "Admin Cannot Add Admin",
+		arg:  gin.H{"user_id": added.ID, "role": db.RoleAdmin},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleAdmin))
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Member Cannot Add",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleMember))
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Not Member",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetUser_conversation(gomock.Any(), gomock.Any()).Times(1).Return(db.UserConversation{}, sql.ErrNoRows)
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "User Not Found",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{ID: convID, Kind: db.KindGroup}, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(added.ID)).Times(1).Return(db.GetUserRow{}, sql.ErrNoRows)
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Already Member",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{ID: convID, Kind: db.KindGroup}, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(added.ID)).Times(1).Return(db.GetUserRow{ID: added.ID}, nil)
+			store.EXPECT().
+				CreateConvMember(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.UserConversation{}, &pq.Error{Code: "23505"})
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Conversation Not Found",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{}, sql.ErrNoRows)
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Direct Conversation",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().GetConversation(gomock.Any(), gomock.Eq(convID)).Times(1).Return(db.Conversation{ID: convID, Kind: db.KindDirect}, nil)
+			store.EXPECT().CreateConvMember(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Bad Role",
+		arg:  gin.H{"user_id": added.ID, "role": db.RoleOwner},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name: "No Auth",
+		arg:  gin.H{"user_id": added.ID},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/conversation/%d/members", convID)
+			marshalled, err := json.Marshal(tc.arg)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(marshalled))
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	user, _ := randomDBUser(t)
+	removed, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	testCases := []struct {
+		name       string
+		userID     int64
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name:   "OK",
+		userID: removed.ID,
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleAdmin))
+			expectMember(store, membership(removed.ID, convID, db.RoleMember))
+			store.EXPECT().
+				DeleteUser_conversation(gomock.Any(), gomock.Eq(db.DeleteUser_conversationParams{UserID: removed.ID, ConvID: convID})).
+				Times(1).
+				Return(nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+		},
+	}, {
+		name:   "Admin Cannot Remove Admin",
+		userID: removed.ID,
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleAdmin))
+			expectMember(store, membership(removed.ID, convID, db.RoleAdmin))
+			store.EXPECT().DeleteUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:   "Target Not Member",
+		userID: removed.ID,
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			store.EXPECT().
+				GetUser_conversation(gomock.Any(), gomock.Eq(db.GetUser_conversationParams{UserID: removed.ID, ConvID: convID})).
+				Times(1).
+				Return(db.UserConversation{}, sql.ErrNoRows)
+			store.EXPECT().DeleteUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:   "Remove Self",
+		userID: user.ID,
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetUser_conversation(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:   "Int Server Err",
+		userID: removed.ID,
+		buildStubs: func(store *mockdb.MockStore) {
+			expectMember(store, membership(user.ID, convID, db.RoleOwner))
+			expectMember(store, membership(removed.ID, convID, db.RoleAdmin))
+			store.EXPECT().
+				DeleteUser_conversation(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/conversation/%d/members/%d", convID, tc.userID)
+
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestLeaveConvo(t *testing.T) {
+	user, _ := randomDBUser(t)
+	heir, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				LeaveConvTx(gomock.Any(), gomock.Eq(db.LeaveConvParams{UserID: user.ID, ConvID: convID})).
+				Times(1).
+				Return(db.LeaveConvResult{
+					Left:     membership(user.ID, convID, db.RoleOwner),
+					NewOwner: membership(heir.ID, convID, db.RoleOwner),
+				}, nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{heir.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got leaveConvReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, user.ID, got.UserID)
+			require.Equal(t, heir.ID, got.NewOwner)
+		},
+	}, {
+		name: "Not Member",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				LeaveConvTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.LeaveConvResult{}, sql.ErrNoRows)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Int Server Err",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				LeaveConvTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.LeaveConvResult{}, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/conversation/%d/leave", convID)
+
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestMarkConvRead(t *testing.T) {
+	user, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+	msgID := util.RandomInt(1, 1000)
+
+	read := membership(user.ID, convID, db.RoleMember)
+	read.LastReadMessageID = sql.NullInt64{Int64: msgID, Valid: true}
+
+	testCases := []struct {
+		name       string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK Latest",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				MarkConvRead(gomock.Any(), gomock.Eq(db.MarkConvReadParams{ConvID: convID, UserID: user.ID})).
+				Times(1).
+				Return(read, nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got readReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, readReturn{UserID: user.ID, ConvID: convID, LastReadMessageID: msgID}, got)
+		},
+	}, {
+		name: "OK Message",
+		body: gin.H{"message_id": msgID},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				MarkConvRead(gomock.Any(), gomock.Eq(db.MarkConvReadParams{
+					ConvID:    convID,
+					MessageID: sql.NullInt64{Int64: msgID, Valid: true},
+					UserID:    user.ID,
+				})).
+				Times(1).
+				Return(read, nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(convID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+		},
+	}, {
+		name: "Bad Request",
+		body: gin.H{"message_id": -1},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				MarkConvRead(gomock.Any(), gomock.Any()).
+				Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name: "Not Member",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				MarkConvRead(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.UserConversation{}, sql.ErrNoRows)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name: "Int Server Err",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				MarkConvRead(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.UserConversation{}, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/conversation/%d/read", convID)
+
+			var body io.Reader
+			if tc.body != nil {
+				data, err := json.Marshal(tc.body)
+				require.NoError(t, err)
+				body = bytes.NewReader(data)
+			}
+			request, err := http.NewRequest(http.MethodPost, url, body)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/rjriverac/messaging-server/db/sqlc"
@@ -30,8 +33,158 @@ func (s *Server) sendMessage(ctx *gin.Context) {
 	}
 	sent, err := s.store.SendMessage(ctx, arg)
 	if err != nil {
+		if errors.Is(err, db.ErrNotMember) {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	s.publishToConv(ctx, sent.ConvID, Event{Type: EventMessage, ID: sent.MsgID, ConvID: sent.ConvID, Data: sent})
 	ctx.JSON(http.StatusAccepted, sent)
 }
+
+type messageIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type editMessageRequest struct {
+	Content string `json:"content" binding:"required,min=1"`
+}
+
+// MessageReturn carries the sender's current name and image, the same way
+// ConvMessage does.
+type MessageReturn struct {
+	ID          int64      `json:"id"`
+	ConvID      int64      `json:"convID"`
+	SenderID    int64      `json:"senderID"`
+	From        string     `json:"from"`
+	SenderImage string     `json:"senderImage"`
+	Content     string     `json:"content"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	EditedAt    *time.Time `json:"editedAt"`
+	DeletedAt   *time.Time `json:"deletedAt"`
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if t.Valid {
+		return &t.Time
+	}
+	return nil
+}
+
+func newMessageReturn(msg db.Message, sender db.GetUserRow) MessageReturn {
+	image := NullString(sender.Image)
+	return MessageReturn{
+		ID:          msg.ID,
+		ConvID:      msg.ConvID,
+		SenderID:    msg.SenderID.Int64,
+		From:        sender.Name,
+		SenderImage: image.NullStrToString(),
+		Content:     msg.Content,
+		CreatedAt:   msg.CreatedAt,
+		EditedAt:    nullTime(msg.EditedAt),
+		DeletedAt:   nullTime(msg.DeletedAt),
+	}
+}
+
+var errSystemMessage = errors.New("system messages cannot be changed")
+
+// requireAuthor writes the error response itself, callers only need to
+// return when ok is false.
+func (s *Server) requireAuthor(ctx *gin.Context, msgID, userID int64) (db.Message, bool) {
+	msg, err := s.store.GetMessage(ctx, msgID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return msg, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return msg, false
+	}
+	if msg.DeletedAt.Valid {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return msg, false
+	}
+	// system messages carry the sender that caused them but belong to nobody
+	if msg.IsSystem {
+		ctx.JSON(http.StatusForbidden, errorResponse(errSystemMessage))
+		return msg, false
+	}
+	if !msg.SenderID.Valid || msg.SenderID.Int64 != userID {
+		err := errors.New("only the author can change this message")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return msg, false
+	}
+	return msg, true
+}
+
+func (s *Server) editMessage(ctx *gin.Context) {
+	var uri messageIDRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req editMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if _, ok := s.requireAuthor(ctx, uri.ID, auth.User); !ok {
+		return
+	}
+	// only the author gets this far, so the sender is the caller
+	sender, err := s.store.GetUser(ctx, auth.User)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	msg, err := s.store.EditMessageTx(ctx, db.EditMessageParams{
+		ID:      uri.ID,
+		Content: req.Content,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ret := newMessageReturn(msg, sender)
+	s.publishToConv(ctx, msg.ConvID, Event{Type: EventMessageEdited, ConvID: msg.ConvID, Data: ret})
+	ctx.JSON(http.StatusOK, ret)
+}
+
+func (s *Server) deleteMessage(ctx *gin.Context) {
+	var uri messageIDRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if _, ok := s.requireAuthor(ctx, uri.ID, auth.User); !ok {
+		return
+	}
+	// only the author gets this far, so the sender is the caller
+	sender, err := s.store.GetUser(ctx, auth.User)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	msg, err := s.store.DeleteMessageTx(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ret := newMessageReturn(msg, sender)
+	s.publishToConv(ctx, msg.ConvID, Event{Type: EventMessageDeleted, ConvID: msg.ConvID, Data: ret})
+	ctx.JSON(http.StatusOK, ret)
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -48,7 +50,10 @@ func TestSendMessage(t *testing.T) {
 				})).
 				Times(1).
 				Return(result, nil)
-
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(result.ConvID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
 		},
 		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 			require.Equal(t, http.StatusAccepted, recorder.Code)
@@ -70,6 +75,27 @@ func TestSendMessage(t *testing.T) {
 		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 			require.Equal(t, http.StatusBadRequest, recorder.Code)
 		},
+	}, {
+		name: "Not Member",
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		arg: gin.H{
+			"content": msgParams.Content,
+			"convID":  msgParams.ConvID,
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				SendMessage(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.SendResult{}, db.ErrNotMember)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Any()).
+				Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
 	}, {
 		name: "Int Server Err",
 		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
@@ -136,5 +162,340 @@ func randomSendResult() db.SendResult {
 	return db.SendResult{
 		Timestamp: now,
 		MsgID:     util.RandomInt(0, 1000),
+		ConvID:    util.RandomInt(1, 1000),
+		SenderID:  util.RandomInt(1, 1000),
+		From:      util.RandomUserGen(),
+		Content:   util.RandomString(10),
+	}
+}
+
+func randomMessage(sender db.User) db.Message {
+	return db.Message{
+		ID:        util.RandomInt(1, 1000),
+		From:      sender.Name,
+		SenderID:  sql.NullInt64{Int64: sender.ID, Valid: true},
+		Content:   util.RandomString(10),
+		CreatedAt: time.Now(),
+		ConvID:    util.RandomInt(1, 1000),
+	}
+}
+
+func requireMessageBody(t *testing.T, body *bytes.Buffer, msg db.Message, sender db.GetUserRow) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var got MessageReturn
+	err = json.Unmarshal(data, &got)
+	require.NoError(t, err)
+	require.Equal(t, msg.ID, got.ID)
+	require.Equal(t, msg.SenderID.Int64, got.SenderID)
+	require.Equal(t, msg.Content, got.Content)
+	require.Equal(t, msg.EditedAt.Valid, got.EditedAt != nil)
+	require.Equal(t, msg.DeletedAt.Valid, got.DeletedAt != nil)
+	require.Equal(t, sender.Name, got.From)
+	require.Equal(t, sender.Image.String, got.SenderImage)
+}
+
+// senderRow is the user as GetUser returns them, renamed since msg was sent
+func senderRow(user db.User) db.GetUserRow {
+	return db.GetUserRow{
+		ID:    user.ID,
+		Name:  util.RandomUserGen(),
+		Email: user.Email,
+		Image: util.NullStrGen(12),
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	user, _ := randomDBUser(t)
+	msg := randomMessage(user)
+	sender := senderRow(user)
+	content := util.RandomString(12)
+
+	edited := msg
+	edited.Content = content
+	edited.EditedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	deleted := msg
+	deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	// the rename notice records who renamed, which is still not an author
+	system := msg
+	system.IsSystem = true
+
+	testCases := []struct {
+		name       string
+		msgID      int64
+		arg        gin.H
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name:  "OK",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(msg, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(sender, nil)
+			store.EXPECT().
+				EditMessageTx(gomock.Any(), gomock.Eq(db.EditMessageParams{ID: msg.ID, Content: content})).
+				Times(1).
+				Return(edited, nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(msg.ConvID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+			requireMessageBody(t, recorder.Body, edited, sender)
+		},
+	}, {
+		name:  "Not Author",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID+1, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(msg, nil)
+			store.EXPECT().EditMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:  "System Message",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(system, nil)
+			store.EXPECT().EditMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:  "Not Found",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(db.Message{}, sql.ErrNoRows)
+			store.EXPECT().EditMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:  "Already Deleted",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(deleted, nil)
+			store.EXPECT().EditMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:  "Bad Request",
+		msgID: msg.ID,
+		arg:   gin.H{"content": ""},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:  "Int Server Err",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(msg, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(sender, nil)
+			store.EXPECT().EditMessageTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Message{}, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}, {
+		name:  "No Auth",
+		msgID: msg.ID,
+		arg:   gin.H{"content": content},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/message/%d", tc.msgID)
+			marshalled, err := json.Marshal(tc.arg)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(marshalled))
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	user, _ := randomDBUser(t)
+	msg := randomMessage(user)
+	sender := senderRow(user)
+
+	deleted := msg
+	deleted.Content = "message deleted"
+	deleted.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	system := msg
+	system.IsSystem = true
+
+	testCases := []struct {
+		name       string
+		msgID      int64
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name:  "OK",
+		msgID: msg.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(msg, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(sender, nil)
+			store.EXPECT().DeleteMessageTx(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(deleted, nil)
+			store.EXPECT().
+				ListConvMembers(gomock.Any(), gomock.Eq(msg.ConvID)).
+				Times(1).
+				Return([]int64{user.ID}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+			requireMessageBody(t, recorder.Body, deleted, sender)
+		},
+	}, {
+		name:  "Not Author",
+		msgID: msg.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID+1, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(msg, nil)
+			store.EXPECT().DeleteMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:  "System Message",
+		msgID: msg.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(system, nil)
+			store.EXPECT().DeleteMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		},
+	}, {
+		name:  "Already Deleted",
+		msgID: msg.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(deleted, nil)
+			store.EXPECT().DeleteMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:  "Bad Request",
+		msgID: 0,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:  "Int Server Err",
+		msgID: msg.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetMessage(gomock.Any(), gomock.Eq(msg.ID)).Times(1).Return(db.Message{}, sql.ErrConnDone)
+			store.EXPECT().DeleteMessageTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/message/%d", tc.msgID)
+
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
 	}
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -15,7 +16,7 @@ const (
 	authPayloadKey         = "authorization_payload"
 )
 
-func authMWare(tokenMaker token.Maker) gin.HandlerFunc {
+func authMWare(tokenMaker token.Maker, revoker token.Revoker) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
@@ -41,7 +42,32 @@ func authMWare(tokenMaker token.Maker) gin.HandlerFunc {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
+		revoked, err := revoker.IsRevoked(ctx, payload)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if revoked {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(token.ErrRevokedToken))
+			return
+		}
 		ctx.Set(authPayloadKey, payload)
 		ctx.Next()
 	}
 }
+
+// requireScope must run after authMWare and rejects tokens missing any of
+// the given scopes.
+func requireScope(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(authPayloadKey).(*token.Payload)
+		for _, scope := range scopes {
+			if !payload.HasScope(scope) {
+				err := fmt.Errorf("token is missing the %q scope", scope)
+				ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
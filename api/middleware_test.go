@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -20,11 +21,24 @@ func addAuth(
 	id int64,
 	duration time.Duration,
 ) {
-	token, payload, err := tokenMaker.CreateToken(id, duration)
+	addScopedAuth(t, request, tokenMaker, authorizationType, id, token.RoleUser, token.ScopesForRole(token.RoleUser), duration)
+}
+
+func addScopedAuth(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	id int64,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) {
+	accessToken, payload, err := tokenMaker.CreateToken(id, role, scopes, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
-	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, accessToken)
 
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
 }
@@ -83,11 +97,13 @@ func TestAuthMWare(t *testing.T) {
 		tc := testCases[i]
 		t.Run(tc.name, func(t *testing.T) {
 			server := newTestServer(t, nil)
+			revoker, err := token.NewCachedRevoker(nil, 16, time.Minute)
+			require.NoError(t, err)
 
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMWare(server.tokenMaker),
+				authMWare(server.tokenMaker, revoker),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -108,3 +124,103 @@ func TestAuthMWare(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMWareRevoked(t *testing.T) {
+	server := newTestServer(t, nil)
+	revoker, err := token.NewCachedRevoker(nil, 16, time.Minute)
+	require.NoError(t, err)
+
+	authPath := "/auth"
+	server.router.GET(
+		authPath,
+		authMWare(server.tokenMaker, revoker),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	accessToken, payload, err := server.tokenMaker.CreateToken(8, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+	require.NoError(t, err)
+	serve := func(accessToken string) int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, authPath, nil)
+		require.NoError(t, err)
+		request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authTypeBearer, accessToken))
+		server.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve(accessToken))
+	require.NoError(t, revoker.Revoke(context.Background(), payload))
+	require.Equal(t, http.StatusUnauthorized, serve(accessToken))
+
+	// revoking a user rejects every token issued before the cutoff only
+	other, _, err := server.tokenMaker.CreateToken(9, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, revoker.RevokeUser(context.Background(), 9, time.Now()))
+	require.Equal(t, http.StatusUnauthorized, serve(other))
+
+	time.Sleep(time.Millisecond)
+	fresh, _, err := server.tokenMaker.CreateToken(9, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serve(fresh))
+}
+
+func TestRequireScope(t *testing.T) {
+	testCases := []struct {
+		name      string
+		setupAuth func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkRes  func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuth(t, request, tokenMaker, authTypeBearer, 8, token.RoleAdmin, token.ScopesForRole(token.RoleAdmin), time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		}, {
+			name: "Missing Scope",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, 8, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		}, {
+			name: "Read Only",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuth(t, request, tokenMaker, authTypeBearer, 8, token.RoleUser, []string{token.ScopeRead}, time.Minute)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			revoker, err := token.NewCachedRevoker(nil, 16, time.Minute)
+			require.NoError(t, err)
+
+			authPath := "/scoped"
+			server.router.GET(
+				authPath,
+				authMWare(server.tokenMaker, revoker),
+				requireScope(token.ScopeWrite, token.ScopeAdmin),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
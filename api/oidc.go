@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie   = "oidc_state"
+	oidcStateDuration = 10 * time.Minute
+	oidcTimeout       = 10 * time.Second
+	oidcRandomBytes   = 16
+)
+
+var (
+	errUnknownProvider    = errors.New("unknown identity provider")
+	errOIDCState          = errors.New("sign in expired or was started elsewhere, try again")
+	errOIDCEmailRequired  = errors.New("identity provider did not share a verified email address")
+	errOIDCLinkUnverified = errors.New("an account with this email exists but has not been verified")
+)
+
+// oidcProvider is one configured identity provider. Discovery happens the
+// first time it is used so a provider that is down does not stop the server
+// starting, and is retried until it succeeds.
+type oidcProvider struct {
+	config util.OIDCProvider
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func newOIDCProviders(config util.Config) map[string]*oidcProvider {
+	providers := make(map[string]*oidcProvider, len(config.OIDCProviders))
+	for _, provider := range config.OIDCProviders {
+		providers[provider.Name] = &oidcProvider{config: provider}
+	}
+	return providers
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.provider, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oidcTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+	p.provider = provider
+	return provider, nil
+}
+
+// oauth2Config sends the provider back to the callback route under APP_URL,
+// which is the redirect URL to register with it.
+func (p *oidcProvider) oauth2Config(provider *oidc.Provider, appURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  fmt.Sprintf("%s/auth/oidc/%s/callback", strings.TrimRight(appURL, "/"), p.config.Name),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+type oidcClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// exchange trades an authorization code for the ID token it stands for and
+// returns the claims once the signature, audience and nonce check out.
+func (p *oidcProvider) exchange(ctx context.Context, appURL, code, nonce string) (oidcClaims, error) {
+	var claims oidcClaims
+
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return claims, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, oidcTimeout)
+	defer cancel()
+
+	tok, err := p.oauth2Config(provider, appURL).Exchange(ctx, code)
+	if err != nil {
+		return claims, err
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return claims, errors.New("token response has no id_token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return claims, err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return claims, errors.New("id token nonce does not match")
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+func randomURLToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (server *Server) oidcProvider(ctx *gin.Context) (*oidcProvider, bool) {
+	provider, ok := server.oidcProviders[ctx.Param("provider")]
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errorResponse(errUnknownProvider))
+	}
+	return provider, ok
+}
+
+// startOIDC sends the browser to the identity provider. The state and nonce
+// it has to come back with ride along in a signed cookie.
+func (server *Server) startOIDC(ctx *gin.Context) {
+	p, ok := server.oidcProvider(ctx)
+	if !ok {
+		return
+	}
+	provider, err := p.discover(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errorResponse(err))
+		return
+	}
+
+	state, err := randomURLToken(oidcRandomBytes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	nonce, err := randomURLToken(oidcRandomBytes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	cookie, err := server.signer.Sign(token.PurposeOIDCState, strings.Join([]string{state, nonce, p.config.Name}, ":"), oidcStateDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.setOIDCCookie(ctx, cookie, int(oidcStateDuration.Seconds()))
+
+	authURL := p.oauth2Config(provider, server.config.AppURL).AuthCodeURL(state, oidc.Nonce(nonce))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// setOIDCCookie scopes the state cookie to the sign in routes. Lax is the
+// strictest SameSite mode that still sends it on the redirect back.
+func (server *Server) setOIDCCookie(ctx *gin.Context, value string, maxAge int) {
+	secure := strings.HasPrefix(server.config.AppURL, "https://")
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, value, maxAge, "/auth/oidc/", "", secure, true)
+}
+
+// oidcCallback finishes a sign in started by startOIDC and logs the user in
+// exactly like loginUser would, two-factor included.
+func (server *Server) oidcCallback(ctx *gin.Context) {
+	p, ok := server.oidcProvider(ctx)
+	if !ok {
+		return
+	}
+	if reason := ctx.Query("error"); reason != "" {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("identity provider refused sign in: %s", reason)))
+		return
+	}
+
+	cookie, err := ctx.Cookie(oidcStateCookie)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errOIDCState))
+		return
+	}
+	// the state is single use whatever happens next
+	server.setOIDCCookie(ctx, "", -1)
+
+	subject, err := server.signer.Verify(token.PurposeOIDCState, cookie)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errOIDCState))
+		return
+	}
+	parts := strings.SplitN(subject, ":", 3)
+	if len(parts) != 3 || parts[2] != p.config.Name ||
+		subtle.ConstantTimeCompare([]byte(parts[0]), []byte(ctx.Query("state"))) != 1 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errOIDCState))
+		return
+	}
+	code := ctx.Query("code")
+	if code == "" {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errOIDCState))
+		return
+	}
+
+	claims, err := p.exchange(ctx, server.config.AppURL, code, parts[1])
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	user, err := server.oidcUser(ctx, p.config.Name, claims)
+	if err != nil {
+		if err == errOIDCEmailRequired || err == errOIDCLinkUnverified {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.finishLogin(ctx, user)
+}
+
+// oidcUser finds the account behind an external identity. Identities seen
+// before map straight to their user. A new one is linked to the account with
+// the same verified email, or gets a fresh account when there is none.
+func (server *Server) oidcUser(ctx context.Context, provider string, claims oidcClaims) (db.User, error) {
+	identity, err := server.store.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		row, err := server.store.GetUser(ctx, identity.UserID)
+		if err != nil {
+			return db.User{}, err
+		}
+		return userFromRow(row), nil
+	}
+	if err != sql.ErrNoRows {
+		return db.User{}, err
+	}
+
+	// an unverified address proves nothing about who owns the account
+	if claims.Email == "" || !claims.EmailVerified {
+		return db.User{}, errOIDCEmailRequired
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, claims.Email)
+	if err == nil {
+		// whoever registered the address never proved they own it, linking
+		// would hand the account's password to someone else
+		if !user.EmailVerifiedAt.Valid {
+			return db.User{}, errOIDCLinkUnverified
+		}
+		_, err = server.store.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+		return user, err
+	}
+	if err != sql.ErrNoRows {
+		return db.User{}, err
+	}
+
+	// nobody knows this password, a password reset sets a usable one
+	password, err := randomURLToken(2 * oidcRandomBytes)
+	if err != nil {
+		return db.User{}, err
+	}
+	hashedPw, err := util.HashPassword(password)
+	if err != nil {
+		return db.User{}, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	return server.store.CreateOIDCUserTx(ctx, db.CreateOIDCUserParams{
+		CreateUserParams: db.CreateUserParams{
+			Name:     name,
+			Email:    claims.Email,
+			HashedPw: hashedPw,
+			Image:    sql.NullString{String: claims.Picture, Valid: claims.Picture != ""},
+		},
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+}
@@ -0,0 +1,452 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mockClientID     = "messaging-server"
+	mockClientSecret = "secret"
+	mockAuthCode     = "good-code"
+)
+
+// mockOIDC is just enough of an OpenID provider for the authorization code
+// flow: discovery, keys and a token endpoint that answers one code.
+type mockOIDC struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// claims go into the next ID token, the test sets the nonce
+	claims jwt.MapClaims
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	mock := &mockOIDC{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                mock.URL,
+			"authorization_endpoint":                mock.URL + "/authorize",
+			"token_endpoint":                        mock.URL + "/token",
+			"jwks_uri":                              mock.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		writeJSON(w, http.StatusOK, token.JWKSet{Keys: []token.JWK{{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: "mock",
+			N:   enc.EncodeToString(key.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != mockClientID || secret != mockClientSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.PostFormValue("code") != mockAuthCode {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, mock.claims)
+		idToken.Header["kid"] = "mock"
+		signed, err := idToken.SignedString(key)
+		require.NoError(t, err)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+
+	mock.Server = httptest.NewServer(mux)
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// identityClaims are what the provider says about a user with a verified
+// address, nonce is filled in after the sign in starts.
+func (mock *mockOIDC) identityClaims(subject, email string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            mock.URL,
+		"aud":            mockClientID,
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+		"name":           "Mock User",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+	}
+}
+
+func newOIDCTestServer(t *testing.T, store db.Store, mock *mockOIDC) *Server {
+	server := newTestServer(t, store)
+	server.config.AppURL = "http://localhost:8080"
+	server.oidcProviders = newOIDCProviders(util.Config{OIDCProviders: []util.OIDCProvider{{
+		Name:         "mock",
+		IssuerURL:    mock.URL,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+	}}})
+	return server
+}
+
+// startOIDCLogin runs the start route and returns what the browser would
+// carry to the provider and back.
+func startOIDCLogin(t *testing.T, server *Server) (state, nonce string, cookie *http.Cookie) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/auth/oidc/mock/start", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	query := location.Query()
+	require.Equal(t, mockClientID, query.Get("client_id"))
+	require.Equal(t, "http://localhost:8080/auth/oidc/mock/callback", query.Get("redirect_uri"))
+	require.Contains(t, query.Get("scope"), "openid")
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, oidcStateCookie, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+	return query.Get("state"), query.Get("nonce"), cookies[0]
+}
+
+func TestStartOIDC(t *testing.T) {
+	mock := newMockOIDC(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := newOIDCTestServer(t, mockdb.NewMockStore(ctrl), mock)
+
+	state, nonce, _ := startOIDCLogin(t, server)
+	require.NotEmpty(t, state)
+	require.NotEmpty(t, nonce)
+	require.NotEqual(t, state, nonce)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/auth/oidc/unknown/start", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	mock := newMockOIDC(t)
+	user, _ := randomDBUser(t)
+	row := db.GetUserRow{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		CreatedAt:       user.CreatedAt,
+		Role:            token.RoleUser,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+	}
+	subject := util.RandomString(12)
+	identity := db.UserIdentity{ID: 1, UserID: user.ID, Provider: "mock", Subject: subject, Email: user.Email}
+
+	type callback struct {
+		query  url.Values
+		cookie *http.Cookie
+	}
+
+	testCases := []struct {
+		name string
+		// tweak changes the claims or the callback request the happy path
+		// would produce
+		tweak      func(claims jwt.MapClaims, cb *callback)
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Linked Identity",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserIdentity(gomock.Any(), gomock.Eq(db.GetUserIdentityParams{Provider: "mock", Subject: subject})).
+					Times(1).
+					Return(identity, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.NotEmpty(t, got.AccessToken)
+				require.NotEmpty(t, got.RefreshToken)
+				require.Equal(t, user.ID, got.User.ID)
+			},
+		},
+		{
+			name: "Link By Email",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrNoRows)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateUserIdentity(gomock.Any(), gomock.Eq(db.CreateUserIdentityParams{
+						UserID:   user.ID,
+						Provider: "mock",
+						Subject:  subject,
+						Email:    user.Email,
+					})).
+					Times(1).
+					Return(identity, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "New User",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrNoRows)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateOIDCUserParams) (db.User, error) {
+						require.Equal(t, "mock", arg.Provider)
+						require.Equal(t, subject, arg.Subject)
+						require.Equal(t, user.Email, arg.Email)
+						require.Equal(t, "Mock User", arg.Name)
+						require.NotEmpty(t, arg.HashedPw)
+						return user, nil
+					})
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Two Factor",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(identity, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{UserID: user.ID, ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got mfaChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.True(t, got.MFARequired)
+				require.NotEmpty(t, got.MFAToken)
+			},
+		},
+		{
+			name: "Unverified Email Claim",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				claims["email_verified"] = false
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrNoRows)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateOIDCUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errOIDCEmailRequired.Error())
+			},
+		},
+		{
+			name: "Unverified Account",
+			buildStubs: func(store *mockdb.MockStore) {
+				unverified := user
+				unverified.EmailVerifiedAt = sql.NullTime{}
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrNoRows)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(unverified, nil)
+				store.EXPECT().CreateUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errOIDCLinkUnverified.Error())
+			},
+		},
+		{
+			name: "Duplicate Email",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrNoRows)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pq.Error{Code: "23505"})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Identity Lookup Err",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserIdentity{}, sql.ErrConnDone)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong State",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				cb.query.Set("state", "forged")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errOIDCState.Error())
+			},
+		},
+		{
+			name: "No Cookie",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				cb.cookie = nil
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Forged Cookie",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				cb.cookie.Value = cb.query.Get("state") + ":nonce:mock"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Bad Code",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				cb.query.Set("code", "bad-code")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong Nonce",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				claims["nonce"] = "replayed"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong Audience",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				claims["aud"] = "someone-else"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Provider Refused",
+			tweak: func(claims jwt.MapClaims, cb *callback) {
+				cb.query = url.Values{"error": {"access_denied"}, "state": {cb.query.Get("state")}}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserIdentity(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "access_denied")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newOIDCTestServer(t, store, mock)
+			state, nonce, cookie := startOIDCLogin(t, server)
+
+			mock.claims = mock.identityClaims(subject, user.Email)
+			mock.claims["nonce"] = nonce
+			cb := &callback{
+				query:  url.Values{"code": {mockAuthCode}, "state": {state}},
+				cookie: cookie,
+			}
+			if tc.tweak != nil {
+				tc.tweak(mock.claims, cb)
+			}
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?"+cb.query.Encode(), nil)
+			require.NoError(t, err)
+			if cb.cookie != nil {
+				request.AddCookie(cb.cookie)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
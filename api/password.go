@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+)
+
+const (
+	resetTokenDuration = time.Hour
+	resetTokenBytes    = 32
+)
+
+// newResetToken returns a random token for the email and the hash to store.
+func newResetToken() (string, string, error) {
+	raw := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(raw)
+	return resetToken, hashResetToken(resetToken), nil
+}
+
+func hashResetToken(resetToken string) string {
+	sum := sha256.Sum256([]byte(resetToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (server *Server) sendPasswordReset(ctx context.Context, email, resetToken string) error {
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(server.config.AppURL, "/"), url.QueryEscape(resetToken))
+
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+	return server.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Choose a new password by opening the link below, it is valid for %s and works once.\n\n%s\n\n"+
+			"If you did not ask for this you can ignore this message, your password is unchanged.", resetTokenDuration, link),
+	})
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// forgotPassword mails a reset link. Like resendVerification it answers the
+// same for unknown addresses, and as quickly: the reset is created and mailed
+// after the response.
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.Status(http.StatusAccepted)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.runAsync(func(ctx context.Context) {
+		if err := server.createPasswordReset(ctx, user); err != nil {
+			log.Printf("cannot send password reset to user %d: %v", user.ID, err)
+		}
+	})
+	ctx.Status(http.StatusAccepted)
+}
+
+func (server *Server) createPasswordReset(ctx context.Context, user db.User) error {
+	resetToken, hash, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	_, err = server.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(resetTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+	return server.sendPasswordReset(ctx, user.Email, resetToken)
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// resetPassword sets a new password with an emailed token and logs the user
+// out everywhere.
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := util.ValidatePassword(req.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashed, err := util.HashPassword(req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	userID, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordParams{
+		TokenHash: hashResetToken(req.Token),
+		HashedPw:  hashed,
+	})
+	if err != nil {
+		if err == db.ErrResetTokenInvalid {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.revoker.RevokeUser(ctx, userID, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+var (
+	errWrongPassword     = errors.New("current password is incorrect")
+	errPasswordUnchanged = errors.New("new password must differ from the current one")
+)
+
+// passwordKey rate limits guesses at the current password made with a
+// stolen access token, separately from logins to the same account.
+func passwordKey(userID int64) string {
+	return fmt.Sprintf("password:%d", userID)
+}
+
+// requirePassword guards changes a stolen access token alone must not make,
+// the caller has to prove they know the current password. It writes the
+// error response itself, callers only need to return when ok is false.
+func (server *Server) requirePassword(ctx *gin.Context, userID int64, password string) bool {
+	key := passwordKey(userID)
+	if wait := server.accountGuard.retryAfter(key); wait > 0 {
+		tooManyRequests(ctx, wait)
+		return false
+	}
+
+	current, err := server.store.GetUserPassword(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if err := util.CheckPassword(password, current); err != nil {
+		server.accountGuard.fail(key)
+		ctx.JSON(http.StatusForbidden, errorResponse(errWrongPassword))
+		return false
+	}
+	server.accountGuard.reset(key)
+	return true
+}
+
+// changePassword replaces the password of the caller, who has to know the
+// current one. Every session and token ends, including the one used here, so
+// the client logs in again with the new password.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := util.ValidatePassword(req.NewPassword); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errPasswordUnchanged))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if !server.requirePassword(ctx, auth.User, req.CurrentPassword) {
+		return
+	}
+
+	hashed, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	err = server.store.ChangePasswordTx(ctx, db.ChangePasswordParams{UserID: auth.User, HashedPw: hashed})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.revoker.RevokeUser(ctx, auth.User, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
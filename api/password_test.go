@@ -0,0 +1,483 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+var resetLink = regexp.MustCompile(`reset-password\?token=(\S+)`)
+
+func TestForgotPassword(t *testing.T) {
+	user, _ := randomDBUser(t)
+
+	testCases := []struct {
+		name       string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore, stored *string)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore, stored *string) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().DeleteExpiredPasswordResets(gomock.Any()).Times(0)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+						require.Equal(t, user.ID, arg.UserID)
+						require.WithinDuration(t, time.Now().Add(resetTokenDuration), arg.ExpiresAt, time.Second)
+						*stored = arg.TokenHash
+						return db.PasswordReset{UserID: arg.UserID, TokenHash: arg.TokenHash, ExpiresAt: arg.ExpiresAt}, nil
+					})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Contains(t, mailed.String(), "To: "+user.Email)
+
+				match := resetLink.FindStringSubmatch(mailed.String())
+				require.Len(t, match, 2)
+				resetToken, err := url.QueryUnescape(match[1])
+				require.NoError(t, err)
+				// only the hash is stored
+				require.NotEqual(t, resetToken, stored)
+				require.Equal(t, hashResetToken(resetToken), stored)
+			},
+		},
+		{
+			name: "Unknown Email",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore, stored *string) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().CreatePasswordReset(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Zero(t, mailed.Len())
+			},
+		},
+		{
+			name: "Internal Err",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore, stored *string) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Create Err",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore, stored *string) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().DeleteExpiredPasswordResets(gomock.Any()).Times(0)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordReset{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string) {
+				// the reset is made after answering, a failure only shows in the log
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Zero(t, mailed.Len())
+			},
+		},
+		{
+			name: "Bad Request",
+			body: gin.H{"email": "not-an-email"},
+			buildStubs: func(store *mockdb.MockStore, stored *string) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer, stored string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			var stored string
+			tc.buildStubs(store, &stored)
+
+			server := newTestServer(t, store)
+			var mailed bytes.Buffer
+			server.mailer = mail.NewLogMailer(&mailed, "no-reply@example.com")
+
+			recorder := postJSON(t, server, "/account/password/forgot", tc.body)
+			server.tasks.Wait()
+			tc.checkRes(t, recorder, &mailed, stored)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	user, _ := randomDBUser(t)
+	resetToken, hash, err := newResetToken()
+	require.NoError(t, err)
+	password := util.RandomPassword()
+
+	testCases := []struct {
+		name       string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"token": resetToken, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ResetPasswordParams) (int64, error) {
+						require.Equal(t, hash, arg.TokenHash)
+						require.NoError(t, util.CheckPassword(password, arg.HashedPw))
+						return user.ID, nil
+					})
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.RevokeUserTokensParams) error {
+						require.Equal(t, user.ID, arg.UserID)
+						return nil
+					})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "Invalid Token",
+			body: gin.H{"token": resetToken, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), db.ErrResetTokenInvalid)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Internal Err",
+			body: gin.H{"token": resetToken, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Revoke Err",
+			body: gin.H{"token": resetToken, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user.ID, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Weak Password",
+			body: gin.H{"token": resetToken, "password": "onlyletters"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrPasswordTooSimple.Error())
+			},
+		},
+		{
+			name: "Short Password",
+			body: gin.H{"token": resetToken, "password": "123"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := postJSON(t, server, "/account/password/reset", tc.body)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user, current := randomDBUser(t)
+	newPassword := util.RandomPassword()
+
+	testCases := []struct {
+		name       string
+		body       gin.H
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+		setup      func(server *Server)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ChangePasswordParams) error {
+						require.Equal(t, user.ID, arg.UserID)
+						require.NoError(t, util.CheckPassword(newPassword, arg.HashedPw))
+						return nil
+					})
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.RevokeUserTokensParams) error {
+						require.Equal(t, user.ID, arg.UserID)
+						require.WithinDuration(t, time.Now(), arg.RevokedBefore, time.Second)
+						return nil
+					})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong Current Password",
+			body: gin.H{"current_password": "wrong-password-1", "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errWrongPassword.Error())
+			},
+		},
+		{
+			name: "Locked Out",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			setup: func(server *Server) {
+				for i := 0; i <= accountFreeFailures; i++ {
+					server.accountGuard.fail(passwordKey(user.ID))
+				}
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "Unchanged",
+			body: gin.H{"current_password": newPassword, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errPasswordUnchanged.Error())
+			},
+		},
+		{
+			name: "Weak Password",
+			body: gin.H{"current_password": current, "new_password": "short1"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), util.ErrPasswordTooShort.Error())
+			},
+		},
+		{
+			name: "Missing Current Password",
+			body: gin.H{"new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Not Found",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("", sql.ErrNoRows)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "Tx Err",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Revoke Err",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuth(t, request, tokenMaker, authTypeBearer, user.ID, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "No Auth",
+			body: gin.H{"current_password": current, "new_password": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			if tc.setup != nil {
+				tc.setup(server)
+			}
+
+			marshalled, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, "/account/password", bytes.NewReader(marshalled))
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMaker)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
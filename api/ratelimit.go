@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// how many distinct clients are tracked before the least recently seen
+	// are forgotten
+	rateLimitCacheSize = 10000
+
+	// unauthenticated endpoints allow a burst, then one request a second
+	publicRateLimit = rate.Limit(1)
+	publicRateBurst = 10
+
+	// failed logins allowed before backoff starts, an ip gets more room since
+	// many users can share one
+	accountFreeFailures = 5
+	ipFreeFailures      = 20
+	loginBackoffBase    = time.Second
+	loginBackoffMax     = 15 * time.Minute
+	// failures are forgotten once a key has had none for this long
+	loginFailureWindow = time.Hour
+	loginSweepInterval = time.Minute
+)
+
+var errTooManyRequests = errors.New("too many requests, try again later")
+
+// keyedLimiter hands out one token bucket per key.
+type keyedLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters *lru.Cache[string, *rate.Limiter]
+}
+
+func newKeyedLimiter(limit rate.Limit, burst int) *keyedLimiter {
+	limiters, _ := lru.New[string, *rate.Limiter](rateLimitCacheSize)
+	return &keyedLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: limiters,
+	}
+}
+
+func (l *keyedLimiter) get(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters.Get(key)
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters.Add(key, limiter)
+	}
+	return limiter
+}
+
+// rateLimit rejects clients that exceed limiter, keyed by client ip.
+func rateLimit(limiter *keyedLimiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reservation := limiter.get(ctx.ClientIP()).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			tooManyRequests(ctx, delay)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func tooManyRequests(ctx *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(errTooManyRequests))
+}
+
+type loginFailures struct {
+	count       int
+	lockedUntil time.Time
+	lastFailed  time.Time
+}
+
+// loginGuard counts failed logins per key. Past the free failures every
+// further one locks the key out for twice as long as the previous, up to
+// loginBackoffMax. Counts are never evicted to make room, so failures against
+// made up accounts cannot push a real account's count out; they are only
+// forgotten after loginFailureWindow without a failure.
+type loginGuard struct {
+	mu        sync.Mutex
+	free      int
+	failures  map[string]loginFailures
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLoginGuard(free int) *loginGuard {
+	return &loginGuard{
+		free:     free,
+		failures: make(map[string]loginFailures),
+		now:      time.Now,
+	}
+}
+
+// retryAfter is how long key stays locked out, zero when it may try now.
+func (g *loginGuard) retryAfter(key string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	entry, ok := g.failures[key]
+	if !ok {
+		return 0
+	}
+	if wait := entry.lockedUntil.Sub(g.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (g *loginGuard) fail(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	g.sweep(now)
+
+	entry := g.failures[key]
+	entry.count++
+	entry.lastFailed = now
+	if over := entry.count - g.free; over > 0 {
+		backoff := loginBackoffMax
+		if over <= 20 {
+			backoff = loginBackoffBase << (over - 1)
+		}
+		if backoff > loginBackoffMax {
+			backoff = loginBackoffMax
+		}
+		entry.lockedUntil = now.Add(backoff)
+	}
+	g.failures[key] = entry
+}
+
+func (g *loginGuard) reset(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, key)
+}
+
+// sweep forgets keys that stopped failing. A lockout never outlasts the
+// window, so nothing still locked is dropped. Callers hold g.mu.
+func (g *loginGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < loginSweepInterval {
+		return
+	}
+	g.lastSweep = now
+	for key, entry := range g.failures {
+		if now.Sub(entry.lastFailed) > loginFailureWindow {
+			delete(g.failures, key)
+		}
+	}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := gin.New()
+	router.GET("/limited", rateLimit(newKeyedLimiter(rate.Every(time.Hour), 2)), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	serve := func(ip string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/limited", nil)
+		require.NoError(t, err)
+		request.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusOK, serve("10.0.0.1").Code)
+	require.Equal(t, http.StatusOK, serve("10.0.0.1").Code)
+
+	recorder := serve("10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	// other clients have their own bucket
+	require.Equal(t, http.StatusOK, serve("10.0.0.2").Code)
+}
+
+func TestLoginGuard(t *testing.T) {
+	now := time.Now()
+	guard := newLoginGuard(2)
+	guard.now = func() time.Time { return now }
+
+	guard.fail("a")
+	guard.fail("a")
+	require.Zero(t, guard.retryAfter("a"))
+
+	// every failure past the free ones doubles the lockout
+	guard.fail("a")
+	require.Equal(t, loginBackoffBase, guard.retryAfter("a"))
+	guard.fail("a")
+	require.Equal(t, 2*loginBackoffBase, guard.retryAfter("a"))
+	require.Zero(t, guard.retryAfter("b"))
+
+	for i := 0; i < 100; i++ {
+		guard.fail("a")
+	}
+	require.Equal(t, loginBackoffMax, guard.retryAfter("a"))
+
+	now = now.Add(loginBackoffMax)
+	require.Zero(t, guard.retryAfter("a"))
+
+	guard.reset("a")
+	guard.fail("a")
+	require.Zero(t, guard.retryAfter("a"))
+}
+
+func TestLoginGuardNoEviction(t *testing.T) {
+	now := time.Now()
+	guard := newLoginGuard(0)
+	guard.now = func() time.Time { return now }
+
+	guard.fail("victim")
+	require.Equal(t, loginBackoffBase, guard.retryAfter("victim"))
+
+	// failures against made up accounts do not push the victim's count out
+	for i := 0; i < 2*rateLimitCacheSize; i++ {
+		guard.fail(fmt.Sprintf("unknown-%d@example.com", i))
+	}
+	require.Equal(t, loginBackoffBase, guard.retryAfter("victim"))
+
+	// keys that stopped failing are eventually forgotten
+	now = now.Add(loginFailureWindow + time.Second)
+	guard.fail("other")
+	guard.mu.Lock()
+	require.Len(t, guard.failures, 1)
+	guard.mu.Unlock()
+}
+
+func TestLoginLockout(t *testing.T) {
+	user, password := randomDBUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(accountFreeFailures+1).
+		Return(user, nil)
+
+	// stays within the public limiter's burst, only the lockout is under test
+	require.Less(t, accountFreeFailures+2, publicRateBurst)
+	server := newTestServer(t, store)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		data, err := json.Marshal(gin.H{"email": user.Email, "password": password})
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, "/account/login", bytes.NewReader(data))
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for i := 0; i < accountFreeFailures+1; i++ {
+		require.Equal(t, http.StatusUnauthorized, login("wrongpassword").Code)
+	}
+
+	// locked out now, even the right password is not checked
+	recorder := login(password)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
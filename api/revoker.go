@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const (
+	revokerCacheSize = 10000
+	// how long a token known to be valid is trusted without asking Postgres
+	revokerCacheTTL = 30 * time.Second
+)
+
+// storeRevoker keeps revocations in Postgres so every server instance
+// rejects the same tokens.
+type storeRevoker struct {
+	store db.Store
+}
+
+func (r storeRevoker) Revoke(ctx context.Context, payload *token.Payload) error {
+	return r.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.ID,
+		UserID:    payload.User,
+		ExpiresAt: payload.Expires,
+	})
+}
+
+func (r storeRevoker) RevokeUser(ctx context.Context, user int64, before time.Time) error {
+	return r.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		UserID:        user,
+		RevokedBefore: before,
+	})
+}
+
+func (r storeRevoker) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	return r.store.IsTokenRevoked(ctx, db.IsTokenRevokedParams{
+		ID:       payload.ID,
+		UserID:   payload.User,
+		IssuedAt: payload.CreatedAt,
+	})
+}
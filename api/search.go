@@ -0,0 +1,140 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const defaultSearchPage = 20
+
+type searchMessagesQuery struct {
+	Q      string    `form:"q" binding:"required,max=256"`
+	ConvID int64     `form:"conv_id" binding:"omitempty,min=1"`
+	From   int64     `form:"from" binding:"omitempty,min=1"`
+	Before time.Time `form:"before" time_format:"2006-01-02T15:04:05Z07:00"`
+	After  time.Time `form:"after" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor string    `form:"cursor"`
+	Limit  int32     `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SearchResult.Snippet is the matching part of the message, HTML escaped,
+// with the matched terms wrapped in <mark></mark>.
+type SearchResult struct {
+	ID        int64     `json:"id"`
+	ConvID    int64     `json:"conv_id"`
+	SenderID  int64     `json:"sender_id"`
+	From      string    `json:"from"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchReturn struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+var errBadCursor = errors.New("invalid cursor")
+
+// Search results are ordered by rank then id, so the cursor carries both.
+// It is opaque to clients.
+func encodeSearchCursor(rank float32, id int64) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float32, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errBadCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, errBadCursor
+	}
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return 0, 0, errBadCursor
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, errBadCursor
+	}
+	return float32(rank), id, nil
+}
+
+// searchMessages runs a full-text search over every conversation the caller
+// belongs to, best matches first.
+func (server *Server) searchMessages(ctx *gin.Context) {
+	var query searchMessagesQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if strings.TrimSpace(query.Q) == "" {
+		err := errors.New("q cannot be blank")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !query.Before.IsZero() && !query.After.IsZero() && !query.After.Before(query.Before) {
+		err := errors.New("after must be earlier than before")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchPage
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	arg := db.SearchMessagesParams{
+		Query:    query.Q,
+		UserID:   auth.User,
+		ConvID:   sql.NullInt64{Int64: query.ConvID, Valid: query.ConvID != 0},
+		SenderID: sql.NullInt64{Int64: query.From, Valid: query.From != 0},
+		Before:   sql.NullTime{Time: query.Before, Valid: !query.Before.IsZero()},
+		After:    sql.NullTime{Time: query.After, Valid: !query.After.IsZero()},
+		Limit:    query.Limit + 1,
+	}
+	if query.Cursor != "" {
+		rank, id, err := decodeSearchCursor(query.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.CursorRank = sql.NullFloat64{Float64: float64(rank), Valid: true}
+		arg.CursorID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	rows, err := server.store.SearchMessages(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// one extra row tells us whether another page exists
+	ret := SearchReturn{Results: []SearchResult{}}
+	if len(rows) > int(query.Limit) {
+		rows = rows[:query.Limit]
+		last := rows[len(rows)-1]
+		ret.NextCursor = encodeSearchCursor(last.Rank, last.ID)
+	}
+	for _, row := range rows {
+		ret.Results = append(ret.Results, SearchResult{
+			ID:        row.ID,
+			ConvID:    row.ConvID,
+			SenderID:  row.SenderID.Int64,
+			From:      row.SenderName,
+			Snippet:   row.Snippet,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, ret)
+}
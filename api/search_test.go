@@ -0,0 +1,189 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchCursor(t *testing.T) {
+	rank := float32(0.0607927)
+	id := util.RandomInt(1, 1000)
+
+	gotRank, gotID, err := decodeSearchCursor(encodeSearchCursor(rank, id))
+	require.NoError(t, err)
+	require.Equal(t, rank, gotRank)
+	require.Equal(t, id, gotID)
+
+	for _, bad := range []string{"!!", "bm9jb2xvbg", "eDox", "MC41Ong"} {
+		_, _, err := decodeSearchCursor(bad)
+		require.ErrorIs(t, err, errBadCursor)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	user, _ := randomDBUser(t)
+	convID := util.RandomInt(1, 1000)
+	before := time.Now().UTC().Truncate(time.Second)
+	after := before.Add(-24 * time.Hour)
+
+	n := 3
+	rows := make([]db.SearchMessagesRow, n)
+	for i := range rows {
+		rows[i] = db.SearchMessagesRow{
+			ID:         int64(100 - i),
+			ConvID:     convID,
+			SenderID:   sql.NullInt64{Int64: user.ID, Valid: true},
+			SenderName: user.Name,
+			CreatedAt:  after.Add(time.Hour),
+			Snippet:    "<mark>" + util.RandomString(6) + "</mark>",
+			Rank:       float32(n-i) / 10,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		query      url.Values
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		query: url.Values{
+			"q":       {"hello"},
+			"conv_id": {"1"},
+			"from":    {"2"},
+			"before":  {before.Format(time.RFC3339)},
+			"after":   {after.Format(time.RFC3339)},
+			"limit":   {"2"},
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				SearchMessages(gomock.Any(), gomock.Eq(db.SearchMessagesParams{
+					Query:    "hello",
+					UserID:   user.ID,
+					ConvID:   sql.NullInt64{Int64: 1, Valid: true},
+					SenderID: sql.NullInt64{Int64: 2, Valid: true},
+					Before:   sql.NullTime{Time: before, Valid: true},
+					After:    sql.NullTime{Time: after, Valid: true},
+					Limit:    3,
+				})).
+				Times(1).
+				Return(rows, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got SearchReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Len(t, got.Results, 2)
+			require.Equal(t, rows[0].Snippet, got.Results[0].Snippet)
+			require.Equal(t, user.ID, got.Results[0].SenderID)
+
+			rank, id, err := decodeSearchCursor(got.NextCursor)
+			require.NoError(t, err)
+			require.Equal(t, rows[1].Rank, rank)
+			require.Equal(t, rows[1].ID, id)
+		},
+	}, {
+		name:  "Cursor",
+		query: url.Values{"q": {"hello"}, "cursor": {encodeSearchCursor(0.5, 42)}},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				SearchMessages(gomock.Any(), gomock.Eq(db.SearchMessagesParams{
+					Query:      "hello",
+					UserID:     user.ID,
+					CursorRank: sql.NullFloat64{Float64: 0.5, Valid: true},
+					CursorID:   sql.NullInt64{Int64: 42, Valid: true},
+					Limit:      defaultSearchPage + 1,
+				})).
+				Times(1).
+				Return([]db.SearchMessagesRow{}, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got SearchReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Empty(t, got.Results)
+			require.Empty(t, got.NextCursor)
+		},
+	}, {
+		name:  "Bad Cursor",
+		query: url.Values{"q": {"hello"}, "cursor": {"nope"}},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().SearchMessages(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:  "Blank Query",
+		query: url.Values{"q": {"   "}},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().SearchMessages(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name: "Bad Range",
+		query: url.Values{
+			"q":      {"hello"},
+			"before": {after.Format(time.RFC3339)},
+			"after":  {before.Format(time.RFC3339)},
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().SearchMessages(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name:  "Int Server Err",
+		query: url.Values{"q": {"hello"}},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				SearchMessages(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(nil, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/search/messages?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
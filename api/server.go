@@ -1,12 +1,19 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
 	"github.com/rjriverac/messaging-server/token"
 	"github.com/rjriverac/messaging-server/util"
 )
@@ -15,52 +22,250 @@ type Server struct {
 	config     util.Config
 	store      db.Store
 	tokenMaker token.Maker
+	revoker    token.Revoker
+	signer     *token.Signer
+	mailer     mail.Mailer
 	router     *gin.Engine
+	hub        *Hub
+	janitor    *janitor
+	httpServer *http.Server
+	// tasks tracks work started by runAsync
+	tasks sync.WaitGroup
+
+	oidcProviders map[string]*oidcProvider
+
+	publicLimiter *keyedLimiter
+	accountGuard  *loginGuard
+	ipGuard       *loginGuard
 }
 
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token:%w", err)
 	}
+	revoker, err := token.NewCachedRevoker(storeRevoker{store}, revokerCacheSize, revokerCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token revoker:%w", err)
+	}
+	signer, err := token.NewSigner(config.TokenSymmetricKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create code signer:%w", err)
+	}
+	mailer, err := newMailer(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create mailer:%w", err)
+	}
 	server := &Server{
 		config:     config,
 		store:      store,
 		tokenMaker: tokenMaker,
+		revoker:    revoker,
+		signer:     signer,
+		mailer:     mailer,
+		hub:        NewHub(),
+
+		oidcProviders: newOIDCProviders(config),
+
+		publicLimiter: newKeyedLimiter(publicRateLimit, publicRateBurst),
+		accountGuard:  newLoginGuard(accountFreeFailures),
+		ipGuard:       newLoginGuard(ipFreeFailures),
 	}
+	go server.hub.Run()
+	// expired rows are swept in the background, not by the requests adding them
+	server.janitor = newJanitor(store, janitorInterval,
+		janitorJob{"revoked tokens", db.Store.DeleteExpiredRevokedTokens},
+		janitorJob{"password resets", db.Store.DeleteExpiredPasswordResets},
+	)
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterStructValidation(validRequest, UpdateUserRequest{})
+		v.RegisterStructValidation(validConvRequest, updateConvRequest{})
 	}
-	server.createRoutes()
+	if err := server.createRoutes(); err != nil {
+		return nil, err
+	}
+	server.httpServer = &http.Server{Handler: server.router}
 	return server, nil
 }
 
+const (
+	tokenTypePasetoLocal  = "paseto-local"
+	tokenTypePasetoPublic = "paseto-public"
+	tokenTypeJWT          = "jwt"
+)
+
+// tokenIssuer is the iss claim of our tokens, TOKEN_ISSUER when it is set and
+// APP_URL otherwise. Empty means tokens carry no issuer.
+func tokenIssuer(config util.Config) string {
+	if config.TokenIssuer != "" {
+		return config.TokenIssuer
+	}
+	return strings.TrimRight(config.AppURL, "/")
+}
+
+// newTokenMaker builds the maker named by TOKEN_TYPE, paseto-local when it is
+// unset. jwt signs with the keys in TOKEN_KEY_DIR when one is given and with
+// TOKEN_SYMMETRIC_KEY otherwise.
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	var opts []token.Option
+	if issuer := tokenIssuer(config); issuer != "" {
+		opts = append(opts, token.WithIssuer(issuer))
+	}
+	if config.TokenAudience != "" {
+		opts = append(opts, token.WithAudience(config.TokenAudience))
+	}
+
+	switch config.TokenType {
+	case "", tokenTypePasetoLocal:
+		return token.NewPasetoMaker(config.TokenSymmetricKey, opts...)
+	case tokenTypePasetoPublic:
+		keys, err := token.LoadKeyDir(config.TokenKeyDir)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewPasetoPublicMaker(keys, opts...)
+	case tokenTypeJWT:
+		if config.TokenKeyDir == "" {
+			return token.NewJWT(config.TokenSymmetricKey, opts...)
+		}
+		keys, err := token.LoadKeyDir(config.TokenKeyDir)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewJWTPublicMaker(keys, opts...)
+	}
+	return nil, fmt.Errorf("unknown token type %q", config.TokenType)
+}
+
+const (
+	mailerLog  = "log"
+	mailerSMTP = "smtp"
+)
+
+// newMailer builds the mailer named by MAILER. log, the default, appends mail
+// to MAIL_LOG_FILE or prints it when no file is set.
+func newMailer(config util.Config) (mail.Mailer, error) {
+	switch config.Mailer {
+	case "", mailerLog:
+		if config.MailLogFile == "" {
+			return mail.NewLogMailer(os.Stdout, config.MailFrom), nil
+		}
+		return mail.NewFileMailer(config.MailLogFile, config.MailFrom)
+	case mailerSMTP:
+		return mail.NewSMTPMailer(config.SMTPAddr, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	}
+	return nil, fmt.Errorf("unknown mailer %q", config.Mailer)
+}
+
 func errorResponse(err error) gin.H {
 	return gin.H{"error": err.Error()}
 }
 
 func (server *Server) StartServer(addr string) error {
-	return server.router.Run(addr)
+	server.httpServer.Addr = addr
+	go server.janitor.Run()
+	return server.httpServer.ListenAndServe()
 }
 
-func (server *Server) createRoutes() {
+// Shutdown stops accepting requests and closes every real-time connection,
+// which http.Server.Shutdown alone does not track once they are hijacked.
+// Mail still being sent in the background goes out before it returns.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.hub.Stop()
+	server.janitor.Stop()
+	err := server.httpServer.Shutdown(ctx)
+	server.tasks.Wait()
+	return err
+}
+
+const asyncTimeout = 30 * time.Second
+
+// runAsync runs fn after the response is sent, for work whose duration must
+// not show in how long the request took. fn gets its own context, the
+// request's ends with the response.
+func (server *Server) runAsync(fn func(ctx context.Context)) {
+	server.tasks.Add(1)
+	go func() {
+		defer server.tasks.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), asyncTimeout)
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// trustedProxies is TRUSTED_PROXIES without blanks. With none configured the
+// client ip is always the connecting address, X-Forwarded-For is whatever the
+// client wants it to be.
+func trustedProxies(config util.Config) []string {
+	proxies := []string{}
+	for _, proxy := range config.TrustedProxies {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func (server *Server) createRoutes() error {
 	router := gin.Default()
-	router.POST("/account/", server.createUser)
-	router.POST("/account/login", server.loginUser)
-	router.POST("/tokens/renew", server.renewAccessToken)
+	if err := router.SetTrustedProxies(trustedProxies(server.config)); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES:%w", err)
+	}
+	public := router.Group("/", rateLimit(server.publicLimiter))
+	public.POST("/account/", server.createUser)
+	public.POST("/account/login", server.loginUser)
+	public.POST("/account/login/2fa", server.loginTwoFactor)
+	public.POST("/tokens/renew", server.renewAccessToken)
+	public.POST("/account/verify", server.verifyEmail)
+	public.POST("/account/verify/resend", server.resendVerification)
+	public.POST("/account/password/forgot", server.forgotPassword)
+	public.POST("/account/password/reset", server.resetPassword)
+	public.GET("/auth/oidc/:provider/start", server.startOIDC)
+	public.GET("/auth/oidc/:provider/callback", server.oidcCallback)
+	router.GET(jwksPath, server.jwks)
+	router.GET("/.well-known/openid-configuration", server.discovery)
+
+	authRoutes := router.Group("/", authMWare(server.tokenMaker, server.revoker))
+	readRoutes := authRoutes.Group("/", requireScope(token.ScopeRead))
+	writeRoutes := authRoutes.Group("/", requireScope(token.ScopeWrite))
+	adminRoutes := authRoutes.Group("/admin", requireScope(token.ScopeAdmin))
+
+	// any token may end its own session
+	authRoutes.POST("/account/logout", server.logoutUser)
+
+	readRoutes.GET("/account/:id", server.getUser)
+	readRoutes.GET("/account/", server.listUser)
+	readRoutes.GET("/account/search", server.searchUsers)
+	readRoutes.GET("/account/sessions", server.listSessions)
+	writeRoutes.DELETE("/account/sessions/:id", server.deleteSession)
+	writeRoutes.PUT("/account/", server.updateUser)
+	writeRoutes.PUT("/account/password", server.changePassword)
+	writeRoutes.POST("/account/2fa/setup", server.setupTwoFactor)
+	writeRoutes.POST("/account/2fa/confirm", server.confirmTwoFactor)
 
-	authRoutes := router.Group("/").Use(authMWare(server.tokenMaker))
+	writeRoutes.POST("/message", server.sendMessage)
+	writeRoutes.PATCH("/message/:id", server.editMessage)
+	writeRoutes.DELETE("/message/:id", server.deleteMessage)
 
-	authRoutes.GET("/account/:id", server.getUser)
-	authRoutes.GET("/account/", server.listUser)
-	authRoutes.PUT("/account/", server.updateUser)
+	readRoutes.GET("/conversation", server.getConvos)
+	readRoutes.GET("/conversation/:id", server.detailConvo)
+	writeRoutes.POST("/conversation", server.createConvo)
+	writeRoutes.PATCH("/conversation/:id", server.updateConvo)
+	writeRoutes.DELETE("/conversation/:id", server.deleteConvo)
+	readRoutes.GET("/conversation/:id/events", server.streamConvEvents)
+	writeRoutes.POST("/conversation/:id/typing", server.sendTyping)
+	writeRoutes.POST("/conversation/:id/read", server.markConvRead)
+	writeRoutes.POST("/conversation/:id/members", server.addMember)
+	writeRoutes.DELETE("/conversation/:id/members/:user_id", server.removeMember)
+	writeRoutes.POST("/conversation/:id/leave", server.leaveConvo)
+	writeRoutes.GET("/dm/:user_id", server.openDM)
+	readRoutes.GET("/search/messages", server.searchMessages)
 
-	authRoutes.POST("/message", server.sendMessage)
+	readRoutes.GET("/ws", server.serveWS)
 
-	authRoutes.GET("/conversation", server.getConvos)
-	authRoutes.GET("/conversation/:id", server.detailConvo)
-	authRoutes.POST("/conversation", server.createConvo)
+	adminRoutes.PUT("/account/:id/role", server.updateUserRole)
 
 	server.router = router
+	return nil
 }
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rjriverac/messaging-server/mail"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenMaker(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, token.GenerateKeyFile(dir, "2024-01-01"))
+
+	testCases := []struct {
+		name      string
+		tokenType string
+		keyDir    string
+		check     func(t *testing.T, maker token.Maker, err error)
+	}{{
+		name: "Default",
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &token.PasetoMaker{}, maker)
+		},
+	}, {
+		name:      "Paseto Public",
+		tokenType: tokenTypePasetoPublic,
+		keyDir:    dir,
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &token.PasetoPublicMaker{}, maker)
+		},
+	}, {
+		name:      "JWT",
+		tokenType: tokenTypeJWT,
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &token.JWTMaker{}, maker)
+		},
+	}, {
+		name:      "JWT Public",
+		tokenType: tokenTypeJWT,
+		keyDir:    dir,
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &token.JWTPublicMaker{}, maker)
+		},
+	}, {
+		name:      "Missing Key Dir",
+		tokenType: tokenTypePasetoPublic,
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.Error(t, err)
+		},
+	}, {
+		name:      "Unknown",
+		tokenType: "saml",
+		check: func(t *testing.T, maker token.Maker, err error) {
+			require.Error(t, err)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			config := util.Config{
+				TokenType:         tc.tokenType,
+				TokenSymmetricKey: util.RandomString(32),
+				TokenKeyDir:       tc.keyDir,
+				TokenIssuer:       "https://chat.example.com",
+				TokenAudience:     "gateway",
+			}
+			maker, err := newTokenMaker(config)
+			tc.check(t, maker, err)
+			if err != nil {
+				return
+			}
+
+			accessToken, _, err := maker.CreateToken(1, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+			require.NoError(t, err)
+			payload, err := maker.VerifyToken(accessToken)
+			require.NoError(t, err)
+			require.Equal(t, config.TokenIssuer, payload.Issuer)
+			require.Equal(t, config.TokenAudience, payload.Audience)
+		})
+	}
+}
+
+func TestTokenIssuer(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config util.Config
+		want   string
+	}{{
+		name:   "Token Issuer",
+		config: util.Config{TokenIssuer: "messaging", AppURL: "https://chat.example.com"},
+		want:   "messaging",
+	}, {
+		name:   "App URL",
+		config: util.Config{AppURL: "https://chat.example.com/"},
+		want:   "https://chat.example.com",
+	}, {
+		name: "Neither",
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.TokenSymmetricKey = util.RandomString(32)
+			maker, err := newTokenMaker(tc.config)
+			require.NoError(t, err)
+
+			// discovery advertises exactly what the tokens carry
+			require.Equal(t, tc.want, tokenIssuer(tc.config))
+			accessToken, _, err := maker.CreateToken(1, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+			require.NoError(t, err)
+			payload, err := maker.VerifyToken(accessToken)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, payload.Issuer)
+		})
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "mail.log")
+
+	testCases := []struct {
+		name   string
+		config util.Config
+		check  func(t *testing.T, mailer mail.Mailer, err error)
+	}{{
+		name: "Default",
+		check: func(t *testing.T, mailer mail.Mailer, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &mail.LogMailer{}, mailer)
+		},
+	}, {
+		name:   "Log File",
+		config: util.Config{Mailer: mailerLog, MailLogFile: logFile},
+		check: func(t *testing.T, mailer mail.Mailer, err error) {
+			require.NoError(t, err)
+			require.NoError(t, mailer.Send(context.Background(), mail.Message{To: "user@example.com", Subject: "Hi"}))
+			data, err := os.ReadFile(logFile)
+			require.NoError(t, err)
+			require.Contains(t, string(data), "To: user@example.com")
+		},
+	}, {
+		name:   "SMTP",
+		config: util.Config{Mailer: mailerSMTP, SMTPAddr: "localhost:25"},
+		check: func(t *testing.T, mailer mail.Mailer, err error) {
+			require.NoError(t, err)
+			require.IsType(t, &mail.SMTPMailer{}, mailer)
+		},
+	}, {
+		name:   "SMTP Bad Addr",
+		config: util.Config{Mailer: mailerSMTP},
+		check: func(t *testing.T, mailer mail.Mailer, err error) {
+			require.Error(t, err)
+		},
+	}, {
+		name:   "Unknown",
+		config: util.Config{Mailer: "carrier-pigeon"},
+		check: func(t *testing.T, mailer mail.Mailer, err error) {
+			require.Error(t, err)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			mailer, err := newMailer(tc.config)
+			tc.check(t, mailer, err)
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		proxies []string
+		limited bool
+	}{{
+		// a client rotating X-Forwarded-For still shares one bucket
+		name:    "None Trusted",
+		limited: true,
+	}, {
+		name:    "Trusted Proxy",
+		proxies: []string{" 10.0.0.1 ", ""},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server, err := NewServer(util.Config{
+				TokenSymmetricKey: util.RandomString(32),
+				TrustedProxies:    tc.proxies,
+			}, nil)
+			require.NoError(t, err)
+
+			limited := false
+			for i := 0; i <= publicRateBurst; i++ {
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(http.MethodPost, "/tokens/renew", nil)
+				require.NoError(t, err)
+				request.RemoteAddr = "10.0.0.1:1234"
+				request.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d", i+1))
+				server.router.ServeHTTP(recorder, request)
+				limited = limited || recorder.Code == http.StatusTooManyRequests
+			}
+			require.Equal(t, tc.limited, limited)
+		})
+	}
+
+	_, err := NewServer(util.Config{
+		TokenSymmetricKey: util.RandomString(32),
+		TrustedProxies:    []string{"not an address"},
+	}, nil)
+	require.Error(t, err)
+}
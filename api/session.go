@@ -0,0 +1,122 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+type sessionReturn struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newSessionReturn(session db.Session) sessionReturn {
+	return sessionReturn{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// logoutUser blocks the session behind the caller's refresh token, along
+// with every session rotated from the same login, so it can no longer be
+// renewed, and revokes the access token used for the request.
+func (server *Server) logoutUser(ctx *gin.Context) {
+	var req logoutRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if !server.revokeSession(ctx, refreshPayload.ID, auth.User) {
+		return
+	}
+	if err := server.revoker.Revoke(ctx, auth); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (server *Server) listSessions(ctx *gin.Context) {
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+
+	sessions, err := server.store.ListActiveSessions(ctx, auth.User)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ret := []sessionReturn{}
+	for _, session := range sessions {
+		ret = append(ret, newSessionReturn(session))
+	}
+	ctx.JSON(http.StatusOK, ret)
+}
+
+type sessionIDRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+func (server *Server) deleteSession(ctx *gin.Context) {
+	var req sessionIDRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if server.revokeSession(ctx, uuid.MustParse(req.ID), auth.User) {
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// revokeSession blocks every session of the family id belongs to. Each
+// renewal replaces the session row, so an older refresh token may point at a
+// row that is already rotated away while its successor is still live. Only
+// sessions owned by userID are touched, anyone else's session looks like it
+// does not exist. It reports whether the sessions were blocked and has
+// already written the error response when they were not.
+func (server *Server) revokeSession(ctx *gin.Context, id uuid.UUID, userID int64) bool {
+	session, err := server.store.GetSession(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if session.UserID != userID {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return false
+	}
+
+	if err := server.store.BlockSessionFamily(ctx, session.FamilyID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	return true
+}
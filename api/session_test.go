@@ -0,0 +1,344 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomSession(userID int64) db.Session {
+	return db.Session{
+		ID:           uuid.New(),
+		Email:        util.RandomEmail(),
+		UserID:       userID,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    util.RandomString(12),
+		ClientIp:     "127.0.0.1",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		CreatedAt:    time.Now(),
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	user, _ := randomDBUser(t)
+	familyID := uuid.New()
+
+	testCases := []struct {
+		name       string
+		body       func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID)
+		buildStubs func(store *mockdb.MockStore, sessionID uuid.UUID)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			refresh, payload, err := maker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			return gin.H{"refresh_token": refresh}, payload.ID
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Eq(sessionID)).
+				Times(1).
+				Return(db.Session{ID: sessionID, UserID: user.ID, FamilyID: familyID}, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).Times(1).Return(nil)
+			store.EXPECT().DeleteExpiredRevokedTokens(gomock.Any()).Times(0)
+			store.EXPECT().
+				RevokeToken(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ interface{}, arg db.RevokeTokenParams) error {
+					// the access token of the request, not the refresh token
+					require.Equal(t, user.ID, arg.UserID)
+					require.NotEqual(t, sessionID, arg.ID)
+					return nil
+				})
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNoContent, recorder.Code)
+		},
+	}, {
+		name: "Other User's Session",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			refresh, payload, err := maker.CreateToken(user.ID+1, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			return gin.H{"refresh_token": refresh}, payload.ID
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Eq(sessionID)).
+				Times(1).
+				Return(db.Session{ID: sessionID, UserID: user.ID + 1, FamilyID: familyID}, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Rotated Away Token",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			refresh, payload, err := maker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			return gin.H{"refresh_token": refresh}, payload.ID
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			// the row of this token was replaced on renewal, its successor
+			// in the same family is the one still in use
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Eq(sessionID)).
+				Times(1).
+				Return(db.Session{
+					ID:        sessionID,
+					UserID:    user.ID,
+					FamilyID:  familyID,
+					RotatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+				}, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).Times(1).Return(nil)
+			store.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNoContent, recorder.Code)
+		},
+	}, {
+		name: "Revoke Err",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			refresh, payload, err := maker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			return gin.H{"refresh_token": refresh}, payload.ID
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Session{ID: sessionID, UserID: user.ID, FamilyID: familyID}, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			store.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}, {
+		name: "Invalid Token",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			return gin.H{"refresh_token": "bad"}, uuid.Nil
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}, {
+		name: "Bad Request",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			return gin.H{}, uuid.Nil
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}, {
+		name: "Int Server Err",
+		body: func(t *testing.T, maker token.Maker) (gin.H, uuid.UUID) {
+			refresh, payload, err := maker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			return gin.H{"refresh_token": refresh}, payload.ID
+		},
+		buildStubs: func(store *mockdb.MockStore, sessionID uuid.UUID) {
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Session{}, sql.ErrConnDone)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, sessionID := tc.body(t, server.tokenMaker)
+			tc.buildStubs(store, sessionID)
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/account/logout", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	user, _ := randomDBUser(t)
+	sessions := []db.Session{randomSession(user.ID), randomSession(user.ID)}
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				ListActiveSessions(gomock.Any(), gomock.Eq(user.ID)).
+				Times(1).
+				Return(sessions, nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			// refresh tokens must never be listed
+			require.NotContains(t, string(data), sessions[0].RefreshToken)
+
+			var got []sessionReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Len(t, got, len(sessions))
+			for i, session := range got {
+				require.Equal(t, sessions[i].ID, session.ID)
+				require.Equal(t, sessions[i].UserAgent, session.UserAgent)
+				require.Equal(t, sessions[i].ClientIp, session.ClientIP)
+				require.WithinDuration(t, sessions[i].CreatedAt, session.CreatedAt, time.Second)
+			}
+		},
+	}, {
+		name: "Int Server Err",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				ListActiveSessions(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(nil, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/account/sessions", nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	user, _ := randomDBUser(t)
+	session := randomSession(user.ID)
+	session.FamilyID = uuid.New()
+
+	testCases := []struct {
+		name       string
+		id         string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		id:   session.ID.String(),
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).Times(1).Return(nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNoContent, recorder.Code)
+		},
+	}, {
+		name: "Not Found",
+		id:   session.ID.String(),
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Session{}, sql.ErrNoRows)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Other User's Session",
+		id:   session.ID.String(),
+		buildStubs: func(store *mockdb.MockStore) {
+			other := session
+			other.UserID = user.ID + 1
+			store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(other, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Bad ID",
+		id:   "not-a-uuid",
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/account/sessions/%s", tc.id)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, user.ID, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
 )
 
 type renewAccessTokenRequest struct {
@@ -14,10 +17,16 @@ type renewAccessTokenRequest struct {
 }
 
 type renewAccessTokenResponse struct {
-	AccessToken          string    `json:"access_token"`
-	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	SessionID             uuid.UUID `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
 }
 
+// renewAccessToken rotates the refresh token on every use. Presenting a
+// refresh token that was already rotated means it leaked, so every session
+// descended from the same login is blocked.
 func (server *Server) renewAccessToken(ctx *gin.Context) {
 	var req renewAccessTokenRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -30,6 +39,16 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
+	// revoking a user ends their refresh tokens too, not just access tokens
+	revoked, err := server.revoker.IsRevoked(ctx, refreshPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if revoked {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(token.ErrRevokedToken))
+		return
+	}
 
 	session, err := server.store.GetSession(ctx, refreshPayload.ID)
 	if err != nil {
@@ -58,14 +77,28 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		return
 	}
 
+	if session.RotatedAt.Valid {
+		server.blockSessionFamily(ctx, session.FamilyID)
+		return
+	}
+
 	if time.Now().After(session.ExpiresAt) {
 		err := fmt.Errorf("expired session")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
+	// renewed tokens keep the grants of the login they descend from, refresh
+	// tokens minted before roles existed had full user access
+	role, scopes := refreshPayload.Role, refreshPayload.Scopes
+	if role == "" {
+		role, scopes = token.RoleUser, token.ScopesForRole(token.RoleUser)
+	}
+
 	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
 		refreshPayload.User,
+		role,
+		scopes,
 		server.config.AccessTokenDuration,
 	)
 	if err != nil {
@@ -73,10 +106,57 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		return
 	}
 
+	refreshToken, nextPayload, err := server.tokenMaker.CreateToken(
+		refreshPayload.User,
+		role,
+		scopes,
+		server.config.RefreshTokenDuration,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the new row keeps the login time of its family, the session list shows
+	// when the user signed in rather than when the client last renewed
+	next, err := server.store.RotateSessionTx(ctx, db.RotateSessionParams{
+		OldID: session.ID,
+		Next: db.CreateSessionParams{
+			ID:           nextPayload.ID,
+			Email:        session.Email,
+			UserID:       session.UserID,
+			RefreshToken: refreshToken,
+			UserAgent:    ctx.Request.UserAgent(),
+			ClientIp:     ctx.ClientIP(),
+			ExpiresAt:    nextPayload.Expires,
+			CreatedAt:    session.CreatedAt,
+		},
+	})
+	if err != nil {
+		// lost a race against another renewal with the same token
+		if err == db.ErrSessionReused {
+			server.blockSessionFamily(ctx, session.FamilyID)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	res := renewAccessTokenResponse{
-		AccessToken:          accessToken,
-		AccessTokenExpiresAt: accessPayload.Expires,
+		SessionID:             next.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.Expires,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: nextPayload.Expires,
 	}
 	ctx.JSON(http.StatusOK, res)
 
 }
+
+func (server *Server) blockSessionFamily(ctx *gin.Context, familyID uuid.UUID) {
+	if err := server.store.BlockSessionFamily(ctx, familyID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(db.ErrSessionReused))
+}
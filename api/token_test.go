@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewAccessToken(t *testing.T) {
+	user, _ := randomDBUser(t)
+	familyID := uuid.New()
+
+	// sessionFor builds the stored session matching a freshly minted refresh token
+	sessionFor := func(refresh string, payload *token.Payload) db.Session {
+		return db.Session{
+			ID:           payload.ID,
+			Email:        user.Email,
+			UserID:       user.ID,
+			RefreshToken: refresh,
+			ExpiresAt:    payload.Expires,
+			CreatedAt:    payload.CreatedAt,
+			FamilyID:     familyID,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore, session db.Session)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name: "OK",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			// a family that has rotated before still dates from its login
+			session.CreatedAt = session.CreatedAt.Add(-time.Hour)
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().
+				RotateSessionTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ interface{}, arg db.RotateSessionParams) (db.Session, error) {
+					require.Equal(t, session.ID, arg.OldID)
+					require.Equal(t, user.ID, arg.Next.UserID)
+					require.NotEqual(t, session.ID, arg.Next.ID)
+					require.NotEqual(t, session.RefreshToken, arg.Next.RefreshToken)
+					require.Equal(t, session.CreatedAt, arg.Next.CreatedAt)
+					return db.Session{ID: arg.Next.ID, FamilyID: familyID}, nil
+				})
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got renewAccessTokenResponse
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.NotEmpty(t, got.AccessToken)
+			require.NotEmpty(t, got.RefreshToken)
+			require.NotEqual(t, uuid.Nil, got.SessionID)
+		},
+	}, {
+		name: "Reused Token",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			session.RotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().RotateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).Times(1).Return(nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}, {
+		name: "Concurrent Reuse",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().
+				RotateSessionTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Session{}, db.ErrSessionReused)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).Times(1).Return(nil)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}, {
+		name: "Blocked Session",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			session.IsBlocked = true
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().RotateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}, {
+		name: "Mismatched Token",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			session.RefreshToken = "other"
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		},
+	}, {
+		name: "Session Not Found",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, sql.ErrNoRows)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Rotate Err",
+		buildStubs: func(store *mockdb.MockStore, session db.Session) {
+			store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+			store.EXPECT().
+				RotateSessionTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(db.Session{}, sql.ErrConnDone)
+		},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusInternalServerError, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			refresh, payload, err := server.tokenMaker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+			require.NoError(t, err)
+			tc.buildStubs(store, sessionFor(refresh, payload))
+
+			data, err := json.Marshal(gin.H{"refresh_token": refresh})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/tokens/renew", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestRenewAfterUserRevoked(t *testing.T) {
+	user, _ := randomDBUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	refresh, _, err := server.tokenMaker.CreateToken(user.ID, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Hour)
+	require.NoError(t, err)
+
+	// a password change or logout everywhere leaves the session row alone
+	store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	require.NoError(t, server.revoker.RevokeUser(context.Background(), user.ID, time.Now()))
+	store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().RotateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+
+	data, err := json.Marshal(gin.H{"refresh_token": refresh})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/tokens/renew", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const (
+	totpIssuer = "messaging-server"
+	totpPeriod = 30
+	// codes from one step either side are accepted for clock drift
+	totpSkew = 1
+
+	mfaChallengeDuration = 5 * time.Minute
+	recoveryCodeCount    = 10
+	recoveryCodeBytes    = 5
+)
+
+var (
+	errTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	errBadMFACode       = errors.New("invalid authentication code")
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// matchTOTP reports the time step code belongs to. Steps at or before
+// lastUsed are refused so a code cannot be replayed within its window.
+func matchTOTP(secret, code string, lastUsed int64, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsed {
+			continue
+		}
+		want, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newRecoveryCodes returns codes to show the user once and the hashes to
+// store in their place.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(raw))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed the
+// way they read.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func mfaKey(userID int64) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
+
+type setupTwoFactorRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type setupTwoFactorResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// setupTwoFactor starts enrollment with a fresh secret. Login is unaffected
+// until confirmTwoFactor proves the authenticator app has it, calling setup
+// again before that replaces the secret. Both steps need the current password
+// so a stolen access token cannot enroll an authenticator of its own.
+func (server *Server) setupTwoFactor(ctx *gin.Context) {
+	var req setupTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if !server.requirePassword(ctx, auth.User, req.CurrentPassword) {
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, auth.User)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.StartUserTOTP(ctx, db.StartUserTOTPParams{UserID: user.ID, Secret: key.Secret()})
+	if err != nil {
+		// the upsert skips confirmed rows
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusForbidden, errorResponse(errTwoFactorEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, setupTwoFactorResponse{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+	})
+}
+
+type confirmTwoFactorRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Code            string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type confirmTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTwoFactor turns two-factor on once a code from the new secret is
+// presented, and hands out the recovery codes. They are never shown again.
+func (server *Server) confirmTwoFactor(ctx *gin.Context) {
+	var req confirmTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+	if !server.requirePassword(ctx, auth.User, req.CurrentPassword) {
+		return
+	}
+
+	pending, err := server.store.GetUserTOTP(ctx, auth.User)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, errorResponse(db.ErrTOTPNotPending))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if pending.ConfirmedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errTwoFactorEnabled))
+		return
+	}
+	step, ok := matchTOTP(pending.Secret, req.Code, pending.LastUsedStep, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errBadMFACode))
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	_, err = server.store.ConfirmTOTPTx(ctx, db.ConfirmTOTPParams{
+		UserID:     auth.User,
+		Step:       step,
+		CodeHashes: hashes,
+	})
+	if err != nil {
+		if err == db.ErrTOTPNotPending {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTwoFactorResponse{RecoveryCodes: codes})
+}
+
+type mfaChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"mfa_token_expires_at"`
+}
+
+// mfaChallenge answers a correct password for an account with two-factor on.
+// The token is not an access token, it only lets loginTwoFactor finish.
+func (server *Server) mfaChallenge(ctx *gin.Context, userID int64) {
+	expires := time.Now().Add(mfaChallengeDuration)
+	challenge, err := server.signer.Sign(token.PurposeMFAChallenge, strconv.FormatInt(userID, 10), mfaChallengeDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, mfaChallengeResponse{
+		MFARequired: true,
+		MFAToken:    challenge,
+		ExpiresAt:   expires,
+	})
+}
+
+type loginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is either the current TOTP code or an unused recovery code.
+	Code string `json:"code" binding:"required"`
+}
+
+// loginTwoFactor completes a login that mfaChallenge interrupted.
+func (server *Server) loginTwoFactor(ctx *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	subject, err := server.signer.Verify(token.PurposeMFAChallenge, req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	userID, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(token.ErrInvalidToken))
+		return
+	}
+
+	key := mfaKey(userID)
+	if wait := server.accountGuard.retryAfter(key); wait > 0 {
+		tooManyRequests(ctx, wait)
+		return
+	}
+
+	ok, err := server.checkSecondFactor(ctx, userID, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !ok {
+		server.accountGuard.fail(key)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errBadMFACode))
+		return
+	}
+	server.accountGuard.reset(key)
+
+	user, err := server.store.GetUser(ctx, userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.issueLogin(ctx, userFromRow(user))
+}
+
+// userFromRow widens a GetUser row to a User, without the password hash.
+func userFromRow(row db.GetUserRow) db.User {
+	return db.User{
+		ID:              row.ID,
+		Name:            row.Name,
+		Email:           row.Email,
+		Image:           row.Image,
+		Status:          row.Status,
+		CreatedAt:       row.CreatedAt,
+		HideEmail:       row.HideEmail,
+		Role:            row.Role,
+		EmailVerifiedAt: row.EmailVerifiedAt,
+	}
+}
+
+// checkSecondFactor spends code as a TOTP code when it looks like one and as
+// a recovery code otherwise.
+func (server *Server) checkSecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		secret, err := server.store.GetUserTOTP(ctx, userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			return false, err
+		}
+		if !secret.ConfirmedAt.Valid {
+			return false, nil
+		}
+		step, ok := matchTOTP(secret.Secret, code, secret.LastUsedStep, time.Now())
+		if !ok {
+			return false, nil
+		}
+		// a concurrent login with the same code loses this race
+		_, err = server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{UserID: userID, LastUsedStep: step})
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	_, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: hashRecoveryCode(code),
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,589 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/pquerna/otp/totp"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/stretchr/testify/require"
+)
+
+func randomTOTPSecret(t *testing.T) string {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: "user@example.com"})
+	require.NoError(t, err)
+	return key.Secret()
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := totp.GenerateCode(secret, at)
+	require.NoError(t, err)
+	return code
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := randomTOTPSecret(t)
+	now := time.Now()
+	current := now.Unix() / totpPeriod
+
+	step, ok := matchTOTP(secret, totpCode(t, secret, now), 0, now)
+	require.True(t, ok)
+	require.Equal(t, current, step)
+
+	// one step of drift either way is tolerated
+	step, ok = matchTOTP(secret, totpCode(t, secret, now.Add(-totpPeriod*time.Second)), 0, now)
+	require.True(t, ok)
+	require.Equal(t, current-1, step)
+
+	_, ok = matchTOTP(secret, totpCode(t, secret, now.Add(-5*time.Minute)), 0, now)
+	require.False(t, ok)
+
+	// a step that was already used cannot be used again
+	_, ok = matchTOTP(secret, totpCode(t, secret, now), current, now)
+	require.False(t, ok)
+
+	_, ok = matchTOTP(secret, "abcdef", 0, now)
+	require.False(t, ok)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Len(t, hashes, recoveryCodeCount)
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		require.Len(t, code, 9)
+		require.Equal(t, "-", code[4:5])
+		require.False(t, seen[code])
+		seen[code] = true
+
+		require.Equal(t, hashes[i], hashRecoveryCode(code))
+		// typed without the dash or in capitals still matches
+		require.Equal(t, hashes[i], hashRecoveryCode(strings.ToUpper(strings.Replace(code, "-", " ", 1))))
+	}
+}
+
+func serveAuthed(t *testing.T, server *Server, method, path string, body gin.H, userID int64) *httptest.ResponseRecorder {
+	marshalled, err := json.Marshal(body)
+	require.NoError(t, err)
+	request, err := http.NewRequest(method, path, bytes.NewReader(marshalled))
+	require.NoError(t, err)
+	if userID != 0 {
+		addAuth(t, request, server.tokenMaker, authTypeBearer, userID, time.Minute)
+	}
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestSetupTwoFactor(t *testing.T) {
+	user, password := randomDBUser(t)
+	row := db.GetUserRow{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role}
+
+	testCases := []struct {
+		name       string
+		userID     int64
+		password   string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			userID:   user.ID,
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().
+					StartUserTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.StartUserTOTPParams) (db.UserTotp, error) {
+						require.Equal(t, user.ID, arg.UserID)
+						require.NotEmpty(t, arg.Secret)
+						return db.UserTotp{UserID: arg.UserID, Secret: arg.Secret}, nil
+					})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got setupTwoFactorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.NotEmpty(t, got.Secret)
+				require.True(t, strings.HasPrefix(got.URI, "otpauth://totp/"))
+				require.Contains(t, got.URI, "secret="+got.Secret)
+				require.Contains(t, got.URI, "issuer="+totpIssuer)
+			},
+		},
+		{
+			name:     "Already Enabled",
+			userID:   user.ID,
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().
+					StartUserTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errTwoFactorEnabled.Error())
+			},
+		},
+		{
+			name:     "User Not Found",
+			userID:   user.ID,
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.GetUserRow{}, sql.ErrNoRows)
+				store.EXPECT().StartUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "Internal Err",
+			userID:   user.ID,
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(row, nil)
+				store.EXPECT().
+					StartUserTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:     "Wrong Password",
+			userID:   user.ID,
+			password: "wrong-password-1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().StartUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errWrongPassword.Error())
+			},
+		},
+		{
+			name:   "Missing Password",
+			userID: user.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().StartUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "No Auth",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := serveAuthed(t, server, http.MethodPost, "/account/2fa/setup", gin.H{"current_password": tc.password}, tc.userID)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	user, password := randomDBUser(t)
+	secret := randomTOTPSecret(t)
+	pending := db.UserTotp{UserID: user.ID, Secret: secret}
+	confirmed := pending
+	confirmed.ConfirmedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name       string
+		password   string
+		code       func(t *testing.T) string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			password: password,
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ConfirmTOTPParams) (db.UserTotp, error) {
+						require.Equal(t, user.ID, arg.UserID)
+						require.InDelta(t, time.Now().Unix()/totpPeriod, arg.Step, 1)
+						require.Len(t, arg.CodeHashes, recoveryCodeCount)
+						return confirmed, nil
+					})
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got confirmTwoFactorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Len(t, got.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			name:     "Wrong Code",
+			password: password,
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now().Add(-time.Hour)) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(pending, nil)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errBadMFACode.Error())
+			},
+		},
+		{
+			name:     "Not Started",
+			password: password,
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "Already Enabled",
+			password: password,
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(confirmed, nil)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "Not Numeric",
+			password: password,
+			code:     func(t *testing.T) string { return "12ab56" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "Tx Err",
+			password: password,
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:     "Wrong Password",
+			password: "wrong-password-1",
+			code:     func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPassword(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user.HashedPw, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errWrongPassword.Error())
+			},
+		},
+		{
+			name: "Missing Password",
+			code: func(t *testing.T) string { return totpCode(t, secret, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserPassword(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := serveAuthed(t, server, http.MethodPost, "/account/2fa/confirm", gin.H{"current_password": tc.password, "code": tc.code(t)}, user.ID)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	user, _ := randomDBUser(t)
+	row := db.GetUserRow{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		CreatedAt:       user.CreatedAt,
+		Role:            token.RoleUser,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+	}
+	secret := randomTOTPSecret(t)
+	enabled := db.UserTotp{
+		UserID:      user.ID,
+		Secret:      secret,
+		ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	recoveryCode := "abcd-efgh"
+
+	challenge := func(t *testing.T, server *Server) string {
+		code, err := server.signer.Sign(token.PurposeMFAChallenge, strconv.FormatInt(user.ID, 10), time.Minute)
+		require.NoError(t, err)
+		return code
+	}
+
+	testCases := []struct {
+		name       string
+		body       func(t *testing.T, server *Server) gin.H
+		buildStubs func(store *mockdb.MockStore)
+		setup      func(server *Server)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "TOTP OK",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": totpCode(t, secret, time.Now())}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(enabled, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UseTOTPStepParams) (db.UserTotp, error) {
+						require.Equal(t, user.ID, arg.UserID)
+						require.InDelta(t, time.Now().Unix()/totpPeriod, arg.LastUsedStep, 1)
+						return enabled, nil
+					})
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.NotEmpty(t, got.AccessToken)
+				require.NotEmpty(t, got.RefreshToken)
+				require.Equal(t, user.ID, got.User.ID)
+			},
+		},
+		{
+			name: "Recovery Code OK",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": strings.ToUpper(recoveryCode)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{UserID: user.ID, CodeHash: hashRecoveryCode(recoveryCode)})).
+					Times(1).
+					Return(db.RecoveryCode{UserID: user.ID}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(row, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Used Recovery Code",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": recoveryCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RecoveryCode{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errBadMFACode.Error())
+			},
+		},
+		{
+			name: "Replayed TOTP",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": totpCode(t, secret, time.Now())}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(enabled, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong TOTP",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": totpCode(t, secret, time.Now().Add(-time.Hour))}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(enabled, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Bad Challenge",
+			body: func(t *testing.T, server *Server) gin.H {
+				// an email verification code is no challenge
+				code, err := server.signer.Sign(token.PurposeVerifyEmail, strconv.FormatInt(user.ID, 10), time.Minute)
+				require.NoError(t, err)
+				return gin.H{"mfa_token": code, "code": totpCode(t, secret, time.Now())}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Locked Out",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": totpCode(t, secret, time.Now())}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			setup: func(server *Server) {
+				for i := 0; i <= accountFreeFailures; i++ {
+					server.accountGuard.fail(mfaKey(user.ID))
+				}
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			name: "Internal Err",
+			body: func(t *testing.T, server *Server) gin.H {
+				return gin.H{"mfa_token": challenge(t, server), "code": totpCode(t, secret, time.Now())}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			if tc.setup != nil {
+				tc.setup(server)
+			}
+			recorder := postJSON(t, server, "/account/login/2fa", tc.body(t, server))
+			tc.checkRes(t, recorder)
+		})
+	}
+}
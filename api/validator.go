@@ -5,12 +5,21 @@ import "github.com/go-playground/validator/v10"
 var validRequest validator.StructLevelFunc = func(sl validator.StructLevel) {
 	info := sl.Current().Interface().(UpdateUserRequest)
 
-	if len(info.Name) == 0 && len(info.Email) == 0 && len(info.Password) == 0 && len(info.Image) == 0 && len(info.Status) == 0 {
+	if len(info.Name) == 0 && len(info.Email) == 0 && len(info.Image) == 0 && len(info.Status) == 0 && info.HideEmail == nil {
 		sl.ReportError(info.Name, "name", "name", "empty request", "")
-		sl.ReportError(info.Password, "Password", "empty request", "name", "")
 		sl.ReportError(info.Image, "Image", "Image", "empty request", "")
 		sl.ReportError(info.Email, "Email", "Email", "empty request", "")
 		sl.ReportError(info.Status, "Status", "Status", "empty request", "")
 	}
 
 }
+
+var validConvRequest validator.StructLevelFunc = func(sl validator.StructLevel) {
+	info := sl.Current().Interface().(updateConvRequest)
+
+	if len(info.Name) == 0 && len(info.Topic) == 0 && len(info.Description) == 0 {
+		sl.ReportError(info.Name, "name", "name", "empty request", "")
+		sl.ReportError(info.Topic, "topic", "topic", "empty request", "")
+		sl.ReportError(info.Description, "description", "description", "empty request", "")
+	}
+}
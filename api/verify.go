@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const (
+	emailVerifyDuration = 24 * time.Hour
+	mailTimeout         = 10 * time.Second
+)
+
+var (
+	errEmailNotVerified = errors.New("email address has not been verified")
+	errVerificationUsed = errors.New("verification code has already been used")
+)
+
+// sendVerification mails a code binding id to email. The code stops working
+// once the address is verified or changed, which makes it single use.
+func (server *Server) sendVerification(ctx context.Context, id int64, email string) error {
+	code, err := server.signer.Sign(token.PurposeVerifyEmail, fmt.Sprintf("%d:%s", id, email), emailVerifyDuration)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(server.config.AppURL, "/"), url.QueryEscape(code))
+
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+	return server.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm this address by opening the link below, it is valid for %s.\n\n%s\n\n"+
+			"If you did not sign up you can ignore this message.", emailVerifyDuration, link),
+	})
+}
+
+func parseVerifySubject(subject string) (int64, string, error) {
+	rawID, email, ok := strings.Cut(subject, ":")
+	if !ok {
+		return 0, "", token.ErrInvalidToken
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return 0, "", token.ErrInvalidToken
+	}
+	return id, email, nil
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	subject, err := server.signer.Verify(token.PurposeVerifyEmail, req.Token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	id, email, err := parseVerifySubject(subject)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: id, Email: email})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errVerificationUsed))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, newUserReturn(user))
+}
+
+// sendVerificationAsync mails the code after the response, a slow mail server
+// neither holds up the request nor shows in how long it took. Failures are
+// only logged, a lost mail can be sent again.
+func (server *Server) sendVerificationAsync(id int64, email string) {
+	server.runAsync(func(ctx context.Context) {
+		if err := server.sendVerification(ctx, id, email); err != nil {
+			log.Printf("cannot send verification email to user %d: %v", id, err)
+		}
+	})
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// resendVerification answers the same whether or not the address belongs to
+// an unverified account, so it cannot be used to look up users.
+func (server *Server) resendVerification(ctx *gin.Context) {
+	var req resendVerificationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// mailed after the response so an unverified account does not answer
+	// any slower than an unknown address
+	if err == nil && !user.EmailVerifiedAt.Valid {
+		server.sendVerificationAsync(user.ID, user.Email)
+	}
+	ctx.Status(http.StatusAccepted)
+}
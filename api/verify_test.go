@@ -0,0 +1,309 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/mail"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/stretchr/testify/require"
+)
+
+var verifyLink = regexp.MustCompile(`verify-email\?token=(\S+)`)
+
+// mailedCode pulls the verification code out of what a log mailer wrote.
+func mailedCode(t *testing.T, out *bytes.Buffer) string {
+	match := verifyLink.FindStringSubmatch(out.String())
+	require.Len(t, match, 2)
+	code, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return code
+}
+
+func postJSON(t *testing.T, server *Server, path string, body gin.H) *httptest.ResponseRecorder {
+	marshalled, err := json.Marshal(body)
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(marshalled))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCreateUserSendsVerification(t *testing.T) {
+	user, password := randomDBUser(t)
+	user.EmailVerifiedAt = sql.NullTime{}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(user, nil)
+
+	server := newTestServer(t, store)
+	var out bytes.Buffer
+	server.mailer = mail.NewLogMailer(&out, "no-reply@example.com")
+
+	recorder := postJSON(t, server, "/account/", gin.H{
+		"name":     user.Name,
+		"email":    user.Email,
+		"password": password,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var got userReturn
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+	require.False(t, got.EmailVerified)
+
+	// the mail goes out after the response
+	server.tasks.Wait()
+	require.Contains(t, out.String(), "To: "+user.Email)
+	subject, err := server.signer.Verify(token.PurposeVerifyEmail, mailedCode(t, &out))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d:%s", user.ID, user.Email), subject)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	user, _ := randomDBUser(t)
+	verified := user
+	verified.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	sign := func(t *testing.T, server *Server, subject string, duration time.Duration) string {
+		code, err := server.signer.Sign(token.PurposeVerifyEmail, subject, duration)
+		require.NoError(t, err)
+		return code
+	}
+	subject := fmt.Sprintf("%d:%s", user.ID, user.Email)
+
+	testCases := []struct {
+		name       string
+		code       func(t *testing.T, server *Server) string
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(t *testing.T, server *Server) string {
+				return sign(t, server, subject, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkEmailVerified(gomock.Any(), gomock.Eq(db.MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})).
+					Times(1).
+					Return(verified, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got userReturn
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, user.ID, got.ID)
+				require.True(t, got.EmailVerified)
+			},
+		},
+		{
+			name: "Already Used",
+			code: func(t *testing.T, server *Server) string {
+				return sign(t, server, subject, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkEmailVerified(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errVerificationUsed.Error())
+			},
+		},
+		{
+			name: "Expired",
+			code: func(t *testing.T, server *Server) string {
+				return sign(t, server, subject, -time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Wrong Purpose",
+			code: func(t *testing.T, server *Server) string {
+				code, err := server.signer.Sign("reset-password", subject, time.Minute)
+				require.NoError(t, err)
+				return code
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Bad Subject",
+			code: func(t *testing.T, server *Server) string {
+				return sign(t, server, user.Email, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Internal Err",
+			code: func(t *testing.T, server *Server) string {
+				return sign(t, server, subject, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkEmailVerified(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Missing Token",
+			code: func(t *testing.T, server *Server) string {
+				return ""
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := postJSON(t, server, "/account/verify", gin.H{"token": tc.code(t, server)})
+			tc.checkRes(t, recorder)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	user, _ := randomDBUser(t)
+	unverified := user
+	unverified.EmailVerifiedAt = sql.NullTime{}
+
+	testCases := []struct {
+		name       string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		checkRes   func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer)
+	}{
+		{
+			name: "Unverified",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(unverified, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Contains(t, mailed.String(), "To: "+user.Email)
+			},
+		},
+		{
+			name: "Already Verified",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Zero(t, mailed.Len())
+			},
+		},
+		{
+			name: "Unknown Email",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer) {
+				// same answer as for a real account
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Zero(t, mailed.Len())
+			},
+		},
+		{
+			name: "Internal Err",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "Bad Request",
+			body: gin.H{"email": "not-an-email"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder, mailed *bytes.Buffer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			var mailed bytes.Buffer
+			server.mailer = mail.NewLogMailer(&mailed, "no-reply@example.com")
+
+			recorder := postJSON(t, server, "/account/verify/resend", tc.body)
+			server.tasks.Wait()
+			tc.checkRes(t, recorder, &mailed)
+		})
+	}
+}
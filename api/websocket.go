@@ -0,0 +1,91 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 512
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+func (server *Server) serveWS(ctx *gin.Context) {
+	auth := ctx.MustGet(authPayloadKey).(*token.Payload)
+
+	// subscribe before the handshake completes so nothing published after
+	// the client sees the upgrade response can be missed
+	sub := server.hub.subscribe(auth.User, 0)
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// the upgrader has already replied with an http error
+		server.hub.unsubscribe(sub)
+		return
+	}
+
+	go server.wsWritePump(conn, sub)
+	go server.wsReadPump(conn, sub)
+}
+
+// wsReadPump only exists to process control frames; anything the client
+// sends is discarded. It owns unsubscribing when the client goes away.
+func (server *Server) wsReadPump(conn *websocket.Conn, sub *subscriber) {
+	defer func() {
+		server.hub.unsubscribe(sub)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func (server *Server) wsWritePump(conn *websocket.Conn, sub *subscriber) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-sub.send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// hub dropped us or is shutting down
+				conn.WriteMessage(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server closing connection"),
+				)
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	mockdb "github.com/rjriverac/messaging-server/db/mock"
+	db "github.com/rjriverac/messaging-server/db/sqlc"
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/stretchr/testify/require"
+)
+
+func dialWS(t *testing.T, server *Server, url string, id int64) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if id != 0 {
+		accessToken, _, err := server.tokenMaker.CreateToken(id, token.RoleUser, token.ScopesForRole(token.RoleUser), time.Minute)
+		require.NoError(t, err)
+		header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authTypeBearer, accessToken))
+	}
+	wsURL := "ws" + strings.TrimPrefix(url, "http") + "/ws"
+	return websocket.DefaultDialer.Dial(wsURL, header)
+}
+
+func TestServeWSAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	_, res, err := dialWS(t, server, ts.URL, 0)
+	require.Error(t, err)
+	require.NotNil(t, res)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestServeWSDelivery(t *testing.T) {
+	user, _ := randomDBUser(t)
+	other, _ := randomDBUser(t)
+	msgParams := randomMsgParams()
+	result := randomSendResult()
+	result.ConvID = msgParams.ConvID
+
+	testCases := []struct {
+		name       string
+		userID     int64
+		buildStubs func(store *mockdb.MockStore)
+		checkConn  func(t *testing.T, conn *websocket.Conn)
+	}{
+		{
+			name:   "member receives message",
+			userID: user.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SendMessage(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(result, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(result.ConvID)).
+					Times(1).
+					Return([]int64{user.ID, other.ID}, nil)
+			},
+			checkConn: func(t *testing.T, conn *websocket.Conn) {
+				conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+				var event struct {
+					Type   string        `json:"type"`
+					ConvID int64         `json:"conv_id"`
+					Data   db.SendResult `json:"data"`
+				}
+				require.NoError(t, conn.ReadJSON(&event))
+				require.Equal(t, EventMessage, event.Type)
+				require.Equal(t, result.ConvID, event.ConvID)
+				require.Equal(t, result.MsgID, event.Data.MsgID)
+				require.Equal(t, result.Content, event.Data.Content)
+			},
+		},
+		{
+			name:   "non member receives nothing",
+			userID: user.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SendMessage(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(result, nil)
+				store.EXPECT().
+					ListConvMembers(gomock.Any(), gomock.Eq(result.ConvID)).
+					Times(1).
+					Return([]int64{other.ID}, nil)
+			},
+			checkConn: func(t *testing.T, conn *websocket.Conn) {
+				conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+				_, _, err := conn.ReadMessage()
+				require.Error(t, err)
+				netErr, ok := err.(interface{ Timeout() bool })
+				require.True(t, ok)
+				require.True(t, netErr.Timeout())
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			ts := httptest.NewServer(server.router)
+			defer ts.Close()
+
+			conn, _, err := dialWS(t, server, ts.URL, tc.userID)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			marshalled, err := json.Marshal(gin.H{
+				"content": msgParams.Content,
+				"convID":  msgParams.ConvID,
+			})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/message", bytes.NewReader(marshalled))
+			require.NoError(t, err)
+			addAuth(t, request, server.tokenMaker, authTypeBearer, other.ID, time.Minute)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusAccepted, recorder.Code)
+
+			tc.checkConn(t, conn)
+		})
+	}
+}
+
+func TestServeWSShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	conn, _, err := dialWS(t, server, ts.URL, 8)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	server.hub.Stop()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
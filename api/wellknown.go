@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rjriverac/messaging-server/token"
+)
+
+const jwksPath = "/.well-known/jwks.json"
+
+var (
+	errNoPublicKeys = errors.New("tokens are not signed with public keys")
+	errNoAppURL     = errors.New("APP_URL is not configured")
+)
+
+// jwks publishes the keys downstream services verify tokens with. Makers
+// using a shared secret have nothing to publish.
+func (server *Server) jwks(ctx *gin.Context) {
+	publisher, ok := server.tokenMaker.(token.KeyPublisher)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errorResponse(errNoPublicKeys))
+		return
+	}
+	// verifiers may cache keys briefly, a rotated key is added well before
+	// it starts signing
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, publisher.JWKS())
+}
+
+type discoveryReturn struct {
+	Issuer          string   `json:"issuer"`
+	JWKSURI         string   `json:"jwks_uri"`
+	SigningAlgs     []string `json:"id_token_signing_alg_values_supported"`
+	SubjectTypes    []string `json:"subject_types_supported"`
+	ClaimsSupported []string `json:"claims_supported"`
+}
+
+// discovery is an OpenID-style configuration document pointing verifiers at
+// the key set. Only the parts that make sense for our tokens are filled in.
+// The issuer is the one the makers put in the iss claim, which may be a
+// logical name, so the key set URL is built from APP_URL instead. Neither
+// comes from the request, whose Host and proxy headers the client controls.
+func (server *Server) discovery(ctx *gin.Context) {
+	publisher, ok := server.tokenMaker.(token.KeyPublisher)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errorResponse(errNoPublicKeys))
+		return
+	}
+	appURL := strings.TrimRight(server.config.AppURL, "/")
+	if appURL == "" {
+		ctx.JSON(http.StatusNotFound, errorResponse(errNoAppURL))
+		return
+	}
+	ctx.JSON(http.StatusOK, discoveryReturn{
+		Issuer:          tokenIssuer(server.config),
+		JWKSURI:         appURL + jwksPath,
+		SigningAlgs:     publisher.Algorithms(),
+		SubjectTypes:    []string{"public"},
+		ClaimsSupported: []string{"uuid", "user_id", "role", "scopes", "iss", "aud", "sub", "iat", "nbf", "exp", "created_at", "expires"},
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rjriverac/messaging-server/token"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, token.GenerateKeyFile(dir, "2024-01-01"))
+	keys, err := token.LoadKeyDir(dir)
+	require.NoError(t, err)
+	publicMaker, err := token.NewJWTPublicMaker(keys)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		maker    token.Maker
+		path     string
+		config   util.Config
+		checkRes func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{{
+		name:  "Key Set",
+		maker: publicMaker,
+		path:  jwksPath,
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got token.JWKSet
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Len(t, got.Keys, 1)
+			require.Equal(t, "2024-01-01", got.Keys[0].Kid)
+			require.Equal(t, "EdDSA", got.Keys[0].Alg)
+			// only the public half is published
+			require.NotContains(t, string(data), `"d"`)
+		},
+	}, {
+		name:   "Discovery",
+		maker:  publicMaker,
+		path:   "/.well-known/openid-configuration",
+		config: util.Config{AppURL: "https://chat.example.com/"},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got discoveryReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			// the request's spoofed host and scheme are ignored
+			require.Equal(t, "https://chat.example.com", got.Issuer)
+			require.Equal(t, "https://chat.example.com"+jwksPath, got.JWKSURI)
+			require.Equal(t, []string{"EdDSA"}, got.SigningAlgs)
+		},
+	}, {
+		name:   "Discovery Token Issuer",
+		maker:  publicMaker,
+		path:   "/.well-known/openid-configuration",
+		config: util.Config{TokenIssuer: "https://auth.example.com", AppURL: "https://chat.example.com"},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			data, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+			var got discoveryReturn
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, "https://auth.example.com", got.Issuer)
+			// the issuer can be a logical name, the keys are served from here
+			require.Equal(t, "https://chat.example.com"+jwksPath, got.JWKSURI)
+			require.NotContains(t, string(data), "token_endpoint")
+			require.NotContains(t, string(data), "revocation_endpoint")
+		},
+	}, {
+		name:   "Discovery Without App URL",
+		maker:  publicMaker,
+		path:   "/.well-known/openid-configuration",
+		config: util.Config{TokenIssuer: "https://auth.example.com"},
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name:  "Discovery Unconfigured",
+		maker: publicMaker,
+		path:  "/.well-known/openid-configuration",
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}, {
+		name: "Symmetric Maker",
+		path: jwksPath,
+		checkRes: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			if tc.maker != nil {
+				server.tokenMaker = tc.maker
+			}
+			server.config.TokenIssuer = tc.config.TokenIssuer
+			server.config.AppURL = tc.config.AppURL
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			request.Host = "evil.example.com"
+			request.Header.Set("X-Forwarded-Proto", "http")
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkRes(t, recorder)
+		})
+	}
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "Message_conv_id_created_at_id_idx";
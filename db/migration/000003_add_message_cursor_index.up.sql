@@ -0,0 +1 @@
+CREATE INDEX ON "Message" ("conv_id", "created_at", "id");
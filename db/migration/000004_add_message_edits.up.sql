@@ -0,0 +1,13 @@
+ALTER TABLE "Message" ADD COLUMN "edited_at" timestamptz;
+ALTER TABLE "Message" ADD COLUMN "deleted_at" timestamptz;
+
+CREATE TABLE "message_edits" (
+  "id" bigserial PRIMARY KEY,
+  "message_id" bigint NOT NULL,
+  "content" varchar NOT NULL,
+  "edited_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "message_edits" ("message_id");
+
+ALTER TABLE "message_edits" ADD FOREIGN KEY ("message_id") REFERENCES "Message" ("id") ON DELETE CASCADE;
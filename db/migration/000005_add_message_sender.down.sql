@@ -0,0 +1 @@
+ALTER TABLE "Message" DROP COLUMN IF EXISTS "sender_id";
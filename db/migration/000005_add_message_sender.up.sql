@@ -0,0 +1,14 @@
+ALTER TABLE "Message" ADD COLUMN "sender_id" bigint;
+
+-- names are not unique, only attribute rows that match exactly one user
+UPDATE "Message"
+SET "sender_id" = "Users"."id"
+FROM "Users"
+WHERE "Users"."name" = "Message"."from"
+  AND (
+    SELECT count(*) FROM "Users" AS u WHERE u."name" = "Message"."from"
+  ) = 1;
+
+CREATE INDEX ON "Message" ("sender_id");
+
+ALTER TABLE "Message" ADD FOREIGN KEY ("sender_id") REFERENCES "Users" ("id") ON DELETE SET NULL;
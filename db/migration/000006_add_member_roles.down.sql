@@ -0,0 +1,5 @@
+ALTER TABLE "user_conversation" DROP COLUMN IF EXISTS "role";
+
+DROP INDEX IF EXISTS "user_conversation_user_id_conv_id_idx";
+
+CREATE INDEX ON "user_conversation" ("user_id", "conv_id");
@@ -0,0 +1,21 @@
+-- SendMessage and CreateConvTx could both insert the same membership twice
+DELETE FROM "user_conversation" a
+USING "user_conversation" b
+WHERE a."user_id" = b."user_id"
+  AND a."conv_id" = b."conv_id"
+  AND a."id" > b."id";
+
+DROP INDEX IF EXISTS "user_conversation_user_id_conv_id_idx";
+
+CREATE UNIQUE INDEX ON "user_conversation" ("user_id", "conv_id");
+
+ALTER TABLE "user_conversation" ADD COLUMN "role" varchar NOT NULL DEFAULT 'member';
+
+ALTER TABLE "user_conversation" ADD CONSTRAINT "user_conversation_role_check" CHECK ("role" IN ('owner', 'admin', 'member'));
+
+-- the first member of every existing conversation is the one who created it
+UPDATE "user_conversation"
+SET "role" = 'owner'
+WHERE "id" IN (
+    SELECT min("id") FROM "user_conversation" GROUP BY "conv_id"
+  );
@@ -0,0 +1,4 @@
+ALTER TABLE "Message" DROP COLUMN IF EXISTS "is_system";
+
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "description";
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "topic";
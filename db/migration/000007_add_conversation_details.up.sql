@@ -0,0 +1,4 @@
+ALTER TABLE "Conversation" ADD COLUMN "topic" varchar;
+ALTER TABLE "Conversation" ADD COLUMN "description" varchar;
+
+ALTER TABLE "Message" ADD COLUMN "is_system" boolean NOT NULL DEFAULT false;
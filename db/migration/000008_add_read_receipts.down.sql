@@ -0,0 +1,3 @@
+ALTER TABLE "user_conversation" DROP COLUMN IF EXISTS "last_read_message_id";
+
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "created_at";
@@ -0,0 +1,11 @@
+ALTER TABLE "Conversation" ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT (now());
+
+UPDATE "Conversation"
+SET "created_at" = coalesce(
+    (SELECT min("created_at") FROM "Message" WHERE "conv_id" = "Conversation"."id"),
+    "created_at"
+  );
+
+ALTER TABLE "user_conversation" ADD COLUMN "last_read_message_id" bigint;
+
+ALTER TABLE "user_conversation" ADD FOREIGN KEY ("last_read_message_id") REFERENCES "Message" ("id") ON DELETE SET NULL;
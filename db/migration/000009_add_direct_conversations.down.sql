@@ -0,0 +1,5 @@
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "dm_user_high";
+
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "dm_user_low";
+
+ALTER TABLE "Conversation" DROP COLUMN IF EXISTS "kind";
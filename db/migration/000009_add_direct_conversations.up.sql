@@ -0,0 +1,20 @@
+ALTER TABLE "Conversation" ADD COLUMN "kind" varchar NOT NULL DEFAULT 'group';
+
+ALTER TABLE "Conversation" ADD CONSTRAINT "Conversation_kind_check" CHECK ("kind" IN ('direct', 'group'));
+
+-- a direct conversation stores its two participants lowest id first so each
+-- pair maps to exactly one row
+ALTER TABLE "Conversation" ADD COLUMN "dm_user_low" bigint;
+
+ALTER TABLE "Conversation" ADD COLUMN "dm_user_high" bigint;
+
+ALTER TABLE "Conversation" ADD CONSTRAINT "Conversation_dm_pair_check" CHECK (
+    ("kind" = 'direct') = ("dm_user_low" IS NOT NULL AND "dm_user_high" IS NOT NULL)
+    AND ("dm_user_low" IS NULL OR "dm_user_low" < "dm_user_high")
+  );
+
+ALTER TABLE "Conversation" ADD FOREIGN KEY ("dm_user_low") REFERENCES "Users" ("id");
+
+ALTER TABLE "Conversation" ADD FOREIGN KEY ("dm_user_high") REFERENCES "Users" ("id");
+
+CREATE UNIQUE INDEX ON "Conversation" ("dm_user_low", "dm_user_high");
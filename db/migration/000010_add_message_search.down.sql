@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "Message_content_search_idx";
@@ -0,0 +1,3 @@
+-- searching against an expression index keeps the tsvector out of every row
+-- the message queries read back
+CREATE INDEX "Message_content_search_idx" ON "Message" USING GIN (to_tsvector('english', "content"));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "Users_email_trgm_idx";
+
+DROP INDEX IF EXISTS "Users_name_trgm_idx";
+
+ALTER TABLE "Users" DROP COLUMN IF EXISTS "hide_email";
@@ -0,0 +1,7 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE "Users" ADD COLUMN "hide_email" boolean NOT NULL DEFAULT false;
+
+CREATE INDEX "Users_name_trgm_idx" ON "Users" USING GIN ("name" gin_trgm_ops);
+
+CREATE INDEX "Users_email_trgm_idx" ON "Users" USING GIN ("email" gin_trgm_ops);
@@ -0,0 +1,11 @@
+-- every refresh token issued from one login shares a family, so reuse of an
+-- already rotated token can block all of them at once
+ALTER TABLE "sessions" ADD COLUMN "family_id" uuid;
+
+UPDATE "sessions" SET "family_id" = "id";
+
+ALTER TABLE "sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+ALTER TABLE "sessions" ADD COLUMN "rotated_at" timestamptz;
+
+CREATE INDEX ON "sessions" ("family_id");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_token_cutoffs";
+
+DROP TABLE IF EXISTS "revoked_tokens";
@@ -0,0 +1,21 @@
+-- access tokens are stateless, so revoked ones are remembered until they
+-- would have expired anyway
+CREATE TABLE "revoked_tokens" (
+  "id" uuid PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "revoked_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- every token a user was issued before revoked_before is rejected, used when
+-- the individual token ids are not known (e.g. after a password change)
+CREATE TABLE "user_token_cutoffs" (
+  "user_id" bigint PRIMARY KEY,
+  "revoked_before" timestamptz NOT NULL
+);
+
+CREATE INDEX ON "revoked_tokens" ("expires_at");
+
+ALTER TABLE "revoked_tokens" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_token_cutoffs" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
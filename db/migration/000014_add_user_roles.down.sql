@@ -0,0 +1,3 @@
+ALTER TABLE "Users" DROP CONSTRAINT IF EXISTS "Users_role_check";
+
+ALTER TABLE "Users" DROP COLUMN IF EXISTS "role";
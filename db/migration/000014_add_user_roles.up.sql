@@ -0,0 +1,3 @@
+ALTER TABLE "Users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'user';
+
+ALTER TABLE "Users" ADD CONSTRAINT "Users_role_check" CHECK ("role" IN ('user', 'admin'));
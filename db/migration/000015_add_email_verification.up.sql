@@ -0,0 +1,4 @@
+ALTER TABLE "Users" ADD COLUMN "email_verified_at" timestamptz;
+
+-- accounts created before verification existed stay usable
+UPDATE "Users" SET "email_verified_at" = "created_at";
@@ -0,0 +1,16 @@
+-- only a hash of each emailed token is kept, a leaked table cannot be used
+-- to reset anyone's password
+CREATE TABLE "password_resets" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "password_resets" ("user_id");
+
+CREATE INDEX ON "password_resets" ("expires_at");
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
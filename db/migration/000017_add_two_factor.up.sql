@@ -0,0 +1,22 @@
+-- a row without confirmed_at is an enrollment that has not been proven with
+-- a code yet and does not affect login
+CREATE TABLE "user_totp" (
+  "user_id" bigint PRIMARY KEY,
+  "secret" varchar NOT NULL,
+  "confirmed_at" timestamptz,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz
+);
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("user_id", "code_hash");
+
+ALTER TABLE "user_totp" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
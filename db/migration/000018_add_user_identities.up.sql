@@ -0,0 +1,15 @@
+-- an external account, the subject is only unique within its provider
+CREATE TABLE "user_identities" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "provider" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "user_identities" ("provider", "subject");
+
+CREATE INDEX ON "user_identities" ("user_id");
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
@@ -37,6 +37,123 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// BlockSession mocks base method.
+func (m *MockStore) BlockSession(arg0 context.Context, arg1 db.BlockSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSession indicates an expected call of BlockSession.
+func (mr *MockStoreMockRecorder) BlockSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSession", reflect.TypeOf((*MockStore)(nil).BlockSession), arg0, arg1)
+}
+
+// BlockSessionFamily mocks base method.
+func (m *MockStore) BlockSessionFamily(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSessionFamily", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockSessionFamily indicates an expected call of BlockSessionFamily.
+func (mr *MockStoreMockRecorder) BlockSessionFamily(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSessionFamily", reflect.TypeOf((*MockStore)(nil).BlockSessionFamily), arg0, arg1)
+}
+
+// BlockUserSessions mocks base method.
+func (m *MockStore) BlockUserSessions(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUserSessions", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUserSessions indicates an expected call of BlockUserSessions.
+func (mr *MockStoreMockRecorder) BlockUserSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUserSessions", reflect.TypeOf((*MockStore)(nil).BlockUserSessions), arg0, arg1)
+}
+
+// ChangePasswordTx mocks base method.
+func (m *MockStore) ChangePasswordTx(arg0 context.Context, arg1 db.ChangePasswordParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePasswordTx indicates an expected call of ChangePasswordTx.
+func (mr *MockStoreMockRecorder) ChangePasswordTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordTx", reflect.TypeOf((*MockStore)(nil).ChangePasswordTx), arg0, arg1)
+}
+
+// ConfirmTOTPTx mocks base method.
+func (m *MockStore) ConfirmTOTPTx(arg0 context.Context, arg1 db.ConfirmTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTPTx", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTPTx indicates an expected call of ConfirmTOTPTx.
+func (mr *MockStoreMockRecorder) ConfirmTOTPTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTPTx", reflect.TypeOf((*MockStore)(nil).ConfirmTOTPTx), arg0, arg1)
+}
+
+// ConfirmUserTOTP mocks base method.
+func (m *MockStore) ConfirmUserTOTP(arg0 context.Context, arg1 db.ConfirmUserTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmUserTOTP indicates an expected call of ConfirmUserTOTP.
+func (mr *MockStoreMockRecorder) ConfirmUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUserTOTP", reflect.TypeOf((*MockStore)(nil).ConfirmUserTOTP), arg0, arg1)
+}
+
+// ConsumePasswordReset mocks base method.
+func (m *MockStore) ConsumePasswordReset(arg0 context.Context, arg1 string) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumePasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumePasswordReset indicates an expected call of ConsumePasswordReset.
+func (mr *MockStoreMockRecorder) ConsumePasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumePasswordReset", reflect.TypeOf((*MockStore)(nil).ConsumePasswordReset), arg0, arg1)
+}
+
+// CreateConvMember mocks base method.
+func (m *MockStore) CreateConvMember(arg0 context.Context, arg1 db.CreateConvMemberParams) (db.UserConversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConvMember", arg0, arg1)
+	ret0, _ := ret[0].(db.UserConversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateConvMember indicates an expected call of CreateConvMember.
+func (mr *MockStoreMockRecorder) CreateConvMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConvMember", reflect.TypeOf((*MockStore)(nil).CreateConvMember), arg0, arg1)
+}
+
 // CreateConvTx mocks base method.
 func (m *MockStore) CreateConvTx(arg0 context.Context, arg1 db.CreateConvParams) (db.ConvReturn, error) {
 	m.ctrl.T.Helper()
@@ -67,6 +184,21 @@ func (mr *MockStoreMockRecorder) CreateConversation(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConversation", reflect.TypeOf((*MockStore)(nil).CreateConversation), arg0, arg1)
 }
 
+// CreateDirectConversation mocks base method.
+func (m *MockStore) CreateDirectConversation(arg0 context.Context, arg1 db.CreateDirectConversationParams) (db.Conversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDirectConversation", arg0, arg1)
+	ret0, _ := ret[0].(db.Conversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDirectConversation indicates an expected call of CreateDirectConversation.
+func (mr *MockStoreMockRecorder) CreateDirectConversation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDirectConversation", reflect.TypeOf((*MockStore)(nil).CreateDirectConversation), arg0, arg1)
+}
+
 // CreateMessage mocks base method.
 func (m *MockStore) CreateMessage(arg0 context.Context, arg1 db.CreateMessageParams) (db.Message, error) {
 	m.ctrl.T.Helper()
@@ -82,6 +214,65 @@ func (mr *MockStoreMockRecorder) CreateMessage(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMessage", reflect.TypeOf((*MockStore)(nil).CreateMessage), arg0, arg1)
 }
 
+// CreateMessageEdit mocks base method.
+func (m *MockStore) CreateMessageEdit(arg0 context.Context, arg1 db.CreateMessageEditParams) (db.MessageEdit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMessageEdit", arg0, arg1)
+	ret0, _ := ret[0].(db.MessageEdit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMessageEdit indicates an expected call of CreateMessageEdit.
+func (mr *MockStoreMockRecorder) CreateMessageEdit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMessageEdit", reflect.TypeOf((*MockStore)(nil).CreateMessageEdit), arg0, arg1)
+}
+
+// CreateOIDCUserTx mocks base method.
+func (m *MockStore) CreateOIDCUserTx(arg0 context.Context, arg1 db.CreateOIDCUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOIDCUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOIDCUserTx indicates an expected call of CreateOIDCUserTx.
+func (mr *MockStoreMockRecorder) CreateOIDCUserTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOIDCUserTx", reflect.TypeOf((*MockStore)(nil).CreateOIDCUserTx), arg0, arg1)
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockStore) CreatePasswordReset(arg0 context.Context, arg1 db.CreatePasswordResetParams) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockStoreMockRecorder) CreatePasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockStore)(nil).CreatePasswordReset), arg0, arg1)
+}
+
+// CreateRecoveryCode mocks base method.
+func (m *MockStore) CreateRecoveryCode(arg0 context.Context, arg1 db.CreateRecoveryCodeParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRecoveryCode indicates an expected call of CreateRecoveryCode.
+func (mr *MockStoreMockRecorder) CreateRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCode", reflect.TypeOf((*MockStore)(nil).CreateRecoveryCode), arg0, arg1)
+}
+
 // CreateSession mocks base method.
 func (m *MockStore) CreateSession(arg0 context.Context, arg1 db.CreateSessionParams) (db.Session, error) {
 	m.ctrl.T.Helper()
@@ -97,6 +288,21 @@ func (mr *MockStoreMockRecorder) CreateSession(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), arg0, arg1)
 }
 
+// CreateSystemMessage mocks base method.
+func (m *MockStore) CreateSystemMessage(arg0 context.Context, arg1 db.CreateSystemMessageParams) (db.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSystemMessage", arg0, arg1)
+	ret0, _ := ret[0].(db.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSystemMessage indicates an expected call of CreateSystemMessage.
+func (mr *MockStoreMockRecorder) CreateSystemMessage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSystemMessage", reflect.TypeOf((*MockStore)(nil).CreateSystemMessage), arg0, arg1)
+}
+
 // CreateUser mocks base method.
 func (m *MockStore) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
@@ -112,6 +318,21 @@ func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
 }
 
+// CreateUserIdentity mocks base method.
+func (m *MockStore) CreateUserIdentity(arg0 context.Context, arg1 db.CreateUserIdentityParams) (db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserIdentity", arg0, arg1)
+	ret0, _ := ret[0].(db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUserIdentity indicates an expected call of CreateUserIdentity.
+func (mr *MockStoreMockRecorder) CreateUserIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserIdentity", reflect.TypeOf((*MockStore)(nil).CreateUserIdentity), arg0, arg1)
+}
+
 // CreateUser_conversation mocks base method.
 func (m *MockStore) CreateUser_conversation(arg0 context.Context, arg1 db.CreateUser_conversationParams) (db.UserConversation, error) {
 	m.ctrl.T.Helper()
@@ -127,6 +348,48 @@ func (mr *MockStoreMockRecorder) CreateUser_conversation(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser_conversation", reflect.TypeOf((*MockStore)(nil).CreateUser_conversation), arg0, arg1)
 }
 
+// DeleteConvMembers mocks base method.
+func (m *MockStore) DeleteConvMembers(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConvMembers", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConvMembers indicates an expected call of DeleteConvMembers.
+func (mr *MockStoreMockRecorder) DeleteConvMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConvMembers", reflect.TypeOf((*MockStore)(nil).DeleteConvMembers), arg0, arg1)
+}
+
+// DeleteConvMessages mocks base method.
+func (m *MockStore) DeleteConvMessages(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConvMessages", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConvMessages indicates an expected call of DeleteConvMessages.
+func (mr *MockStoreMockRecorder) DeleteConvMessages(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConvMessages", reflect.TypeOf((*MockStore)(nil).DeleteConvMessages), arg0, arg1)
+}
+
+// DeleteConvTx mocks base method.
+func (m *MockStore) DeleteConvTx(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConvTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConvTx indicates an expected call of DeleteConvTx.
+func (mr *MockStoreMockRecorder) DeleteConvTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConvTx", reflect.TypeOf((*MockStore)(nil).DeleteConvTx), arg0, arg1)
+}
+
 // DeleteConversation mocks base method.
 func (m *MockStore) DeleteConversation(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -141,6 +404,34 @@ func (mr *MockStoreMockRecorder) DeleteConversation(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConversation", reflect.TypeOf((*MockStore)(nil).DeleteConversation), arg0, arg1)
 }
 
+// DeleteExpiredPasswordResets mocks base method.
+func (m *MockStore) DeleteExpiredPasswordResets(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredPasswordResets", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredPasswordResets indicates an expected call of DeleteExpiredPasswordResets.
+func (mr *MockStoreMockRecorder) DeleteExpiredPasswordResets(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredPasswordResets", reflect.TypeOf((*MockStore)(nil).DeleteExpiredPasswordResets), arg0)
+}
+
+// DeleteExpiredRevokedTokens mocks base method.
+func (m *MockStore) DeleteExpiredRevokedTokens(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevokedTokens", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredRevokedTokens indicates an expected call of DeleteExpiredRevokedTokens.
+func (mr *MockStoreMockRecorder) DeleteExpiredRevokedTokens(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevokedTokens", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRevokedTokens), arg0)
+}
+
 // DeleteMessage mocks base method.
 func (m *MockStore) DeleteMessage(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -155,6 +446,49 @@ func (mr *MockStoreMockRecorder) DeleteMessage(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessage", reflect.TypeOf((*MockStore)(nil).DeleteMessage), arg0, arg1)
 }
 
+// DeleteMessageEdits mocks base method.
+func (m *MockStore) DeleteMessageEdits(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessageEdits", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMessageEdits indicates an expected call of DeleteMessageEdits.
+func (mr *MockStoreMockRecorder) DeleteMessageEdits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessageEdits", reflect.TypeOf((*MockStore)(nil).DeleteMessageEdits), arg0, arg1)
+}
+
+// DeleteMessageTx mocks base method.
+func (m *MockStore) DeleteMessageTx(arg0 context.Context, arg1 int64) (db.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessageTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMessageTx indicates an expected call of DeleteMessageTx.
+func (mr *MockStoreMockRecorder) DeleteMessageTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessageTx", reflect.TypeOf((*MockStore)(nil).DeleteMessageTx), arg0, arg1)
+}
+
+// DeleteRecoveryCodes mocks base method.
+func (m *MockStore) DeleteRecoveryCodes(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCodes", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRecoveryCodes indicates an expected call of DeleteRecoveryCodes.
+func (mr *MockStoreMockRecorder) DeleteRecoveryCodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCodes", reflect.TypeOf((*MockStore)(nil).DeleteRecoveryCodes), arg0, arg1)
+}
+
 // DeleteUser mocks base method.
 func (m *MockStore) DeleteUser(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -197,6 +531,65 @@ func (mr *MockStoreMockRecorder) DeleteUser_conversation_by_id(arg0, arg1 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser_conversation_by_id", reflect.TypeOf((*MockStore)(nil).DeleteUser_conversation_by_id), arg0, arg1)
 }
 
+// DirectConvTx mocks base method.
+func (m *MockStore) DirectConvTx(arg0 context.Context, arg1 db.DirectConvParams) (db.ConvReturn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DirectConvTx", arg0, arg1)
+	ret0, _ := ret[0].(db.ConvReturn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DirectConvTx indicates an expected call of DirectConvTx.
+func (mr *MockStoreMockRecorder) DirectConvTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DirectConvTx", reflect.TypeOf((*MockStore)(nil).DirectConvTx), arg0, arg1)
+}
+
+// EditMessageTx mocks base method.
+func (m *MockStore) EditMessageTx(arg0 context.Context, arg1 db.EditMessageParams) (db.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditMessageTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditMessageTx indicates an expected call of EditMessageTx.
+func (mr *MockStoreMockRecorder) EditMessageTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditMessageTx", reflect.TypeOf((*MockStore)(nil).EditMessageTx), arg0, arg1)
+}
+
+// ExpirePasswordResets mocks base method.
+func (m *MockStore) ExpirePasswordResets(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePasswordResets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpirePasswordResets indicates an expected call of ExpirePasswordResets.
+func (mr *MockStoreMockRecorder) ExpirePasswordResets(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePasswordResets", reflect.TypeOf((*MockStore)(nil).ExpirePasswordResets), arg0, arg1)
+}
+
+// GetConvSuccessor mocks base method.
+func (m *MockStore) GetConvSuccessor(arg0 context.Context, arg1 db.GetConvSuccessorParams) (db.UserConversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConvSuccessor", arg0, arg1)
+	ret0, _ := ret[0].(db.UserConversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConvSuccessor indicates an expected call of GetConvSuccessor.
+func (mr *MockStoreMockRecorder) GetConvSuccessor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConvSuccessor", reflect.TypeOf((*MockStore)(nil).GetConvSuccessor), arg0, arg1)
+}
+
 // GetConversation mocks base method.
 func (m *MockStore) GetConversation(arg0 context.Context, arg1 int64) (db.Conversation, error) {
 	m.ctrl.T.Helper()
@@ -212,6 +605,21 @@ func (mr *MockStoreMockRecorder) GetConversation(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConversation", reflect.TypeOf((*MockStore)(nil).GetConversation), arg0, arg1)
 }
 
+// GetDirectConversation mocks base method.
+func (m *MockStore) GetDirectConversation(arg0 context.Context, arg1 db.GetDirectConversationParams) (db.Conversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDirectConversation", arg0, arg1)
+	ret0, _ := ret[0].(db.Conversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDirectConversation indicates an expected call of GetDirectConversation.
+func (mr *MockStoreMockRecorder) GetDirectConversation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDirectConversation", reflect.TypeOf((*MockStore)(nil).GetDirectConversation), arg0, arg1)
+}
+
 // GetMessage mocks base method.
 func (m *MockStore) GetMessage(arg0 context.Context, arg1 int64) (db.Message, error) {
 	m.ctrl.T.Helper()
@@ -272,6 +680,51 @@ func (mr *MockStoreMockRecorder) GetUserByEmail(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), arg0, arg1)
 }
 
+// GetUserIdentity mocks base method.
+func (m *MockStore) GetUserIdentity(arg0 context.Context, arg1 db.GetUserIdentityParams) (db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIdentity", arg0, arg1)
+	ret0, _ := ret[0].(db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIdentity indicates an expected call of GetUserIdentity.
+func (mr *MockStoreMockRecorder) GetUserIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIdentity", reflect.TypeOf((*MockStore)(nil).GetUserIdentity), arg0, arg1)
+}
+
+// GetUserPassword mocks base method.
+func (m *MockStore) GetUserPassword(arg0 context.Context, arg1 int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserPassword", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserPassword indicates an expected call of GetUserPassword.
+func (mr *MockStoreMockRecorder) GetUserPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPassword", reflect.TypeOf((*MockStore)(nil).GetUserPassword), arg0, arg1)
+}
+
+// GetUserTOTP mocks base method.
+func (m *MockStore) GetUserTOTP(arg0 context.Context, arg1 int64) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTOTP indicates an expected call of GetUserTOTP.
+func (mr *MockStoreMockRecorder) GetUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTOTP", reflect.TypeOf((*MockStore)(nil).GetUserTOTP), arg0, arg1)
+}
+
 // GetUser_conv_by_id mocks base method.
 func (m *MockStore) GetUser_conv_by_id(arg0 context.Context, arg1 int64) (db.UserConversation, error) {
 	m.ctrl.T.Helper()
@@ -302,6 +755,51 @@ func (mr *MockStoreMockRecorder) GetUser_conversation(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser_conversation", reflect.TypeOf((*MockStore)(nil).GetUser_conversation), arg0, arg1)
 }
 
+// IsTokenRevoked mocks base method.
+func (m *MockStore) IsTokenRevoked(arg0 context.Context, arg1 db.IsTokenRevokedParams) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockStoreMockRecorder) IsTokenRevoked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockStore)(nil).IsTokenRevoked), arg0, arg1)
+}
+
+// LeaveConvTx mocks base method.
+func (m *MockStore) LeaveConvTx(arg0 context.Context, arg1 db.LeaveConvParams) (db.LeaveConvResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LeaveConvTx", arg0, arg1)
+	ret0, _ := ret[0].(db.LeaveConvResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LeaveConvTx indicates an expected call of LeaveConvTx.
+func (mr *MockStoreMockRecorder) LeaveConvTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LeaveConvTx", reflect.TypeOf((*MockStore)(nil).LeaveConvTx), arg0, arg1)
+}
+
+// ListActiveSessions mocks base method.
+func (m *MockStore) ListActiveSessions(arg0 context.Context, arg1 int64) ([]db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListActiveSessions", arg0, arg1)
+	ret0, _ := ret[0].([]db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListActiveSessions indicates an expected call of ListActiveSessions.
+func (mr *MockStoreMockRecorder) ListActiveSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListActiveSessions", reflect.TypeOf((*MockStore)(nil).ListActiveSessions), arg0, arg1)
+}
+
 // ListConvFromUser mocks base method.
 func (m *MockStore) ListConvFromUser(arg0 context.Context, arg1 int64) ([]db.Conversation, error) {
 	m.ctrl.T.Helper()
@@ -317,6 +815,21 @@ func (mr *MockStoreMockRecorder) ListConvFromUser(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvFromUser", reflect.TypeOf((*MockStore)(nil).ListConvFromUser), arg0, arg1)
 }
 
+// ListConvMembers mocks base method.
+func (m *MockStore) ListConvMembers(arg0 context.Context, arg1 int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConvMembers", arg0, arg1)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConvMembers indicates an expected call of ListConvMembers.
+func (mr *MockStoreMockRecorder) ListConvMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvMembers", reflect.TypeOf((*MockStore)(nil).ListConvMembers), arg0, arg1)
+}
+
 // ListConvMessages mocks base method.
 func (m *MockStore) ListConvMessages(arg0 context.Context, arg1 db.ListConvMessagesParams) ([]db.ListConvMessagesRow, error) {
 	m.ctrl.T.Helper()
@@ -332,6 +845,66 @@ func (mr *MockStoreMockRecorder) ListConvMessages(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvMessages", reflect.TypeOf((*MockStore)(nil).ListConvMessages), arg0, arg1)
 }
 
+// ListConvMessagesAfter mocks base method.
+func (m *MockStore) ListConvMessagesAfter(arg0 context.Context, arg1 db.ListConvMessagesAfterParams) ([]db.ListConvMessagesAfterRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConvMessagesAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListConvMessagesAfterRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConvMessagesAfter indicates an expected call of ListConvMessagesAfter.
+func (mr *MockStoreMockRecorder) ListConvMessagesAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvMessagesAfter", reflect.TypeOf((*MockStore)(nil).ListConvMessagesAfter), arg0, arg1)
+}
+
+// ListConvMessagesBefore mocks base method.
+func (m *MockStore) ListConvMessagesBefore(arg0 context.Context, arg1 db.ListConvMessagesBeforeParams) ([]db.ListConvMessagesBeforeRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConvMessagesBefore", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListConvMessagesBeforeRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConvMessagesBefore indicates an expected call of ListConvMessagesBefore.
+func (mr *MockStoreMockRecorder) ListConvMessagesBefore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvMessagesBefore", reflect.TypeOf((*MockStore)(nil).ListConvMessagesBefore), arg0, arg1)
+}
+
+// ListConvMessagesSince mocks base method.
+func (m *MockStore) ListConvMessagesSince(arg0 context.Context, arg1 db.ListConvMessagesSinceParams) ([]db.ListConvMessagesSinceRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConvMessagesSince", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListConvMessagesSinceRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConvMessagesSince indicates an expected call of ListConvMessagesSince.
+func (mr *MockStoreMockRecorder) ListConvMessagesSince(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvMessagesSince", reflect.TypeOf((*MockStore)(nil).ListConvMessagesSince), arg0, arg1)
+}
+
+// ListConvSummaries mocks base method.
+func (m *MockStore) ListConvSummaries(arg0 context.Context, arg1 int64) ([]db.ListConvSummariesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConvSummaries", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListConvSummariesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConvSummaries indicates an expected call of ListConvSummaries.
+func (mr *MockStoreMockRecorder) ListConvSummaries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConvSummaries", reflect.TypeOf((*MockStore)(nil).ListConvSummaries), arg0, arg1)
+}
+
 // ListConversations mocks base method.
 func (m *MockStore) ListConversations(arg0 context.Context, arg1 db.ListConversationsParams) ([]db.Conversation, error) {
 	m.ctrl.T.Helper()
@@ -348,7 +921,7 @@ func (mr *MockStoreMockRecorder) ListConversations(arg0, arg1 interface{}) *gomo
 }
 
 // ListMessageByUser mocks base method.
-func (m *MockStore) ListMessageByUser(arg0 context.Context, arg1 string) ([]db.Message, error) {
+func (m *MockStore) ListMessageByUser(arg0 context.Context, arg1 sql.NullInt64) ([]db.Message, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ListMessageByUser", arg0, arg1)
 	ret0, _ := ret[0].([]db.Message)
@@ -362,6 +935,21 @@ func (mr *MockStoreMockRecorder) ListMessageByUser(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMessageByUser", reflect.TypeOf((*MockStore)(nil).ListMessageByUser), arg0, arg1)
 }
 
+// ListMessageEdits mocks base method.
+func (m *MockStore) ListMessageEdits(arg0 context.Context, arg1 int64) ([]db.MessageEdit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMessageEdits", arg0, arg1)
+	ret0, _ := ret[0].([]db.MessageEdit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMessageEdits indicates an expected call of ListMessageEdits.
+func (mr *MockStoreMockRecorder) ListMessageEdits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMessageEdits", reflect.TypeOf((*MockStore)(nil).ListMessageEdits), arg0, arg1)
+}
+
 // ListUserMessages mocks base method.
 func (m *MockStore) ListUserMessages(arg0 context.Context, arg1 int64) ([]db.ListUserMessagesRow, error) {
 	m.ctrl.T.Helper()
@@ -422,6 +1010,139 @@ func (mr *MockStoreMockRecorder) ListUsers(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockStore)(nil).ListUsers), arg0, arg1)
 }
 
+// MarkConvRead mocks base method.
+func (m *MockStore) MarkConvRead(arg0 context.Context, arg1 db.MarkConvReadParams) (db.UserConversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkConvRead", arg0, arg1)
+	ret0, _ := ret[0].(db.UserConversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkConvRead indicates an expected call of MarkConvRead.
+func (mr *MockStoreMockRecorder) MarkConvRead(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkConvRead", reflect.TypeOf((*MockStore)(nil).MarkConvRead), arg0, arg1)
+}
+
+// MarkEmailVerified mocks base method.
+func (m *MockStore) MarkEmailVerified(arg0 context.Context, arg1 db.MarkEmailVerifiedParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailVerified", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkEmailVerified indicates an expected call of MarkEmailVerified.
+func (mr *MockStoreMockRecorder) MarkEmailVerified(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailVerified", reflect.TypeOf((*MockStore)(nil).MarkEmailVerified), arg0, arg1)
+}
+
+// MarkSessionRotated mocks base method.
+func (m *MockStore) MarkSessionRotated(arg0 context.Context, arg1 uuid.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSessionRotated", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkSessionRotated indicates an expected call of MarkSessionRotated.
+func (mr *MockStoreMockRecorder) MarkSessionRotated(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSessionRotated", reflect.TypeOf((*MockStore)(nil).MarkSessionRotated), arg0, arg1)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(arg0 context.Context, arg1 db.ResetPasswordParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), arg0, arg1)
+}
+
+// RevokeToken mocks base method.
+func (m *MockStore) RevokeToken(arg0 context.Context, arg1 db.RevokeTokenParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockStoreMockRecorder) RevokeToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockStore)(nil).RevokeToken), arg0, arg1)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockStore) RevokeUserTokens(arg0 context.Context, arg1 db.RevokeUserTokensParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockStoreMockRecorder) RevokeUserTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockStore)(nil).RevokeUserTokens), arg0, arg1)
+}
+
+// RotateSessionTx mocks base method.
+func (m *MockStore) RotateSessionTx(arg0 context.Context, arg1 db.RotateSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSessionTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateSessionTx indicates an expected call of RotateSessionTx.
+func (mr *MockStoreMockRecorder) RotateSessionTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSessionTx", reflect.TypeOf((*MockStore)(nil).RotateSessionTx), arg0, arg1)
+}
+
+// SearchMessages mocks base method.
+func (m *MockStore) SearchMessages(arg0 context.Context, arg1 db.SearchMessagesParams) ([]db.SearchMessagesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchMessages", arg0, arg1)
+	ret0, _ := ret[0].([]db.SearchMessagesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchMessages indicates an expected call of SearchMessages.
+func (mr *MockStoreMockRecorder) SearchMessages(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchMessages", reflect.TypeOf((*MockStore)(nil).SearchMessages), arg0, arg1)
+}
+
+// SearchUsers mocks base method.
+func (m *MockStore) SearchUsers(arg0 context.Context, arg1 db.SearchUsersParams) ([]db.SearchUsersRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", arg0, arg1)
+	ret0, _ := ret[0].([]db.SearchUsersRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockStoreMockRecorder) SearchUsers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockStore)(nil).SearchUsers), arg0, arg1)
+}
+
 // SendMessage mocks base method.
 func (m *MockStore) SendMessage(arg0 context.Context, arg1 db.SendMessageParams) (db.SendResult, error) {
 	m.ctrl.T.Helper()
@@ -437,6 +1158,66 @@ func (mr *MockStoreMockRecorder) SendMessage(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockStore)(nil).SendMessage), arg0, arg1)
 }
 
+// StartUserTOTP mocks base method.
+func (m *MockStore) StartUserTOTP(arg0 context.Context, arg1 db.StartUserTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartUserTOTP indicates an expected call of StartUserTOTP.
+func (mr *MockStoreMockRecorder) StartUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartUserTOTP", reflect.TypeOf((*MockStore)(nil).StartUserTOTP), arg0, arg1)
+}
+
+// TombstoneMessage mocks base method.
+func (m *MockStore) TombstoneMessage(arg0 context.Context, arg1 int64) (db.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TombstoneMessage", arg0, arg1)
+	ret0, _ := ret[0].(db.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TombstoneMessage indicates an expected call of TombstoneMessage.
+func (mr *MockStoreMockRecorder) TombstoneMessage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TombstoneMessage", reflect.TypeOf((*MockStore)(nil).TombstoneMessage), arg0, arg1)
+}
+
+// UpdateConvMemberRole mocks base method.
+func (m *MockStore) UpdateConvMemberRole(arg0 context.Context, arg1 db.UpdateConvMemberRoleParams) (db.UserConversation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConvMemberRole", arg0, arg1)
+	ret0, _ := ret[0].(db.UserConversation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConvMemberRole indicates an expected call of UpdateConvMemberRole.
+func (mr *MockStoreMockRecorder) UpdateConvMemberRole(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConvMemberRole", reflect.TypeOf((*MockStore)(nil).UpdateConvMemberRole), arg0, arg1)
+}
+
+// UpdateConvTx mocks base method.
+func (m *MockStore) UpdateConvTx(arg0 context.Context, arg1 db.UpdateConvParams) (db.UpdateConvResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConvTx", arg0, arg1)
+	ret0, _ := ret[0].(db.UpdateConvResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConvTx indicates an expected call of UpdateConvTx.
+func (mr *MockStoreMockRecorder) UpdateConvTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConvTx", reflect.TypeOf((*MockStore)(nil).UpdateConvTx), arg0, arg1)
+}
+
 // UpdateConversation mocks base method.
 func (m *MockStore) UpdateConversation(arg0 context.Context, arg1 db.UpdateConversationParams) (db.Conversation, error) {
 	m.ctrl.T.Helper()
@@ -452,6 +1233,21 @@ func (mr *MockStoreMockRecorder) UpdateConversation(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConversation", reflect.TypeOf((*MockStore)(nil).UpdateConversation), arg0, arg1)
 }
 
+// UpdateMessageContent mocks base method.
+func (m *MockStore) UpdateMessageContent(arg0 context.Context, arg1 db.UpdateMessageContentParams) (db.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMessageContent", arg0, arg1)
+	ret0, _ := ret[0].(db.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMessageContent indicates an expected call of UpdateMessageContent.
+func (mr *MockStoreMockRecorder) UpdateMessageContent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMessageContent", reflect.TypeOf((*MockStore)(nil).UpdateMessageContent), arg0, arg1)
+}
+
 // UpdateUserInfo mocks base method.
 func (m *MockStore) UpdateUserInfo(arg0 context.Context, arg1 db.UpdateUserInfoParams) (db.UpdateUserInfoRow, error) {
 	m.ctrl.T.Helper()
@@ -466,3 +1262,77 @@ func (mr *MockStoreMockRecorder) UpdateUserInfo(arg0, arg1 interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserInfo", reflect.TypeOf((*MockStore)(nil).UpdateUserInfo), arg0, arg1)
 }
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(arg0 context.Context, arg1 db.UpdateUserPasswordParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), arg0, arg1)
+}
+
+// UpdateUserRole mocks base method.
+func (m *MockStore) UpdateUserRole(arg0 context.Context, arg1 db.UpdateUserRoleParams) (db.UpdateUserRoleRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", arg0, arg1)
+	ret0, _ := ret[0].(db.UpdateUserRoleRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockStoreMockRecorder) UpdateUserRole(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockStore)(nil).UpdateUserRole), arg0, arg1)
+}
+
+// UpdateUserRoleTx mocks base method.
+func (m *MockStore) UpdateUserRoleTx(arg0 context.Context, arg1 db.UpdateUserRoleParams) (db.UpdateUserRoleRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRoleTx", arg0, arg1)
+	ret0, _ := ret[0].(db.UpdateUserRoleRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRoleTx indicates an expected call of UpdateUserRoleTx.
+func (mr *MockStoreMockRecorder) UpdateUserRoleTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRoleTx", reflect.TypeOf((*MockStore)(nil).UpdateUserRoleTx), arg0, arg1)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStore) UseRecoveryCode(arg0 context.Context, arg1 db.UseRecoveryCodeParams) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStoreMockRecorder) UseRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseRecoveryCode), arg0, arg1)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStore) UseTOTPStep(arg0 context.Context, arg1 db.UseTOTPStepParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStoreMockRecorder) UseTOTPStep(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStore)(nil).UseTOTPStep), arg0, arg1)
+}
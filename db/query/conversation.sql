@@ -2,6 +2,15 @@
 INSERT INTO "Conversation" (name)
 VALUES($1)
 RETURNING *;
+-- name: CreateDirectConversation :one
+INSERT INTO "Conversation" (name, kind, dm_user_low, dm_user_high)
+VALUES($1, 'direct', $2, $3) ON CONFLICT (dm_user_low, dm_user_high) DO NOTHING
+RETURNING *;
+-- name: GetDirectConversation :one
+SELECT *
+FROM "Conversation"
+WHERE dm_user_low = $1
+  and dm_user_high = $2;
 -- name: GetConversation :one
 SELECT *
 FROM "Conversation"
@@ -14,19 +23,106 @@ ORDER BY id
 LIMIT $1 OFFSET $2;
 -- name: UpdateConversation :one
 UPDATE "Conversation"
-SET name = $2
-WHERE ID = $1
+SET name = coalesce(sqlc.narg('name'), name),
+  topic = coalesce(sqlc.narg('topic'), topic),
+  description = coalesce(sqlc.narg('description'), description)
+WHERE ID = sqlc.arg('id')
 returning *;
 -- name: DeleteConversation :exec
 DELETE FROM "Conversation"
 WHERE ID = $1;
 -- name: ListConvMessages :many
 SELECT 
-"Message".from,"Message".content as message_content,"Message".created_at, "Message".id as message_id
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
 FROM
 "user_conversation"
 INNER JOIN "Conversation" on "user_conversation".conv_id = "Conversation".id
 INNER JOIN "Message" on "Conversation".id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
 Where
 "user_conversation".conv_id = $1
-And "user_conversation".user_id=$2;
\ No newline at end of file
+And "user_conversation".user_id=$2;
+-- name: ListConvMessagesBefore :many
+SELECT 
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
+FROM
+"user_conversation"
+INNER JOIN "Message" on "user_conversation".conv_id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
+Where
+"user_conversation".conv_id = sqlc.arg('conv_id')
+And "user_conversation".user_id = sqlc.arg('user_id')
+And (
+  sqlc.narg('before')::bigint IS NULL
+  OR ("Message".created_at, "Message".id) < (
+    SELECT created_at, id FROM "Message"
+    WHERE id = sqlc.narg('before')
+      and conv_id = sqlc.arg('conv_id')
+  )
+)
+ORDER BY "Message".created_at DESC, "Message".id DESC
+LIMIT sqlc.arg('limit');
+-- name: ListConvMessagesAfter :many
+SELECT 
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
+FROM
+"user_conversation"
+INNER JOIN "Message" on "user_conversation".conv_id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
+Where
+"user_conversation".conv_id = sqlc.arg('conv_id')
+And "user_conversation".user_id = sqlc.arg('user_id')
+And ("Message".created_at, "Message".id) > (
+  SELECT created_at, id FROM "Message"
+  WHERE id = sqlc.arg('after')
+    and conv_id = sqlc.arg('conv_id')
+)
+ORDER BY "Message".created_at, "Message".id
+LIMIT sqlc.arg('limit');
+-- name: DeleteConvMessages :exec
+DELETE FROM "Message"
+WHERE conv_id = $1;
+-- name: DeleteConvMembers :exec
+DELETE FROM "user_conversation"
+WHERE conv_id = $1;
+-- name: ListConvSummaries :many
+SELECT "Conversation".id,
+  "Conversation".name,
+  "Conversation".topic,
+  "Conversation".description,
+  "Conversation".kind,
+  "user_conversation".last_read_message_id,
+  (
+    SELECT count(*)
+    FROM "Message"
+    WHERE "Message".conv_id = "Conversation".id
+      and "Message".id > coalesce("user_conversation".last_read_message_id, 0)
+      and "Message".sender_id IS DISTINCT FROM "user_conversation".user_id
+      and "Message".deleted_at IS NULL
+  ) as unread_count,
+  last_message.id as last_message_id,
+  last_message.content as last_message_content,
+  coalesce("Users".name, last_message.from) as last_message_from,
+  last_message.created_at as last_message_at,
+  coalesce(last_message.created_at, "Conversation".created_at) as last_activity_at
+FROM "user_conversation"
+  INNER JOIN "Conversation" on "user_conversation".conv_id = "Conversation".id
+  LEFT JOIN LATERAL (
+    SELECT "Message".id,
+      "Message".from,
+      "Message".content,
+      "Message".created_at,
+      "Message".sender_id
+    FROM "Message"
+    WHERE "Message".conv_id = "Conversation".id
+    ORDER BY "Message".created_at DESC,
+      "Message".id DESC
+    LIMIT 1
+  ) last_message on true
+  LEFT JOIN "Users" on "Users".id = last_message.sender_id
+WHERE "user_conversation".user_id = $1
+ORDER BY last_activity_at DESC,
+  "Conversation".id DESC;
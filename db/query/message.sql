@@ -1,6 +1,10 @@
 -- name: CreateMessage :one
-INSERT INTO "Message" ("from", content, conv_id)
-VALUES($1, $2, $3)
+INSERT INTO "Message" ("from", content, conv_id, sender_id)
+VALUES($1, $2, $3, $4)
+RETURNING *;
+-- name: CreateSystemMessage :one
+INSERT INTO "Message" ("from", content, conv_id, sender_id, is_system)
+VALUES($1, $2, $3, $4, true)
 RETURNING *;
 -- name: GetMessage :one
 SELECT *
@@ -9,8 +13,86 @@ WHERE id = $1;
 -- name: ListMessageByUser :many
 SELECT *
 from "Message"
-WHERE "from" = $1
+WHERE sender_id = $1
 ORDER BY created_at;
 -- name: DeleteMessage :exec
 DELETE FROM "Message"
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+-- name: ListConvMessagesSince :many
+SELECT "Message".id,
+  "Message".content,
+  "Message".created_at,
+  "Message".conv_id,
+  "Message".sender_id,
+  coalesce("Users".name, "Message".from) as sender_name,
+  "Users".image as sender_image,
+  "Message".is_system
+from "Message"
+  LEFT JOIN "Users" on "Users".id = "Message".sender_id
+WHERE "Message".conv_id = $1
+  and "Message".id > $2
+ORDER BY "Message".id
+LIMIT $3;
+-- name: UpdateMessageContent :one
+UPDATE "Message"
+SET content = $2,
+  edited_at = now()
+WHERE id = $1
+  and deleted_at IS NULL
+RETURNING *;
+-- name: TombstoneMessage :one
+UPDATE "Message"
+SET content = 'message deleted',
+  deleted_at = now()
+WHERE id = $1
+  and deleted_at IS NULL
+RETURNING *;
+-- name: SearchMessages :many
+SELECT "Message".id,
+  "Message".conv_id,
+  "Message".sender_id,
+  coalesce("Users".name, "Message".from) as sender_name,
+  "Message".created_at,
+  -- escaped first so the only markup in the snippet is the <mark> it adds
+  ts_headline(
+    'english',
+    replace(
+      replace(replace("Message".content, '&', '&amp;'), '<', '&lt;'),
+      '>',
+      '&gt;'
+    ),
+    query,
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+  ) as snippet,
+  ts_rank(to_tsvector('english', "Message".content), query) as rank
+FROM "Message"
+  CROSS JOIN websearch_to_tsquery('english', sqlc.arg('query')) query
+  INNER JOIN "user_conversation" on "user_conversation".conv_id = "Message".conv_id
+  and "user_conversation".user_id = sqlc.arg('user_id')
+  LEFT JOIN "Users" on "Users".id = "Message".sender_id
+WHERE to_tsvector('english', "Message".content) @@ query
+  and "Message".deleted_at IS NULL
+  and "Message".is_system = false
+  and (
+    sqlc.narg('conv_id')::bigint IS NULL
+    OR "Message".conv_id = sqlc.narg('conv_id')
+  )
+  and (
+    sqlc.narg('sender_id')::bigint IS NULL
+    OR "Message".sender_id = sqlc.narg('sender_id')
+  )
+  and (
+    sqlc.narg('before')::timestamptz IS NULL
+    OR "Message".created_at < sqlc.narg('before')
+  )
+  and (
+    sqlc.narg('after')::timestamptz IS NULL
+    OR "Message".created_at > sqlc.narg('after')
+  )
+  and (
+    sqlc.narg('cursor_rank')::real IS NULL
+    OR (ts_rank(to_tsvector('english', "Message".content), query), "Message".id) < (sqlc.narg('cursor_rank'), sqlc.narg('cursor_id')::bigint)
+  )
+ORDER BY rank DESC,
+  "Message".id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,12 @@
+-- name: CreateMessageEdit :one
+INSERT INTO "message_edits" (message_id, content)
+VALUES($1, $2)
+RETURNING *;
+-- name: ListMessageEdits :many
+SELECT *
+from "message_edits"
+WHERE message_id = $1
+ORDER BY edited_at, id;
+-- name: DeleteMessageEdits :exec
+DELETE FROM "message_edits"
+WHERE message_id = $1;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+-- name: ConsumePasswordReset :one
+UPDATE password_resets
+SET used_at = now()
+WHERE token_hash = $1
+  and used_at IS NULL
+  and expires_at > now()
+RETURNING *;
+-- name: ExpirePasswordResets :exec
+UPDATE password_resets
+SET used_at = now()
+WHERE user_id = $1
+  and used_at IS NULL;
+-- name: DeleteExpiredPasswordResets :exec
+DELETE FROM password_resets
+WHERE expires_at < now();
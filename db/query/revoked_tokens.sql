@@ -0,0 +1,22 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (id, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (id) DO NOTHING;
+-- name: RevokeUserTokens :exec
+INSERT INTO user_token_cutoffs (user_id, revoked_before)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET revoked_before = GREATEST(user_token_cutoffs.revoked_before, excluded.revoked_before);
+-- name: IsTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM revoked_tokens
+    WHERE revoked_tokens.id = sqlc.arg('id')
+  )
+  OR EXISTS (
+    SELECT 1 FROM user_token_cutoffs
+    WHERE user_token_cutoffs.user_id = sqlc.arg('user_id')
+      and user_token_cutoffs.revoked_before > sqlc.arg('issued_at')
+  ) AS revoked;
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now();
@@ -8,10 +8,39 @@ INSERT INTO sessions (
     client_ip,
     is_blocked,
     expires_at,
-    created_at
+    created_at,
+    family_id
   )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 RETURNING *;
 -- name: GetSession :one
 SELECT * FROM sessions
-WHERE id = $1 LIMIT 1;
\ No newline at end of file
+WHERE id = $1 LIMIT 1;
+-- name: ListActiveSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+  and is_blocked = false
+  and rotated_at IS NULL
+  and expires_at > now()
+ORDER BY created_at DESC;
+-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1
+  and user_id = $2
+RETURNING *;
+-- name: MarkSessionRotated :one
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1
+  and rotated_at IS NULL
+RETURNING *;
+-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1;
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE user_id = $1
+  and is_blocked = false;
@@ -0,0 +1,39 @@
+-- name: StartUserTOTP :one
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = excluded.secret,
+  last_used_step = 0,
+  created_at = now()
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1 LIMIT 1;
+-- name: ConfirmUserTOTP :one
+UPDATE user_totp
+SET confirmed_at = now(),
+  last_used_step = $2
+WHERE user_id = $1
+  and confirmed_at IS NULL
+RETURNING *;
+-- name: UseTOTPStep :one
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1
+  and confirmed_at IS NOT NULL
+  and last_used_step < $2
+RETURNING *;
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = now()
+WHERE user_id = $1
+  and code_hash = $2
+  and used_at IS NULL
+RETURNING *;
@@ -14,7 +14,10 @@ SELECT id,
   email,
   image,
   status,
-  created_at
+  created_at,
+  hide_email,
+  role,
+  email_verified_at
 FROM "Users"
 WHERE id = $1
 LIMIT 1;
@@ -28,7 +31,8 @@ SELECT id,
   name,
   email,
   image,
-  status
+  status,
+  hide_email
 FROM "Users"
 ORDER BY id
 LIMIT $1 OFFSET $2;
@@ -39,14 +43,44 @@ SET
     email = coalesce(sqlc.narg('email'), email),
     image = coalesce(sqlc.narg('image'), image),
     status = coalesce(sqlc.narg('status'), status),
-    hashed_pw = coalesce(sqlc.narg('hashed_pw'), hashed_pw)
+    hide_email = coalesce(sqlc.narg('hide_email'), hide_email),
+    -- a new address has to be verified again
+    email_verified_at = CASE
+      WHEN coalesce(sqlc.narg('email'), email) = email THEN email_verified_at
+    END
 where id = sqlc.arg('id')
 RETURNING id,
   name,
   email,
   image,
   status,
-  created_at;
+  created_at,
+  hide_email,
+  email_verified_at;
+-- name: UpdateUserRole :one
+UPDATE "Users"
+SET role = $2
+WHERE id = $1
+RETURNING id,
+  name,
+  email,
+  role;
+-- name: GetUserPassword :one
+SELECT hashed_pw
+FROM "Users"
+WHERE id = $1
+LIMIT 1;
+-- name: UpdateUserPassword :exec
+UPDATE "Users"
+SET hashed_pw = $2
+WHERE id = $1;
+-- name: MarkEmailVerified :one
+UPDATE "Users"
+SET email_verified_at = now()
+WHERE id = $1
+  and email = $2
+  and email_verified_at IS NULL
+RETURNING *;
 -- name: DeleteUser :exec
 DELETE FROM "Users"
 WHERE id = $1;
@@ -62,9 +96,37 @@ inner JOIN "Message" on "Conversation".id = "Message".conv_id
 Where "Users".id = $1;
 -- name: ListConvFromUser :many
 SELECT 
-"Conversation".id,"Conversation".name
+"Conversation".id,"Conversation".name,"Conversation".topic,"Conversation".description,"Conversation".created_at,"Conversation".kind,"Conversation".dm_user_low,"Conversation".dm_user_high
 FROM
 "Users"
 INNER JOIN "user_conversation" on "Users".id = "user_conversation".user_id
 INNER JOIN "Conversation" on "user_conversation".conv_id = "Conversation".id
-WHERE "Users".id = $1;
\ No newline at end of file
+WHERE "Users".id = $1;
+-- name: SearchUsers :many
+SELECT id,
+  name,
+  email,
+  image,
+  status,
+  hide_email
+FROM "Users"
+WHERE id <> sqlc.arg('user_id')
+  and (
+    name ILIKE sqlc.arg('prefix')
+    OR name % sqlc.arg('query')
+    OR (
+      hide_email = false
+      and (
+        email ILIKE sqlc.arg('prefix')
+        OR email % sqlc.arg('query')
+      )
+    )
+  )
+ORDER BY name ILIKE sqlc.arg('prefix')
+  OR (
+    hide_email = false
+    and email ILIKE sqlc.arg('prefix')
+  ) DESC,
+  similarity(name, sqlc.arg('query')) DESC,
+  id
+LIMIT sqlc.arg('limit');
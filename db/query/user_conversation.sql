@@ -26,4 +26,43 @@ WHERE user_id = $1
   and conv_id = $2;
 -- name: DeleteUser_conversation_by_id :exec
 DELETE FROM "user_conversation"
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+-- name: ListConvMembers :many
+SELECT user_id
+from "user_conversation"
+WHERE conv_id = $1
+ORDER BY user_id;
+-- name: CreateConvMember :one
+INSERT INTO "user_conversation" (user_id, conv_id, role)
+VALUES($1, $2, $3)
+RETURNING *;
+-- name: UpdateConvMemberRole :one
+UPDATE "user_conversation"
+SET role = $3
+WHERE user_id = $1
+  and conv_id = $2
+RETURNING *;
+-- name: GetConvSuccessor :one
+SELECT *
+from "user_conversation"
+WHERE conv_id = $1
+  and user_id <> $2
+ORDER BY role = 'admin' DESC, id
+LIMIT 1;
+-- name: MarkConvRead :one
+UPDATE "user_conversation"
+SET last_read_message_id = GREATEST(
+    last_read_message_id,
+    (
+      SELECT max(id)
+      FROM "Message"
+      WHERE "Message".conv_id = sqlc.arg('conv_id')
+        and (
+          sqlc.narg('message_id')::bigint IS NULL
+          OR "Message".id <= sqlc.narg('message_id')
+        )
+    )
+  )
+WHERE user_id = sqlc.arg('user_id')
+  and conv_id = sqlc.arg('conv_id')
+RETURNING *;
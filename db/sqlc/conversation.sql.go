@@ -14,16 +14,73 @@ import (
 const createConversation = `-- name: CreateConversation :one
 INSERT INTO "Conversation" (name)
 VALUES($1)
-RETURNING id, name
+RETURNING id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
 `
 
 func (q *Queries) CreateConversation(ctx context.Context, name sql.NullString) (Conversation, error) {
 	row := q.db.QueryRowContext(ctx, createConversation, name)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Topic,
+		&i.Description,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.DmUserLow,
+		&i.DmUserHigh,
+	)
 	return i, err
 }
 
+const createDirectConversation = `-- name: CreateDirectConversation :one
+INSERT INTO "Conversation" (name, kind, dm_user_low, dm_user_high)
+VALUES($1, 'direct', $2, $3) ON CONFLICT (dm_user_low, dm_user_high) DO NOTHING
+RETURNING id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
+`
+
+type CreateDirectConversationParams struct {
+	Name       sql.NullString `json:"name"`
+	DmUserLow  sql.NullInt64  `json:"dmUserLow"`
+	DmUserHigh sql.NullInt64  `json:"dmUserHigh"`
+}
+
+func (q *Queries) CreateDirectConversation(ctx context.Context, arg CreateDirectConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createDirectConversation, arg.Name, arg.DmUserLow, arg.DmUserHigh)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Topic,
+		&i.Description,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.DmUserLow,
+		&i.DmUserHigh,
+	)
+	return i, err
+}
+
+const deleteConvMembers = `-- name: DeleteConvMembers :exec
+DELETE FROM "user_conversation"
+WHERE conv_id = $1
+`
+
+func (q *Queries) DeleteConvMembers(ctx context.Context, convID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteConvMembers, convID)
+	return err
+}
+
+const deleteConvMessages = `-- name: DeleteConvMessages :exec
+DELETE FROM "Message"
+WHERE conv_id = $1
+`
+
+func (q *Queries) DeleteConvMessages(ctx context.Context, convID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteConvMessages, convID)
+	return err
+}
+
 const deleteConversation = `-- name: DeleteConversation :exec
 DELETE FROM "Conversation"
 WHERE ID = $1
@@ -35,7 +92,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, id int64) error {
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT id, name
+SELECT id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
 FROM "Conversation"
 WHERE id = $1
 LIMIT 1
@@ -44,17 +101,56 @@ LIMIT 1
 func (q *Queries) GetConversation(ctx context.Context, id int64) (Conversation, error) {
 	row := q.db.QueryRowContext(ctx, getConversation, id)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Topic,
+		&i.Description,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.DmUserLow,
+		&i.DmUserHigh,
+	)
+	return i, err
+}
+
+const getDirectConversation = `-- name: GetDirectConversation :one
+SELECT id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
+FROM "Conversation"
+WHERE dm_user_low = $1
+  and dm_user_high = $2
+`
+
+type GetDirectConversationParams struct {
+	DmUserLow  sql.NullInt64 `json:"dmUserLow"`
+	DmUserHigh sql.NullInt64 `json:"dmUserHigh"`
+}
+
+func (q *Queries) GetDirectConversation(ctx context.Context, arg GetDirectConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getDirectConversation, arg.DmUserLow, arg.DmUserHigh)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Topic,
+		&i.Description,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.DmUserLow,
+		&i.DmUserHigh,
+	)
 	return i, err
 }
 
 const listConvMessages = `-- name: ListConvMessages :many
 SELECT 
-"Message".from,"Message".content as message_content,"Message".created_at, "Message".id as message_id
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
 FROM
 "user_conversation"
 INNER JOIN "Conversation" on "user_conversation".conv_id = "Conversation".id
 INNER JOIN "Message" on "Conversation".id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
 Where
 "user_conversation".conv_id = $1
 And "user_conversation".user_id=$2
@@ -66,10 +162,15 @@ type ListConvMessagesParams struct {
 }
 
 type ListConvMessagesRow struct {
-	From           string    `json:"from"`
-	MessageContent string    `json:"messageContent"`
-	CreatedAt      time.Time `json:"createdAt"`
-	MessageID      int64     `json:"messageID"`
+	SenderID       sql.NullInt64  `json:"senderID"`
+	SenderName     string         `json:"senderName"`
+	SenderImage    sql.NullString `json:"senderImage"`
+	MessageContent string         `json:"messageContent"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	MessageID      int64          `json:"messageID"`
+	EditedAt       sql.NullTime   `json:"editedAt"`
+	DeletedAt      sql.NullTime   `json:"deletedAt"`
+	IsSystem       bool           `json:"isSystem"`
 }
 
 func (q *Queries) ListConvMessages(ctx context.Context, arg ListConvMessagesParams) ([]ListConvMessagesRow, error) {
@@ -82,10 +183,263 @@ func (q *Queries) ListConvMessages(ctx context.Context, arg ListConvMessagesPara
 	for rows.Next() {
 		var i ListConvMessagesRow
 		if err := rows.Scan(
-			&i.From,
+			&i.SenderID,
+			&i.SenderName,
+			&i.SenderImage,
+			&i.MessageContent,
+			&i.CreatedAt,
+			&i.MessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.IsSystem,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConvMessagesAfter = `-- name: ListConvMessagesAfter :many
+SELECT 
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
+FROM
+"user_conversation"
+INNER JOIN "Message" on "user_conversation".conv_id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
+Where
+"user_conversation".conv_id = $1
+And "user_conversation".user_id = $2
+And ("Message".created_at, "Message".id) > (
+  SELECT created_at, id FROM "Message"
+  WHERE id = $3
+    and conv_id = $1
+)
+ORDER BY "Message".created_at, "Message".id
+LIMIT $4
+`
+
+type ListConvMessagesAfterParams struct {
+	ConvID int64 `json:"convID"`
+	UserID int64 `json:"userID"`
+	After  int64 `json:"after"`
+	Limit  int32 `json:"limit"`
+}
+
+type ListConvMessagesAfterRow struct {
+	SenderID       sql.NullInt64  `json:"senderID"`
+	SenderName     string         `json:"senderName"`
+	SenderImage    sql.NullString `json:"senderImage"`
+	MessageContent string         `json:"messageContent"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	MessageID      int64          `json:"messageID"`
+	EditedAt       sql.NullTime   `json:"editedAt"`
+	DeletedAt      sql.NullTime   `json:"deletedAt"`
+	IsSystem       bool           `json:"isSystem"`
+}
+
+func (q *Queries) ListConvMessagesAfter(ctx context.Context, arg ListConvMessagesAfterParams) ([]ListConvMessagesAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConvMessagesAfter,
+		arg.ConvID,
+		arg.UserID,
+		arg.After,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListConvMessagesAfterRow{}
+	for rows.Next() {
+		var i ListConvMessagesAfterRow
+		if err := rows.Scan(
+			&i.SenderID,
+			&i.SenderName,
+			&i.SenderImage,
+			&i.MessageContent,
+			&i.CreatedAt,
+			&i.MessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.IsSystem,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConvMessagesBefore = `-- name: ListConvMessagesBefore :many
+SELECT 
+"Message".sender_id, coalesce("Users".name, "Message".from) as sender_name, "Users".image as sender_image,
+"Message".content as message_content,"Message".created_at, "Message".id as message_id, "Message".edited_at, "Message".deleted_at, "Message".is_system
+FROM
+"user_conversation"
+INNER JOIN "Message" on "user_conversation".conv_id = "Message".conv_id
+LEFT JOIN "Users" on "Users".id = "Message".sender_id
+Where
+"user_conversation".conv_id = $1
+And "user_conversation".user_id = $2
+And (
+  $3::bigint IS NULL
+  OR ("Message".created_at, "Message".id) < (
+    SELECT created_at, id FROM "Message"
+    WHERE id = $3
+      and conv_id = $1
+  )
+)
+ORDER BY "Message".created_at DESC, "Message".id DESC
+LIMIT $4
+`
+
+type ListConvMessagesBeforeParams struct {
+	ConvID int64         `json:"convID"`
+	UserID int64         `json:"userID"`
+	Before sql.NullInt64 `json:"before"`
+	Limit  int32         `json:"limit"`
+}
+
+type ListConvMessagesBeforeRow struct {
+	SenderID       sql.NullInt64  `json:"senderID"`
+	SenderName     string         `json:"senderName"`
+	SenderImage    sql.NullString `json:"senderImage"`
+	MessageContent string         `json:"messageContent"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	MessageID      int64          `json:"messageID"`
+	EditedAt       sql.NullTime   `json:"editedAt"`
+	DeletedAt      sql.NullTime   `json:"deletedAt"`
+	IsSystem       bool           `json:"isSystem"`
+}
+
+func (q *Queries) ListConvMessagesBefore(ctx context.Context, arg ListConvMessagesBeforeParams) ([]ListConvMessagesBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConvMessagesBefore,
+		arg.ConvID,
+		arg.UserID,
+		arg.Before,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListConvMessagesBeforeRow{}
+	for rows.Next() {
+		var i ListConvMessagesBeforeRow
+		if err := rows.Scan(
+			&i.SenderID,
+			&i.SenderName,
+			&i.SenderImage,
 			&i.MessageContent,
 			&i.CreatedAt,
 			&i.MessageID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.IsSystem,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConvSummaries = `-- name: ListConvSummaries :many
+SELECT "Conversation".id,
+  "Conversation".name,
+  "Conversation".topic,
+  "Conversation".description,
+  "Conversation".kind,
+  "user_conversation".last_read_message_id,
+  (
+    SELECT count(*)
+    FROM "Message"
+    WHERE "Message".conv_id = "Conversation".id
+      and "Message".id > coalesce("user_conversation".last_read_message_id, 0)
+      and "Message".sender_id IS DISTINCT FROM "user_conversation".user_id
+      and "Message".deleted_at IS NULL
+  ) as unread_count,
+  last_message.id as last_message_id,
+  last_message.content as last_message_content,
+  coalesce("Users".name, last_message.from) as last_message_from,
+  last_message.created_at as last_message_at,
+  coalesce(last_message.created_at, "Conversation".created_at) as last_activity_at
+FROM "user_conversation"
+  INNER JOIN "Conversation" on "user_conversation".conv_id = "Conversation".id
+  LEFT JOIN LATERAL (
+    SELECT "Message".id,
+      "Message".from,
+      "Message".content,
+      "Message".created_at,
+      "Message".sender_id
+    FROM "Message"
+    WHERE "Message".conv_id = "Conversation".id
+    ORDER BY "Message".created_at DESC,
+      "Message".id DESC
+    LIMIT 1
+  ) last_message on true
+  LEFT JOIN "Users" on "Users".id = last_message.sender_id
+WHERE "user_conversation".user_id = $1
+ORDER BY last_activity_at DESC,
+  "Conversation".id DESC
+`
+
+type ListConvSummariesRow struct {
+	ID                 int64          `json:"id"`
+	Name               sql.NullString `json:"name"`
+	Topic              sql.NullString `json:"topic"`
+	Description        sql.NullString `json:"description"`
+	Kind               string         `json:"kind"`
+	LastReadMessageID  sql.NullInt64  `json:"lastReadMessageID"`
+	UnreadCount        int64          `json:"unreadCount"`
+	LastMessageID      sql.NullInt64  `json:"lastMessageID"`
+	LastMessageContent sql.NullString `json:"lastMessageContent"`
+	LastMessageFrom    sql.NullString `json:"lastMessageFrom"`
+	LastMessageAt      sql.NullTime   `json:"lastMessageAt"`
+	LastActivityAt     time.Time      `json:"lastActivityAt"`
+}
+
+func (q *Queries) ListConvSummaries(ctx context.Context, userID int64) ([]ListConvSummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConvSummaries, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListConvSummariesRow{}
+	for rows.Next() {
+		var i ListConvSummariesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Topic,
+			&i.Description,
+			&i.Kind,
+			&i.LastReadMessageID,
+			&i.UnreadCount,
+			&i.LastMessageID,
+			&i.LastMessageContent,
+			&i.LastMessageFrom,
+			&i.LastMessageAt,
+			&i.LastActivityAt,
 		); err != nil {
 			return nil, err
 		}
@@ -101,7 +455,7 @@ func (q *Queries) ListConvMessages(ctx context.Context, arg ListConvMessagesPara
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT id, name
+SELECT id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
 FROM "Conversation"
 ORDER BY id
 LIMIT $1 OFFSET $2
@@ -121,7 +475,16 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 	items := []Conversation{}
 	for rows.Next() {
 		var i Conversation
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Topic,
+			&i.Description,
+			&i.CreatedAt,
+			&i.Kind,
+			&i.DmUserLow,
+			&i.DmUserHigh,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -137,19 +500,37 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 
 const updateConversation = `-- name: UpdateConversation :one
 UPDATE "Conversation"
-SET name = $2
-WHERE ID = $1
-returning id, name
+SET name = coalesce($1, name),
+  topic = coalesce($2, topic),
+  description = coalesce($3, description)
+WHERE ID = $4
+returning id, name, topic, description, created_at, kind, dm_user_low, dm_user_high
 `
 
 type UpdateConversationParams struct {
-	ID   int64          `json:"id"`
-	Name sql.NullString `json:"name"`
+	Name        sql.NullString `json:"name"`
+	Topic       sql.NullString `json:"topic"`
+	Description sql.NullString `json:"description"`
+	ID          int64          `json:"id"`
 }
 
 func (q *Queries) UpdateConversation(ctx context.Context, arg UpdateConversationParams) (Conversation, error) {
-	row := q.db.QueryRowContext(ctx, updateConversation, arg.ID, arg.Name)
+	row := q.db.QueryRowContext(ctx, updateConversation,
+		arg.Name,
+		arg.Topic,
+		arg.Description,
+		arg.ID,
+	)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.Name)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Topic,
+		&i.Description,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.DmUserLow,
+		&i.DmUserHigh,
+	)
 	return i, err
 }
@@ -67,6 +67,14 @@ func TestUpdateConv(t *testing.T) {
 	require.NotEmpty(t, conv2)
 	require.Equal(t, conv2.Name.String, nName)
 	require.Len(t, conv2.Name.String, 5)
+
+	// unset fields are left alone
+	topic := util.NullStrGen(8)
+	conv3, err := testQueries.UpdateConversation(context.Background(), UpdateConversationParams{ID: conv1.ID, Topic: topic})
+	require.NoError(t, err)
+	require.Equal(t, conv2.Name, conv3.Name)
+	require.Equal(t, topic, conv3.Topic)
+	require.False(t, conv3.Description.Valid)
 }
 
 func TestDeleteConv(t *testing.T) {
@@ -113,9 +121,144 @@ func TestGetConvMessages(t *testing.T) {
 	for _, msg := range list {
 
 		require.NotEmpty(t, msg)
-		require.Len(t, msg.From, 10)
+		// no sender_id, so the name stored on the message is used
+		require.False(t, msg.SenderID.Valid)
+		require.Len(t, msg.SenderName, 10)
 		require.Len(t, msg.MessageContent, 50)
 		require.NotZero(t, msg.MessageID)
 		require.WithinDuration(t, now, msg.CreatedAt, time.Second)
 	}
 }
+
+func TestListConvMessagesPages(t *testing.T) {
+	conv := createRandConv(t)
+	user := createRandomUser(t)
+
+	_, err := testQueries.CreateUser_conversation(context.Background(), CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
+	require.NoError(t, err)
+
+	n := 10
+	sent := make([]Message, n)
+	for i := 0; i < n; i++ {
+		sent[i], err = testQueries.CreateMessage(context.Background(), CreateMessageParams{
+			From:     user.Name,
+			Content:  util.RandomString(50),
+			ConvID:   conv.ID,
+			SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+		require.NoError(t, err)
+	}
+
+	latest, err := testQueries.ListConvMessagesBefore(context.Background(), ListConvMessagesBeforeParams{
+		ConvID: conv.ID,
+		UserID: user.ID,
+		Limit:  4,
+	})
+	require.NoError(t, err)
+	require.Len(t, latest, 4)
+	require.Equal(t, sent[n-1].ID, latest[0].MessageID)
+
+	older, err := testQueries.ListConvMessagesBefore(context.Background(), ListConvMessagesBeforeParams{
+		ConvID: conv.ID,
+		UserID: user.ID,
+		Before: sql.NullInt64{Int64: latest[3].MessageID, Valid: true},
+		Limit:  int32(n),
+	})
+	require.NoError(t, err)
+	require.Len(t, older, n-4)
+	require.Equal(t, sent[n-5].ID, older[0].MessageID)
+	require.Equal(t, sent[0].ID, older[n-5].MessageID)
+
+	newer, err := testQueries.ListConvMessagesAfter(context.Background(), ListConvMessagesAfterParams{
+		ConvID: conv.ID,
+		UserID: user.ID,
+		After:  sent[2].ID,
+		Limit:  3,
+	})
+	require.NoError(t, err)
+	require.Len(t, newer, 3)
+	for i, msg := range newer {
+		require.Equal(t, sent[i+3].ID, msg.MessageID)
+	}
+
+	// a cursor from another conversation matches nothing
+	foreign := createRandMessage(t)
+	none, err := testQueries.ListConvMessagesBefore(context.Background(), ListConvMessagesBeforeParams{
+		ConvID: conv.ID,
+		UserID: user.ID,
+		Before: sql.NullInt64{Int64: foreign.ID, Valid: true},
+		Limit:  int32(n),
+	})
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	outsider := createRandomUser(t)
+	hidden, err := testQueries.ListConvMessagesBefore(context.Background(), ListConvMessagesBeforeParams{
+		ConvID: conv.ID,
+		UserID: outsider.ID,
+		Limit:  4,
+	})
+	require.NoError(t, err)
+	require.Empty(t, hidden)
+}
+
+func TestListConvSummaries(t *testing.T) {
+	user := createRandomUser(t)
+	sender := createRandomUser(t)
+
+	quiet := createRandConv(t)
+	busy := createRandConv(t)
+	for _, conv := range []Conversation{quiet, busy} {
+		_, err := testQueries.CreateUser_conversation(context.Background(), CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
+		require.NoError(t, err)
+	}
+
+	var last Message
+	for i := 0; i < 3; i++ {
+		var err error
+		last, err = testQueries.CreateMessage(context.Background(), CreateMessageParams{
+			From:     sender.Name,
+			Content:  util.RandomString(20),
+			ConvID:   busy.ID,
+			SenderID: sql.NullInt64{Int64: sender.ID, Valid: true},
+		})
+		require.NoError(t, err)
+	}
+	// the user's own messages are never unread
+	_, err := testQueries.CreateMessage(context.Background(), CreateMessageParams{
+		From:     user.Name,
+		Content:  util.RandomString(20),
+		ConvID:   quiet.ID,
+		SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	own, err := testQueries.CreateMessage(context.Background(), CreateMessageParams{
+		From:     user.Name,
+		Content:  util.RandomString(20),
+		ConvID:   quiet.ID,
+		SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	list, err := testQueries.ListConvSummaries(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	require.Equal(t, quiet.ID, list[0].ID)
+	require.Zero(t, list[0].UnreadCount)
+	require.Equal(t, own.ID, list[0].LastMessageID.Int64)
+	require.Equal(t, user.Name, list[0].LastMessageFrom.String)
+
+	require.Equal(t, busy.ID, list[1].ID)
+	require.Equal(t, int64(3), list[1].UnreadCount)
+	require.Equal(t, last.ID, list[1].LastMessageID.Int64)
+	require.Equal(t, last.Content, list[1].LastMessageContent.String)
+	require.Equal(t, sender.Name, list[1].LastMessageFrom.String)
+	require.WithinDuration(t, last.CreatedAt, list[1].LastActivityAt, time.Millisecond)
+
+	_, err = testQueries.MarkConvRead(context.Background(), MarkConvReadParams{ConvID: busy.ID, UserID: user.ID})
+	require.NoError(t, err)
+	list, err = testQueries.ListConvSummaries(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Zero(t, list[1].UnreadCount)
+}
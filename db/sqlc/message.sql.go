@@ -7,22 +7,65 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO "Message" ("from", content, conv_id)
-VALUES($1, $2, $3)
-RETURNING id, "from", content, created_at, conv_id
+INSERT INTO "Message" ("from", content, conv_id, sender_id)
+VALUES($1, $2, $3, $4)
+RETURNING id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
 `
 
 type CreateMessageParams struct {
-	From    string `json:"from"`
-	Content string `json:"content"`
-	ConvID  int64  `json:"convID"`
+	From     string        `json:"from"`
+	Content  string        `json:"content"`
+	ConvID   int64         `json:"convID"`
+	SenderID sql.NullInt64 `json:"senderID"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
-	row := q.db.QueryRowContext(ctx, createMessage, arg.From, arg.Content, arg.ConvID)
+	row := q.db.QueryRowContext(ctx, createMessage,
+		arg.From,
+		arg.Content,
+		arg.ConvID,
+		arg.SenderID,
+	)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.From,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ConvID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.SenderID,
+		&i.IsSystem,
+	)
+	return i, err
+}
+
+const createSystemMessage = `-- name: CreateSystemMessage :one
+INSERT INTO "Message" ("from", content, conv_id, sender_id, is_system)
+VALUES($1, $2, $3, $4, true)
+RETURNING id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
+`
+
+type CreateSystemMessageParams struct {
+	From     string        `json:"from"`
+	Content  string        `json:"content"`
+	ConvID   int64         `json:"convID"`
+	SenderID sql.NullInt64 `json:"senderID"`
+}
+
+func (q *Queries) CreateSystemMessage(ctx context.Context, arg CreateSystemMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createSystemMessage,
+		arg.From,
+		arg.Content,
+		arg.ConvID,
+		arg.SenderID,
+	)
 	var i Message
 	err := row.Scan(
 		&i.ID,
@@ -30,6 +73,10 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.Content,
 		&i.CreatedAt,
 		&i.ConvID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.SenderID,
+		&i.IsSystem,
 	)
 	return i, err
 }
@@ -45,7 +92,7 @@ func (q *Queries) DeleteMessage(ctx context.Context, id int64) error {
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, "from", content, created_at, conv_id
+SELECT id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
 from "Message"
 WHERE id = $1
 `
@@ -59,19 +106,89 @@ func (q *Queries) GetMessage(ctx context.Context, id int64) (Message, error) {
 		&i.Content,
 		&i.CreatedAt,
 		&i.ConvID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.SenderID,
+		&i.IsSystem,
 	)
 	return i, err
 }
 
+const listConvMessagesSince = `-- name: ListConvMessagesSince :many
+SELECT "Message".id,
+  "Message".content,
+  "Message".created_at,
+  "Message".conv_id,
+  "Message".sender_id,
+  coalesce("Users".name, "Message".from) as sender_name,
+  "Users".image as sender_image,
+  "Message".is_system
+from "Message"
+  LEFT JOIN "Users" on "Users".id = "Message".sender_id
+WHERE "Message".conv_id = $1
+  and "Message".id > $2
+ORDER BY "Message".id
+LIMIT $3
+`
+
+type ListConvMessagesSinceParams struct {
+	ConvID int64 `json:"convID"`
+	ID     int64 `json:"id"`
+	Limit  int32 `json:"limit"`
+}
+
+type ListConvMessagesSinceRow struct {
+	ID          int64          `json:"id"`
+	Content     string         `json:"content"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ConvID      int64          `json:"convID"`
+	SenderID    sql.NullInt64  `json:"senderID"`
+	SenderName  string         `json:"senderName"`
+	SenderImage sql.NullString `json:"senderImage"`
+	IsSystem    bool           `json:"isSystem"`
+}
+
+func (q *Queries) ListConvMessagesSince(ctx context.Context, arg ListConvMessagesSinceParams) ([]ListConvMessagesSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConvMessagesSince, arg.ConvID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListConvMessagesSinceRow{}
+	for rows.Next() {
+		var i ListConvMessagesSinceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ConvID,
+			&i.SenderID,
+			&i.SenderName,
+			&i.SenderImage,
+			&i.IsSystem,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessageByUser = `-- name: ListMessageByUser :many
-SELECT id, "from", content, created_at, conv_id
+SELECT id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
 from "Message"
-WHERE "from" = $1
+WHERE sender_id = $1
 ORDER BY created_at
 `
 
-func (q *Queries) ListMessageByUser(ctx context.Context, from string) ([]Message, error) {
-	rows, err := q.db.QueryContext(ctx, listMessageByUser, from)
+func (q *Queries) ListMessageByUser(ctx context.Context, senderID sql.NullInt64) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessageByUser, senderID)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +202,124 @@ func (q *Queries) ListMessageByUser(ctx context.Context, from string) ([]Message
 			&i.Content,
 			&i.CreatedAt,
 			&i.ConvID,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.SenderID,
+			&i.IsSystem,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT "Message".id,
+  "Message".conv_id,
+  "Message".sender_id,
+  coalesce("Users".name, "Message".from) as sender_name,
+  "Message".created_at,
+  -- escaped first so the only markup in the snippet is the <mark> it adds
+  ts_headline(
+    'english',
+    replace(
+      replace(replace("Message".content, '&', '&amp;'), '<', '&lt;'),
+      '>',
+      '&gt;'
+    ),
+    query,
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+  ) as snippet,
+  ts_rank(to_tsvector('english', "Message".content), query) as rank
+FROM "Message"
+  CROSS JOIN websearch_to_tsquery('english', $1) query
+  INNER JOIN "user_conversation" on "user_conversation".conv_id = "Message".conv_id
+  and "user_conversation".user_id = $2
+  LEFT JOIN "Users" on "Users".id = "Message".sender_id
+WHERE to_tsvector('english', "Message".content) @@ query
+  and "Message".deleted_at IS NULL
+  and "Message".is_system = false
+  and (
+    $3::bigint IS NULL
+    OR "Message".conv_id = $3
+  )
+  and (
+    $4::bigint IS NULL
+    OR "Message".sender_id = $4
+  )
+  and (
+    $5::timestamptz IS NULL
+    OR "Message".created_at < $5
+  )
+  and (
+    $6::timestamptz IS NULL
+    OR "Message".created_at > $6
+  )
+  and (
+    $7::real IS NULL
+    OR (ts_rank(to_tsvector('english', "Message".content), query), "Message".id) < ($7, $8::bigint)
+  )
+ORDER BY rank DESC,
+  "Message".id DESC
+LIMIT $9
+`
+
+type SearchMessagesParams struct {
+	Query      string          `json:"query"`
+	UserID     int64           `json:"userID"`
+	ConvID     sql.NullInt64   `json:"convID"`
+	SenderID   sql.NullInt64   `json:"senderID"`
+	Before     sql.NullTime    `json:"before"`
+	After      sql.NullTime    `json:"after"`
+	CursorRank sql.NullFloat64 `json:"cursorRank"`
+	CursorID   sql.NullInt64   `json:"cursorID"`
+	Limit      int32           `json:"limit"`
+}
+
+type SearchMessagesRow struct {
+	ID         int64         `json:"id"`
+	ConvID     int64         `json:"convID"`
+	SenderID   sql.NullInt64 `json:"senderID"`
+	SenderName string        `json:"senderName"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	Snippet    string        `json:"snippet"`
+	Rank       float32       `json:"rank"`
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchMessages,
+		arg.Query,
+		arg.UserID,
+		arg.ConvID,
+		arg.SenderID,
+		arg.Before,
+		arg.After,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchMessagesRow{}
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConvID,
+			&i.SenderID,
+			&i.SenderName,
+			&i.CreatedAt,
+			&i.Snippet,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -98,3 +333,60 @@ func (q *Queries) ListMessageByUser(ctx context.Context, from string) ([]Message
 	}
 	return items, nil
 }
+
+const tombstoneMessage = `-- name: TombstoneMessage :one
+UPDATE "Message"
+SET content = 'message deleted',
+  deleted_at = now()
+WHERE id = $1
+  and deleted_at IS NULL
+RETURNING id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
+`
+
+func (q *Queries) TombstoneMessage(ctx context.Context, id int64) (Message, error) {
+	row := q.db.QueryRowContext(ctx, tombstoneMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.From,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ConvID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.SenderID,
+		&i.IsSystem,
+	)
+	return i, err
+}
+
+const updateMessageContent = `-- name: UpdateMessageContent :one
+UPDATE "Message"
+SET content = $2,
+  edited_at = now()
+WHERE id = $1
+  and deleted_at IS NULL
+RETURNING id, "from", content, created_at, conv_id, edited_at, deleted_at, sender_id, is_system
+`
+
+type UpdateMessageContentParams struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+func (q *Queries) UpdateMessageContent(ctx context.Context, arg UpdateMessageContentParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, updateMessageContent, arg.ID, arg.Content)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.From,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ConvID,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.SenderID,
+		&i.IsSystem,
+	)
+	return i, err
+}
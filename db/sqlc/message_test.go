@@ -20,6 +20,7 @@ func createRandMessage(t *testing.T) Message {
 		Content: util.RandomString(50),
 		ConvID: conv.ID,
 		From: user.Name,
+		SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
 	}
 	message, err := testQueries.CreateMessage(context.Background(), arg)
 	require.NoError(t, err)
@@ -27,6 +28,7 @@ func createRandMessage(t *testing.T) Message {
 
 	require.Equal(t, arg.Content, message.Content)
 	require.Equal(t, arg.From, message.From)
+	require.Equal(t, user.ID, message.SenderID.Int64)
 	require.NotEmpty(t, message.CreatedAt)
 
 	return message
@@ -58,6 +60,7 @@ func TestListMessages(t *testing.T) {
 		Content: util.RandomString(50),
 		ConvID: conv.ID,
 		From: user.Name,
+		SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
 	}
 
 	for i := 0; i < 20; i++ {
@@ -66,7 +69,7 @@ func TestListMessages(t *testing.T) {
 		require.NotEmpty(t,msg)
 	}
 
-	messages, err := testQueries.ListMessageByUser(context.Background(), user.Name)
+	messages, err := testQueries.ListMessageByUser(context.Background(), arg.SenderID)
 
 	require.NoError(t, err)
 	require.Len(t, messages, 20)
@@ -89,3 +92,141 @@ func TestDeleteMessage(t *testing.T) {
 	require.EqualError(t, err, sql.ErrNoRows.Error())
 	require.Empty(t, msg2)
 }
+
+func TestListConvMessagesSince(t *testing.T) {
+	user := createRandomUser(t)
+	conv := createRandConv(t)
+
+	var sent []Message
+	for i := 0; i < 10; i++ {
+		msg, err := testQueries.CreateMessage(context.Background(), CreateMessageParams{
+			From:     user.Name,
+			Content:  util.RandomString(50),
+			ConvID:   conv.ID,
+			SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+		require.NoError(t, err)
+		sent = append(sent, msg)
+	}
+
+	messages, err := testQueries.ListConvMessagesSince(context.Background(), ListConvMessagesSinceParams{
+		ConvID: conv.ID,
+		ID:     sent[4].ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 5)
+	for i, msg := range messages {
+		require.Equal(t, sent[i+5].ID, msg.ID)
+		require.Equal(t, conv.ID, msg.ConvID)
+		require.Equal(t, user.Name, msg.SenderName)
+	}
+
+	// the oldest missed messages come first
+	messages, err = testQueries.ListConvMessagesSince(context.Background(), ListConvMessagesSinceParams{
+		ConvID: conv.ID,
+		ID:     sent[0].ID,
+		Limit:  3,
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	require.Equal(t, sent[1].ID, messages[0].ID)
+}
+
+func TestMessageSenderRenamed(t *testing.T) {
+	msg := createRandMessage(t)
+
+	renamed, err := testQueries.UpdateUserInfo(context.Background(), UpdateUserInfoParams{
+		ID:   msg.SenderID.Int64,
+		Name: sql.NullString{String: util.RandomUserGen(), Valid: true},
+	})
+	require.NoError(t, err)
+
+	messages, err := testQueries.ListConvMessagesSince(context.Background(), ListConvMessagesSinceParams{
+		ConvID: msg.ConvID,
+		ID:     msg.ID - 1,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, renamed.ID, messages[0].SenderID.Int64)
+	require.Equal(t, renamed.Name, messages[0].SenderName)
+
+	byUser, err := testQueries.ListMessageByUser(context.Background(), msg.SenderID)
+	require.NoError(t, err)
+	require.Len(t, byUser, 1)
+	require.Equal(t, msg.ID, byUser[0].ID)
+}
+
+func TestSearchMessages(t *testing.T) {
+	user := createRandomUser(t)
+	conv := createRandConv(t)
+	other := createRandConv(t)
+	_, err := testQueries.CreateUser_conversation(context.Background(), CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
+	require.NoError(t, err)
+
+	// a random word keeps other tests' messages out of the results
+	word := util.RandomString(12)
+	send := func(convID int64, content string) Message {
+		msg, err := testQueries.CreateMessage(context.Background(), CreateMessageParams{
+			From:     user.Name,
+			Content:  content,
+			ConvID:   convID,
+			SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+		require.NoError(t, err)
+		return msg
+	}
+	strong := send(conv.ID, word+" "+word+" "+word)
+	weak := send(conv.ID, "something about "+word+" and a lot of other words around it")
+	send(conv.ID, util.RandomString(20))
+	send(other.ID, word)
+	deleted := send(conv.ID, word)
+	_, err = testQueries.TombstoneMessage(context.Background(), deleted.ID)
+	require.NoError(t, err)
+
+	arg := SearchMessagesParams{Query: word, UserID: user.ID, Limit: 1}
+	page, err := testQueries.SearchMessages(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, strong.ID, page[0].ID)
+	require.Contains(t, page[0].Snippet, "<mark>")
+	require.Equal(t, user.Name, page[0].SenderName)
+
+	arg.CursorRank = sql.NullFloat64{Float64: float64(page[0].Rank), Valid: true}
+	arg.CursorID = sql.NullInt64{Int64: page[0].ID, Valid: true}
+	arg.Limit = 10
+	rest, err := testQueries.SearchMessages(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	require.Equal(t, weak.ID, rest[0].ID)
+
+	filtered, err := testQueries.SearchMessages(context.Background(), SearchMessagesParams{
+		Query:  word,
+		UserID: user.ID,
+		Before: sql.NullTime{Time: weak.CreatedAt, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	require.Equal(t, strong.ID, filtered[0].ID)
+
+	// markup in a message comes back escaped, the highlight is the only tag
+	markup := send(conv.ID, "<img src=x onerror=alert(1)> "+word+"</b>")
+	escaped, err := testQueries.SearchMessages(context.Background(), SearchMessagesParams{
+		Query:  word,
+		UserID: user.ID,
+		After:  sql.NullTime{Time: markup.CreatedAt.Add(-time.Microsecond), Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, escaped, 1)
+	require.Contains(t, escaped[0].Snippet, "&lt;img")
+	require.Contains(t, escaped[0].Snippet, "&lt;/b&gt;")
+	require.NotContains(t, escaped[0].Snippet, "<img")
+
+	outsider := createRandomUser(t)
+	hidden, err := testQueries.SearchMessages(context.Background(), SearchMessagesParams{Query: word, UserID: outsider.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, hidden)
+}
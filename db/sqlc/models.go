@@ -12,42 +12,111 @@ import (
 )
 
 type Conversation struct {
-	ID   int64          `json:"id"`
-	Name sql.NullString `json:"name"`
+	ID          int64          `json:"id"`
+	Name        sql.NullString `json:"name"`
+	Topic       sql.NullString `json:"topic"`
+	Description sql.NullString `json:"description"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	Kind        string         `json:"kind"`
+	DmUserLow   sql.NullInt64  `json:"dmUserLow"`
+	DmUserHigh  sql.NullInt64  `json:"dmUserHigh"`
 }
 
 type Message struct {
+	ID        int64         `json:"id"`
+	From      string        `json:"from"`
+	Content   string        `json:"content"`
+	CreatedAt time.Time     `json:"createdAt"`
+	ConvID    int64         `json:"convID"`
+	EditedAt  sql.NullTime  `json:"editedAt"`
+	DeletedAt sql.NullTime  `json:"deletedAt"`
+	SenderID  sql.NullInt64 `json:"senderID"`
+	IsSystem  bool          `json:"isSystem"`
+}
+
+type MessageEdit struct {
 	ID        int64     `json:"id"`
-	From      string    `json:"from"`
+	MessageID int64     `json:"messageID"`
 	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
-	ConvID    int64     `json:"convID"`
+	EditedAt  time.Time `json:"editedAt"`
+}
+
+type PasswordReset struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"userID"`
+	TokenHash string       `json:"tokenHash"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	UsedAt    sql.NullTime `json:"usedAt"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type RecoveryCode struct {
+	ID       int64        `json:"id"`
+	UserID   int64        `json:"userID"`
+	CodeHash string       `json:"codeHash"`
+	UsedAt   sql.NullTime `json:"usedAt"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    int64     `json:"userID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	RevokedAt time.Time `json:"revokedAt"`
 }
 
 type Session struct {
-	ID           uuid.UUID `json:"id"`
-	Email        string    `json:"email"`
-	UserID       int64     `json:"userID"`
-	RefreshToken string    `json:"refreshToken"`
-	UserAgent    string    `json:"userAgent"`
-	ClientIp     string    `json:"clientIp"`
-	IsBlocked    bool      `json:"isBlocked"`
-	ExpiresAt    time.Time `json:"expiresAt"`
-	CreatedAt    time.Time `json:"createdAt"`
+	ID           uuid.UUID    `json:"id"`
+	Email        string       `json:"email"`
+	UserID       int64        `json:"userID"`
+	RefreshToken string       `json:"refreshToken"`
+	UserAgent    string       `json:"userAgent"`
+	ClientIp     string       `json:"clientIp"`
+	IsBlocked    bool         `json:"isBlocked"`
+	ExpiresAt    time.Time    `json:"expiresAt"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	FamilyID     uuid.UUID    `json:"familyID"`
+	RotatedAt    sql.NullTime `json:"rotatedAt"`
 }
 
 type User struct {
-	ID        int64          `json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	HashedPw  string         `json:"hashedPw"`
-	Image     sql.NullString `json:"image"`
-	Status    sql.NullString `json:"status"`
-	CreatedAt time.Time      `json:"createdAt"`
+	ID              int64          `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	HashedPw        string         `json:"hashedPw"`
+	Image           sql.NullString `json:"image"`
+	Status          sql.NullString `json:"status"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	HideEmail       bool           `json:"hideEmail"`
+	Role            string         `json:"role"`
+	EmailVerifiedAt sql.NullTime   `json:"emailVerifiedAt"`
 }
 
 type UserConversation struct {
-	ID     int64 `json:"id"`
-	UserID int64 `json:"userID"`
-	ConvID int64 `json:"convID"`
+	ID                int64         `json:"id"`
+	UserID            int64         `json:"userID"`
+	ConvID            int64         `json:"convID"`
+	Role              string        `json:"role"`
+	LastReadMessageID sql.NullInt64 `json:"lastReadMessageID"`
+}
+
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userID"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UserTokenCutoff struct {
+	UserID        int64     `json:"userID"`
+	RevokedBefore time.Time `json:"revokedBefore"`
+}
+
+type UserTotp struct {
+	UserID       int64        `json:"userID"`
+	Secret       string       `json:"secret"`
+	ConfirmedAt  sql.NullTime `json:"confirmedAt"`
+	LastUsedStep int64        `json:"lastUsedStep"`
+	CreatedAt    time.Time    `json:"createdAt"`
 }
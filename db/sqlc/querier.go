@@ -12,33 +12,80 @@ import (
 )
 
 type Querier interface {
+	BlockSession(ctx context.Context, arg BlockSessionParams) (Session, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	BlockUserSessions(ctx context.Context, userID int64) error
+	ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (UserTotp, error)
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+	CreateConvMember(ctx context.Context, arg CreateConvMemberParams) (UserConversation, error)
 	CreateConversation(ctx context.Context, name sql.NullString) (Conversation, error)
+	CreateDirectConversation(ctx context.Context, arg CreateDirectConversationParams) (Conversation, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
+	CreateMessageEdit(ctx context.Context, arg CreateMessageEditParams) (MessageEdit, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateSystemMessage(ctx context.Context, arg CreateSystemMessageParams) (Message, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
 	CreateUser_conversation(ctx context.Context, arg CreateUser_conversationParams) (UserConversation, error)
+	DeleteConvMembers(ctx context.Context, convID int64) error
+	DeleteConvMessages(ctx context.Context, convID int64) error
 	DeleteConversation(ctx context.Context, id int64) error
+	DeleteExpiredPasswordResets(ctx context.Context) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
 	DeleteMessage(ctx context.Context, id int64) error
+	DeleteMessageEdits(ctx context.Context, messageID int64) error
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
 	DeleteUser(ctx context.Context, id int64) error
 	DeleteUser_conversation(ctx context.Context, arg DeleteUser_conversationParams) error
 	DeleteUser_conversation_by_id(ctx context.Context, id int64) error
+	ExpirePasswordResets(ctx context.Context, userID int64) error
+	GetConvSuccessor(ctx context.Context, arg GetConvSuccessorParams) (UserConversation, error)
 	GetConversation(ctx context.Context, id int64) (Conversation, error)
+	GetDirectConversation(ctx context.Context, arg GetDirectConversationParams) (Conversation, error)
 	GetMessage(ctx context.Context, id int64) (Message, error)
 	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetUser(ctx context.Context, id int64) (GetUserRow, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error)
+	GetUserPassword(ctx context.Context, id int64) (string, error)
+	GetUserTOTP(ctx context.Context, userID int64) (UserTotp, error)
 	GetUser_conv_by_id(ctx context.Context, id int64) (UserConversation, error)
 	GetUser_conversation(ctx context.Context, arg GetUser_conversationParams) (UserConversation, error)
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
+	ListActiveSessions(ctx context.Context, userID int64) ([]Session, error)
 	ListConvFromUser(ctx context.Context, id int64) ([]Conversation, error)
+	ListConvMembers(ctx context.Context, convID int64) ([]int64, error)
 	ListConvMessages(ctx context.Context, arg ListConvMessagesParams) ([]ListConvMessagesRow, error)
+	ListConvMessagesAfter(ctx context.Context, arg ListConvMessagesAfterParams) ([]ListConvMessagesAfterRow, error)
+	ListConvMessagesBefore(ctx context.Context, arg ListConvMessagesBeforeParams) ([]ListConvMessagesBeforeRow, error)
+	ListConvMessagesSince(ctx context.Context, arg ListConvMessagesSinceParams) ([]ListConvMessagesSinceRow, error)
+	ListConvSummaries(ctx context.Context, userID int64) ([]ListConvSummariesRow, error)
 	ListConversations(ctx context.Context, arg ListConversationsParams) ([]Conversation, error)
-	ListMessageByUser(ctx context.Context, from string) ([]Message, error)
+	ListMessageByUser(ctx context.Context, senderID sql.NullInt64) ([]Message, error)
+	ListMessageEdits(ctx context.Context, messageID int64) ([]MessageEdit, error)
 	ListUserMessages(ctx context.Context, id int64) ([]ListUserMessagesRow, error)
 	ListUser_conversationByUser(ctx context.Context, userID int64) ([]UserConversation, error)
 	ListUser_conversations(ctx context.Context) ([]UserConversation, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error)
+	MarkConvRead(ctx context.Context, arg MarkConvReadParams) (UserConversation, error)
+	MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error)
+	MarkSessionRotated(ctx context.Context, id uuid.UUID) (Session, error)
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error)
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error)
+	StartUserTOTP(ctx context.Context, arg StartUserTOTPParams) (UserTotp, error)
+	TombstoneMessage(ctx context.Context, id int64) (Message, error)
+	UpdateConvMemberRole(ctx context.Context, arg UpdateConvMemberRoleParams) (UserConversation, error)
 	UpdateConversation(ctx context.Context, arg UpdateConversationParams) (Conversation, error)
+	UpdateMessageContent(ctx context.Context, arg UpdateMessageContentParams) (Message, error)
 	UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams) (UpdateUserInfoRow, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (UpdateUserRoleRow, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (UserTotp, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	user := createRandomUser(t)
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		IssuedAt: time.Now(),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	for i := 0; i < 2; i++ {
+		// revoking twice is harmless
+		err = testQueries.RevokeToken(context.Background(), RevokeTokenParams{
+			ID:        arg.ID,
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+	}
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	user := createRandomUser(t)
+	cutoff := time.Now()
+
+	err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{UserID: user.ID, RevokedBefore: cutoff})
+	require.NoError(t, err)
+	// an earlier cutoff does not move it back
+	err = testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{UserID: user.ID, RevokedBefore: cutoff.Add(-time.Hour)})
+	require.NoError(t, err)
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		IssuedAt: cutoff.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		IssuedAt: cutoff.Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	user := createRandomUser(t)
+	id := uuid.New()
+	err := testQueries.RevokeToken(context.Background(), RevokeTokenParams{
+		ID:        id,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testQueries.DeleteExpiredRevokedTokens(context.Background()))
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       id,
+		UserID:   user.ID,
+		IssuedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
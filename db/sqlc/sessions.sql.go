@@ -12,6 +12,61 @@ import (
 	"github.com/google/uuid"
 )
 
+const blockSession = `-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1
+  and user_id = $2
+RETURNING id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, rotated_at
+`
+
+type BlockSessionParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID int64     `json:"userID"`
+}
+
+func (q *Queries) BlockSession(ctx context.Context, arg BlockSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, blockSession, arg.ID, arg.UserID)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.UserID,
+		&i.RefreshToken,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const blockSessionFamily = `-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1
+`
+
+func (q *Queries) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, blockSessionFamily, familyID)
+	return err
+}
+
+const blockUserSessions = `-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE user_id = $1
+  and is_blocked = false
+`
+
+func (q *Queries) BlockUserSessions(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, blockUserSessions, userID)
+	return err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO sessions (
     id,
@@ -22,10 +77,11 @@ INSERT INTO sessions (
     client_ip,
     is_blocked,
     expires_at,
-    created_at
+    created_at,
+    family_id
   )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-RETURNING id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, rotated_at
 `
 
 type CreateSessionParams struct {
@@ -38,6 +94,7 @@ type CreateSessionParams struct {
 	IsBlocked    bool      `json:"isBlocked"`
 	ExpiresAt    time.Time `json:"expiresAt"`
 	CreatedAt    time.Time `json:"createdAt"`
+	FamilyID     uuid.UUID `json:"familyID"`
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
@@ -51,6 +108,7 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		arg.IsBlocked,
 		arg.ExpiresAt,
 		arg.CreatedAt,
+		arg.FamilyID,
 	)
 	var i Session
 	err := row.Scan(
@@ -63,12 +121,14 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.IsBlocked,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
 const getSession = `-- name: GetSession :one
-SELECT id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at FROM sessions
+SELECT id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, rotated_at FROM sessions
 WHERE id = $1 LIMIT 1
 `
 
@@ -85,6 +145,79 @@ func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 		&i.IsBlocked,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const listActiveSessions = `-- name: ListActiveSessions :many
+SELECT id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, rotated_at FROM sessions
+WHERE user_id = $1
+  and is_blocked = false
+  and rotated_at IS NULL
+  and expires_at > now()
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListActiveSessions(ctx context.Context, userID int64) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Session{}
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.UserID,
+			&i.RefreshToken,
+			&i.UserAgent,
+			&i.ClientIp,
+			&i.IsBlocked,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.FamilyID,
+			&i.RotatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markSessionRotated = `-- name: MarkSessionRotated :one
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1
+  and rotated_at IS NULL
+RETURNING id, email, user_id, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, rotated_at
+`
+
+func (q *Queries) MarkSessionRotated(ctx context.Context, id uuid.UUID) (Session, error) {
+	row := q.db.QueryRowContext(ctx, markSessionRotated, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.UserID,
+		&i.RefreshToken,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandSession(t *testing.T, user User, expires time.Time) Session {
+	arg := CreateSessionParams{
+		ID:           uuid.New(),
+		Email:        user.Email,
+		UserID:       user.ID,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    util.RandomString(10),
+		ClientIp:     "127.0.0.1",
+		ExpiresAt:    expires,
+		CreatedAt:    time.Now(),
+		FamilyID:     uuid.New(),
+	}
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, session.ID)
+	require.False(t, session.IsBlocked)
+
+	return session
+}
+
+func TestListActiveSessions(t *testing.T) {
+	user := createRandomUser(t)
+
+	active := createRandSession(t, user, time.Now().Add(time.Hour))
+	createRandSession(t, user, time.Now().Add(-time.Hour))
+	blocked := createRandSession(t, user, time.Now().Add(time.Hour))
+	_, err := testQueries.BlockSession(context.Background(), BlockSessionParams{ID: blocked.ID, UserID: user.ID})
+	require.NoError(t, err)
+
+	list, err := testQueries.ListActiveSessions(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, active.ID, list[0].ID)
+}
+
+func TestBlockSession(t *testing.T) {
+	user := createRandomUser(t)
+	session := createRandSession(t, user, time.Now().Add(time.Hour))
+
+	// only the owner can block a session
+	other := createRandomUser(t)
+	_, err := testQueries.BlockSession(context.Background(), BlockSessionParams{ID: session.ID, UserID: other.ID})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	blocked, err := testQueries.BlockSession(context.Background(), BlockSessionParams{ID: session.ID, UserID: user.ID})
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+
+	got, err := testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, got.IsBlocked)
+}
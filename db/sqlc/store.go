@@ -3,14 +3,45 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// ErrNotMember is returned when a user acts on a conversation they do not
+// belong to.
+var ErrNotMember = errors.New("not a member of this conversation")
+
+// ErrSessionReused means a refresh token was presented after it had already
+// been rotated, which only happens if it was copied.
+var ErrSessionReused = errors.New("refresh token already used")
+
+// ErrResetTokenInvalid is returned for password reset tokens that are
+// unknown, already used or expired.
+var ErrResetTokenInvalid = errors.New("reset token is invalid or expired")
+
+// ErrTOTPNotPending is returned when confirming two-factor authentication
+// that was never set up or is already on.
+var ErrTOTPNotPending = errors.New("no two-factor setup to confirm")
+
 type Store interface {
 	Querier
 	SendMessage(ctx context.Context, arg SendMessageParams) (SendResult, error)
 	CreateConvTx(ctx context.Context, arg CreateConvParams) (ConvReturn, error)
+	DirectConvTx(ctx context.Context, arg DirectConvParams) (ConvReturn, error)
+	EditMessageTx(ctx context.Context, arg EditMessageParams) (Message, error)
+	DeleteMessageTx(ctx context.Context, id int64) (Message, error)
+	LeaveConvTx(ctx context.Context, arg LeaveConvParams) (LeaveConvResult, error)
+	UpdateConvTx(ctx context.Context, arg UpdateConvParams) (UpdateConvResult, error)
+	DeleteConvTx(ctx context.Context, id int64) error
+	RotateSessionTx(ctx context.Context, arg RotateSessionParams) (Session, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordParams) (int64, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordParams) error
+	UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleParams) (UpdateUserRoleRow, error)
+	ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPParams) (UserTotp, error)
+	CreateOIDCUserTx(ctx context.Context, arg CreateOIDCUserParams) (User, error)
 }
 type SQLStore struct {
 	*Queries
@@ -48,42 +79,39 @@ type SendMessageParams struct {
 }
 
 type SendResult struct {
-	Timestamp time.Time `json:"sent_at"`
-	MsgID     int64     `json:"id"`
+	Timestamp   time.Time `json:"sent_at"`
+	MsgID       int64     `json:"id"`
+	ConvID      int64     `json:"convID"`
+	SenderID    int64     `json:"senderID"`
+	From        string    `json:"from"`
+	SenderImage string    `json:"senderImage"`
+	Content     string    `json:"content"`
+	System      bool      `json:"system"`
 }
 
 func (store *SQLStore) SendMessage(ctx context.Context, arg SendMessageParams) (SendResult, error) {
 
 	var result SendResult
 
-	// validate conversation exists, create message
-	// check join table
-
 	err := store.execTx(ctx, func(q *Queries) error {
-		var err error
 		if _, err := q.GetUser_conversation(ctx, GetUser_conversationParams{
 			UserID: arg.UserID,
 			ConvID: arg.ConvID,
 		}); err != nil {
 			if err == sql.ErrNoRows {
-				q.CreateUser_conversation(ctx, CreateUser_conversationParams{
-					UserID: arg.UserID,
-					ConvID: arg.ConvID,
-				})
+				return ErrNotMember
 			}
+			return err
 		}
 		user, err := q.GetUser(ctx, arg.UserID)
 		if err != nil {
 			return err
 		}
-		/*
-			todo add query to db to get user and add
-			todo the name to the message params here vs from the api side
-		*/
 		msg, err := q.CreateMessage(ctx, CreateMessageParams{
-			From:    user.Name,
-			Content: arg.Content,
-			ConvID:  arg.ConvID,
+			From:     user.Name,
+			Content:  arg.Content,
+			ConvID:   arg.ConvID,
+			SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
 		})
 		if err != nil {
 			return err
@@ -91,6 +119,11 @@ func (store *SQLStore) SendMessage(ctx context.Context, arg SendMessageParams) (
 
 		result.Timestamp = msg.CreatedAt
 		result.MsgID = msg.ID
+		result.ConvID = msg.ConvID
+		result.SenderID = user.ID
+		result.From = user.Name
+		result.SenderImage = NullString(user.Image).MarshalJson()
+		result.Content = msg.Content
 
 		return nil
 	})
@@ -112,11 +145,73 @@ type CreateConvParams struct {
 	From    int64          `json:"from"`
 }
 
+// Conversation kinds. A direct conversation is between exactly two users and
+// there is at most one per pair.
+const (
+	KindGroup  = "group"
+	KindDirect = "direct"
+)
+
+// ConvReturn.Created is false when CreateConvTx or DirectConvTx found an
+// existing direct conversation instead of starting a new one.
 type ConvReturn struct {
-	Name string `json:"conv_name"`
-	ID   int64  `json:"conv_id"`
+	Name    string `json:"conv_name"`
+	ID      int64  `json:"conv_id"`
+	Kind    string `json:"kind"`
+	Created bool   `json:"created"`
+}
+
+// directConv returns the direct conversation between from and to, creating
+// it with both users as members if it does not exist yet.
+func directConv(ctx context.Context, q *Queries, name sql.NullString, from, to int64) (Conversation, bool, error) {
+	low, high := from, to
+	if low > high {
+		low, high = high, low
+	}
+	pair := CreateDirectConversationParams{
+		Name:       name,
+		DmUserLow:  sql.NullInt64{Int64: low, Valid: true},
+		DmUserHigh: sql.NullInt64{Int64: high, Valid: true},
+	}
+
+	conv, err := q.CreateDirectConversation(ctx, pair)
+	if err == sql.ErrNoRows {
+		conv, err = q.GetDirectConversation(ctx, GetDirectConversationParams{
+			DmUserLow:  pair.DmUserLow,
+			DmUserHigh: pair.DmUserHigh,
+		})
+		if err != nil {
+			return conv, false, err
+		}
+		// either side may have left since, reopening the conversation brings
+		// them both back
+		for _, userID := range []int64{from, to} {
+			_, err = q.GetUser_conversation(ctx, GetUser_conversationParams{UserID: userID, ConvID: conv.ID})
+			if err == sql.ErrNoRows {
+				_, err = q.CreateConvMember(ctx, CreateConvMemberParams{UserID: userID, ConvID: conv.ID, Role: RoleMember})
+			}
+			if err != nil {
+				return conv, false, err
+			}
+		}
+		return conv, false, nil
+	}
+	if err != nil {
+		return conv, false, err
+	}
+
+	if _, err := q.CreateConvMember(ctx, CreateConvMemberParams{UserID: from, ConvID: conv.ID, Role: RoleOwner}); err != nil {
+		return conv, false, err
+	}
+	if _, err := q.CreateConvMember(ctx, CreateConvMemberParams{UserID: to, ConvID: conv.ID, Role: RoleMember}); err != nil {
+		return conv, false, err
+	}
+	return conv, true, nil
 }
 
+// CreateConvTx starts a direct conversation when there is a single recipient
+// other than the sender, returning the existing one if the pair already has
+// one. Anything else creates a new group conversation.
 func (store *SQLStore) CreateConvTx(ctx context.Context, convParams CreateConvParams) (ConvReturn, error) {
 	var ret ConvReturn
 
@@ -136,26 +231,407 @@ func (store *SQLStore) CreateConvTx(ctx context.Context, convParams CreateConvPa
 			return err
 		}
 
+		if len(validUsers) == 1 && validUsers[0].ID != convParams.From {
+			conv, created, err := directConv(ctx, q, convParams.Name, convParams.From, validUsers[0].ID)
+			if err != nil {
+				return err
+			}
+			ret = newConvReturn(conv, created)
+			return nil
+		}
+
 		conv, err := q.CreateConversation(ctx, convParams.Name)
 		if err != nil {
 			return err
 		}
-		q.CreateUser_conversation(ctx, CreateUser_conversationParams{UserID: convParams.From, ConvID: conv.ID})
+		// a conversation without its owner must not be left behind
+		if _, err := q.CreateConvMember(ctx, CreateConvMemberParams{UserID: convParams.From, ConvID: conv.ID, Role: RoleOwner}); err != nil {
+			return err
+		}
 
 		for _, user := range validUsers {
-			if _, err := q.GetUser_conversation(ctx, GetUser_conversationParams{UserID: user.ID, ConvID: conv.ID}); err != nil {
-				if err == sql.ErrNoRows {
-					q.CreateUser_conversation(ctx, CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
-				}
+			_, err := q.GetUser_conversation(ctx, GetUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
+			if err == nil {
 				continue
 			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+			if _, err := q.CreateUser_conversation(ctx, CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID}); err != nil {
+				return err
+			}
 		}
+		ret = newConvReturn(conv, true)
+		return nil
+	})
+	return ret, err
+}
+
+func newConvReturn(conv Conversation, created bool) ConvReturn {
+	return ConvReturn{
+		Name:    NullString(conv.Name).MarshalJson(),
+		ID:      conv.ID,
+		Kind:    conv.Kind,
+		Created: created,
+	}
+}
+
+type DirectConvParams struct {
+	UserID  int64 `json:"user_id"`
+	OtherID int64 `json:"other_id"`
+}
+
+// DirectConvTx opens the direct conversation between two users, creating it
+// on first use.
+func (store *SQLStore) DirectConvTx(ctx context.Context, arg DirectConvParams) (ConvReturn, error) {
+	var ret ConvReturn
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		conv, created, err := directConv(ctx, q, sql.NullString{}, arg.UserID, arg.OtherID)
 		if err != nil {
 			return err
 		}
-		ret.Name = NullString(conv.Name).MarshalJson()
-		ret.ID = conv.ID
+		ret = newConvReturn(conv, created)
 		return nil
 	})
 	return ret, err
 }
+
+type EditMessageParams struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+// EditMessageTx keeps the previous content in message_edits before
+// overwriting it. Deleted messages cannot be edited and return sql.ErrNoRows.
+func (store *SQLStore) EditMessageTx(ctx context.Context, arg EditMessageParams) (Message, error) {
+	var ret Message
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		msg, err := q.GetMessage(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		if msg.DeletedAt.Valid {
+			return sql.ErrNoRows
+		}
+		if _, err := q.CreateMessageEdit(ctx, CreateMessageEditParams{
+			MessageID: msg.ID,
+			Content:   msg.Content,
+		}); err != nil {
+			return err
+		}
+		ret, err = q.UpdateMessageContent(ctx, UpdateMessageContentParams{
+			ID:      arg.ID,
+			Content: arg.Content,
+		})
+		return err
+	})
+	return ret, err
+}
+
+// DeleteMessageTx replaces the message with a tombstone so it keeps its place
+// in the conversation, and drops its edit history along with the content.
+func (store *SQLStore) DeleteMessageTx(ctx context.Context, id int64) (Message, error) {
+	var ret Message
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		ret, err = q.TombstoneMessage(ctx, id)
+		if err != nil {
+			return err
+		}
+		return q.DeleteMessageEdits(ctx, id)
+	})
+	return ret, err
+}
+
+// Roles stored on user_conversation, in increasing order of privilege.
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+type LeaveConvParams struct {
+	UserID int64 `json:"userID"`
+	ConvID int64 `json:"convID"`
+}
+
+type LeaveConvResult struct {
+	Left     UserConversation `json:"left"`
+	NewOwner UserConversation `json:"newOwner"`
+}
+
+// LeaveConvTx removes the member and, when they owned the conversation, hands
+// ownership to the longest-standing admin, or failing that the
+// longest-standing member. NewOwner is left empty when nobody inherits.
+func (store *SQLStore) LeaveConvTx(ctx context.Context, arg LeaveConvParams) (LeaveConvResult, error) {
+	var ret LeaveConvResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		ret.Left, err = q.GetUser_conversation(ctx, GetUser_conversationParams{
+			UserID: arg.UserID,
+			ConvID: arg.ConvID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteUser_conversation(ctx, DeleteUser_conversationParams{
+			UserID: arg.UserID,
+			ConvID: arg.ConvID,
+		}); err != nil {
+			return err
+		}
+		if ret.Left.Role != RoleOwner {
+			return nil
+		}
+
+		next, err := q.GetConvSuccessor(ctx, GetConvSuccessorParams{
+			ConvID: arg.ConvID,
+			UserID: arg.UserID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+		ret.NewOwner, err = q.UpdateConvMemberRole(ctx, UpdateConvMemberRoleParams{
+			UserID: next.UserID,
+			ConvID: arg.ConvID,
+			Role:   RoleOwner,
+		})
+		return err
+	})
+	return ret, err
+}
+
+type UpdateConvParams struct {
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"userID"`
+	Name        sql.NullString `json:"name"`
+	Topic       sql.NullString `json:"topic"`
+	Description sql.NullString `json:"description"`
+}
+
+type UpdateConvResult struct {
+	Conversation  Conversation `json:"conversation"`
+	SystemMessage Message      `json:"systemMessage"`
+}
+
+// UpdateConvTx only changes the fields that are set. Renaming also posts a
+// system message into the conversation, otherwise SystemMessage is empty.
+func (store *SQLStore) UpdateConvTx(ctx context.Context, arg UpdateConvParams) (UpdateConvResult, error) {
+	var ret UpdateConvResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		conv, err := q.GetConversation(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		ret.Conversation, err = q.UpdateConversation(ctx, UpdateConversationParams{
+			ID:          arg.ID,
+			Name:        arg.Name,
+			Topic:       arg.Topic,
+			Description: arg.Description,
+		})
+		if err != nil {
+			return err
+		}
+		if !arg.Name.Valid || arg.Name == conv.Name {
+			return nil
+		}
+
+		user, err := q.GetUser(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+		ret.SystemMessage, err = q.CreateSystemMessage(ctx, CreateSystemMessageParams{
+			From:     user.Name,
+			Content:  fmt.Sprintf("%s renamed the conversation to %s", user.Name, arg.Name.String),
+			ConvID:   arg.ID,
+			SenderID: sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+		return err
+	})
+	return ret, err
+}
+
+// DeleteConvTx removes the conversation along with all of its messages and
+// memberships.
+func (store *SQLStore) DeleteConvTx(ctx context.Context, id int64) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		if err := q.DeleteConvMessages(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeleteConvMembers(ctx, id); err != nil {
+			return err
+		}
+		return q.DeleteConversation(ctx, id)
+	})
+}
+
+type RotateSessionParams struct {
+	OldID uuid.UUID           `json:"old_id"`
+	Next  CreateSessionParams `json:"next"`
+}
+
+// RotateSessionTx retires the old session and stores its replacement. If the
+// old session was already rotated it returns ErrSessionReused and creates
+// nothing, the caller decides what to block.
+func (store *SQLStore) RotateSessionTx(ctx context.Context, arg RotateSessionParams) (Session, error) {
+	var ret Session
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		old, err := q.MarkSessionRotated(ctx, arg.OldID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrSessionReused
+			}
+			return err
+		}
+
+		next := arg.Next
+		next.FamilyID = old.FamilyID
+		ret, err = q.CreateSession(ctx, next)
+		return err
+	})
+	return ret, err
+}
+
+type ResetPasswordParams struct {
+	TokenHash string `json:"token_hash"`
+	HashedPw  string `json:"hashed_pw"`
+}
+
+// ResetPasswordTx spends a reset token and sets the new password, returning
+// the id of the user it belonged to. Unknown, used or expired tokens return
+// ErrResetTokenInvalid and change nothing.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordParams) (int64, error) {
+	var userID int64
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		reset, err := q.ConsumePasswordReset(ctx, arg.TokenHash)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrResetTokenInvalid
+			}
+			return err
+		}
+		userID = reset.UserID
+		return setPassword(ctx, q, userID, arg.HashedPw)
+	})
+	return userID, err
+}
+
+type ChangePasswordParams struct {
+	UserID   int64  `json:"user_id"`
+	HashedPw string `json:"hashed_pw"`
+}
+
+// ChangePasswordTx sets a password the caller has already authorised the
+// change of, ending the user's sessions like a reset does.
+func (store *SQLStore) ChangePasswordTx(ctx context.Context, arg ChangePasswordParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		return setPassword(ctx, q, arg.UserID, arg.HashedPw)
+	})
+}
+
+// UpdateUserRoleTx changes a user's role and blocks their refresh sessions,
+// which would otherwise keep renewing tokens with the old role.
+func (store *SQLStore) UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleParams) (UpdateUserRoleRow, error) {
+	var user UpdateUserRoleRow
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.UpdateUserRole(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return q.BlockUserSessions(ctx, user.ID)
+	})
+	return user, err
+}
+
+// setPassword stores a new password and ends everything that was granted
+// under the old one: refresh sessions and outstanding reset tokens.
+func setPassword(ctx context.Context, q *Queries, userID int64, hashedPw string) error {
+	if err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{ID: userID, HashedPw: hashedPw}); err != nil {
+		return err
+	}
+	if err := q.ExpirePasswordResets(ctx, userID); err != nil {
+		return err
+	}
+	return q.BlockUserSessions(ctx, userID)
+}
+
+type ConfirmTOTPParams struct {
+	UserID int64 `json:"user_id"`
+	// Step is the time step of the code that proved the enrollment, it
+	// cannot be used again to log in.
+	Step       int64    `json:"step"`
+	CodeHashes []string `json:"code_hashes"`
+}
+
+// ConfirmTOTPTx turns on a pending enrollment and replaces the user's
+// recovery codes with CodeHashes.
+func (store *SQLStore) ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPParams) (UserTotp, error) {
+	var ret UserTotp
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		ret, err = q.ConfirmUserTOTP(ctx, ConfirmUserTOTPParams{UserID: arg.UserID, LastUsedStep: arg.Step})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrTOTPNotPending
+			}
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, arg.UserID); err != nil {
+			return err
+		}
+		for _, hash := range arg.CodeHashes {
+			err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{UserID: arg.UserID, CodeHash: hash})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+type CreateOIDCUserParams struct {
+	CreateUserParams
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+// CreateOIDCUserTx registers someone who signed in through an identity
+// provider. The provider has already confirmed the address, so the account
+// starts out verified and linked to the external subject.
+func (store *SQLStore) CreateOIDCUserTx(ctx context.Context, arg CreateOIDCUserParams) (User, error) {
+	var ret User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		user, err := q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+		ret, err = q.MarkEmailVerified(ctx, MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})
+		if err != nil {
+			return err
+		}
+		_, err = q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: arg.Provider,
+			Subject:  arg.Subject,
+			Email:    user.Email,
+		})
+		return err
+	})
+	return ret, err
+}
@@ -2,8 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/rjriverac/messaging-server/util"
 	"github.com/stretchr/testify/require"
 )
@@ -17,6 +20,11 @@ func TestSendMessage(t *testing.T) {
 		Content: util.RandomString(50),
 		ConvID:  createRandConv(t).ID,
 	}
+	_, err := store.CreateUser_conversation(context.Background(), CreateUser_conversationParams{
+		UserID: sender.ID,
+		ConvID: message.ConvID,
+	})
+	require.NoError(t, err)
 
 	n := 5
 
@@ -46,15 +54,42 @@ func TestSendMessage(t *testing.T) {
 		result := <-res
 		require.NotEmpty(t, result)
 		require.NotZero(t, result.Timestamp)
+		require.Equal(t, message.ConvID, result.ConvID)
+		require.Equal(t, sender.ID, result.SenderID)
+		require.Equal(t, sender.Name, result.From)
+		require.Equal(t, message.Content, result.Content)
 
-		_, err = store.GetMessage(context.Background(), result.MsgID)
+		msg, err := store.GetMessage(context.Background(), result.MsgID)
 		require.NoError(t, err)
+		require.Equal(t, sender.ID, msg.SenderID.Int64)
 
 		_, err = store.GetUser_conversation(context.Background(), GetUser_conversationParams{UserID: sender.ID, ConvID: message.ConvID})
 		require.NoError(t, err)
 	}
 }
 
+func TestSendMessageNotMember(t *testing.T) {
+	store := NewStore(testDB)
+
+	sender := createRandomUser(t)
+	conv := createRandConv(t)
+
+	_, err := store.SendMessage(context.Background(), SendMessageParams{
+		UserID:  sender.ID,
+		Content: util.RandomString(50),
+		ConvID:  conv.ID,
+	})
+	require.ErrorIs(t, err, ErrNotMember)
+
+	// the failed send must not have joined the sender to the conversation
+	_, err = store.GetUser_conversation(context.Background(), GetUser_conversationParams{UserID: sender.ID, ConvID: conv.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	messages, err := store.ListMessageByUser(context.Background(), sql.NullInt64{Int64: sender.ID, Valid: true})
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
+
 // func TestCreateConvTx(t *testing.T) {
 // 	testCases := []struct {
 // 		desc string
@@ -92,4 +127,364 @@ func TestConvTx(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEmpty(t, res)
 
+	owner, err := store.GetUser_conversation(context.Background(), GetUser_conversationParams{UserID: sendingUser.ID, ConvID: res.ID})
+	require.NoError(t, err)
+	require.Equal(t, RoleOwner, owner.Role)
+}
+
+func TestDirectConvTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	user1 := createRandomUser(t)
+	user2 := createRandomUser(t)
+
+	res1, err := store.CreateConvTx(context.Background(), CreateConvParams{ToUsers: []string{user2.Email}, From: user1.ID})
+	require.NoError(t, err)
+	require.True(t, res1.Created)
+	require.Equal(t, KindDirect, res1.Kind)
+
+	// either side, either way in, lands in the same conversation
+	res2, err := store.CreateConvTx(context.Background(), CreateConvParams{ToUsers: []string{user1.Email}, From: user2.ID})
+	require.NoError(t, err)
+	require.False(t, res2.Created)
+	require.Equal(t, res1.ID, res2.ID)
+
+	res3, err := store.DirectConvTx(context.Background(), DirectConvParams{UserID: user1.ID, OtherID: user2.ID})
+	require.NoError(t, err)
+	require.False(t, res3.Created)
+	require.Equal(t, res1.ID, res3.ID)
+
+	members, err := store.ListConvMembers(context.Background(), res1.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{user1.ID, user2.ID}, members)
+
+	// leaving and reopening rejoins
+	_, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: user2.ID, ConvID: res1.ID})
+	require.NoError(t, err)
+	res4, err := store.DirectConvTx(context.Background(), DirectConvParams{UserID: user2.ID, OtherID: user1.ID})
+	require.NoError(t, err)
+	require.Equal(t, res1.ID, res4.ID)
+	_, err = store.GetUser_conversation(context.Background(), GetUser_conversationParams{UserID: user2.ID, ConvID: res1.ID})
+	require.NoError(t, err)
+
+	// and the side that stayed reopening it brings the other one back too
+	_, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: user2.ID, ConvID: res1.ID})
+	require.NoError(t, err)
+	res5, err := store.DirectConvTx(context.Background(), DirectConvParams{UserID: user1.ID, OtherID: user2.ID})
+	require.NoError(t, err)
+	require.Equal(t, res1.ID, res5.ID)
+	members, err = store.ListConvMembers(context.Background(), res1.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{user1.ID, user2.ID}, members)
+
+	// a second recipient makes it a group
+	user3 := createRandomUser(t)
+	group, err := store.CreateConvTx(context.Background(), CreateConvParams{ToUsers: []string{user2.Email, user3.Email}, From: user1.ID})
+	require.NoError(t, err)
+	require.Equal(t, KindGroup, group.Kind)
+	require.NotEqual(t, res1.ID, group.ID)
+}
+
+func TestEditMessageTx(t *testing.T) {
+	store := NewStore(testDB)
+	msg := createRandMessage(t)
+
+	contents := []string{util.RandomString(20), util.RandomString(20)}
+	for _, content := range contents {
+		edited, err := store.EditMessageTx(context.Background(), EditMessageParams{ID: msg.ID, Content: content})
+		require.NoError(t, err)
+		require.Equal(t, content, edited.Content)
+		require.True(t, edited.EditedAt.Valid)
+		require.False(t, edited.DeletedAt.Valid)
+	}
+
+	edits, err := testQueries.ListMessageEdits(context.Background(), msg.ID)
+	require.NoError(t, err)
+	require.Len(t, edits, 2)
+	require.Equal(t, msg.Content, edits[0].Content)
+	require.Equal(t, contents[0], edits[1].Content)
+}
+
+func TestDeleteMessageTx(t *testing.T) {
+	store := NewStore(testDB)
+	msg := createRandMessage(t)
+
+	_, err := store.EditMessageTx(context.Background(), EditMessageParams{ID: msg.ID, Content: util.RandomString(20)})
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteMessageTx(context.Background(), msg.ID)
+	require.NoError(t, err)
+	require.Equal(t, msg.ID, deleted.ID)
+	require.Equal(t, "message deleted", deleted.Content)
+	require.True(t, deleted.DeletedAt.Valid)
+
+	edits, err := testQueries.ListMessageEdits(context.Background(), msg.ID)
+	require.NoError(t, err)
+	require.Empty(t, edits)
+
+	_, err = store.DeleteMessageTx(context.Background(), msg.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.EditMessageTx(context.Background(), EditMessageParams{ID: msg.ID, Content: util.RandomString(20)})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestLeaveConvTx(t *testing.T) {
+	store := NewStore(testDB)
+	conv := createRandConv(t)
+
+	roles := []string{RoleOwner, RoleMember, RoleAdmin, RoleMember}
+	members := make([]UserConversation, len(roles))
+	for i, role := range roles {
+		var err error
+		members[i], err = store.CreateConvMember(context.Background(), CreateConvMemberParams{
+			UserID: createRandomUser(t).ID,
+			ConvID: conv.ID,
+			Role:   role,
+		})
+		require.NoError(t, err)
+	}
+
+	// a plain member leaving changes nobody's role
+	res, err := store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: members[3].UserID, ConvID: conv.ID})
+	require.NoError(t, err)
+	require.Equal(t, members[3].ID, res.Left.ID)
+	require.Empty(t, res.NewOwner)
+
+	// the admin is preferred over the longer-standing member
+	res, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: members[0].UserID, ConvID: conv.ID})
+	require.NoError(t, err)
+	require.Equal(t, members[2].UserID, res.NewOwner.UserID)
+	require.Equal(t, RoleOwner, res.NewOwner.Role)
+
+	res, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: members[2].UserID, ConvID: conv.ID})
+	require.NoError(t, err)
+	require.Equal(t, members[1].UserID, res.NewOwner.UserID)
+
+	res, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: members[1].UserID, ConvID: conv.ID})
+	require.NoError(t, err)
+	require.Empty(t, res.NewOwner)
+
+	_, err = store.LeaveConvTx(context.Background(), LeaveConvParams{UserID: members[1].UserID, ConvID: conv.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUpdateConvTx(t *testing.T) {
+	store := NewStore(testDB)
+	conv := createRandConv(t)
+	user := createRandomUser(t)
+
+	res, err := store.UpdateConvTx(context.Background(), UpdateConvParams{
+		ID:     conv.ID,
+		UserID: user.ID,
+		Topic:  util.NullStrGen(10),
+	})
+	require.NoError(t, err)
+	require.Equal(t, conv.Name, res.Conversation.Name)
+	require.Len(t, res.Conversation.Topic.String, 10)
+	require.Empty(t, res.SystemMessage)
+
+	name := util.NullStrGen(6)
+	res, err = store.UpdateConvTx(context.Background(), UpdateConvParams{
+		ID:     conv.ID,
+		UserID: user.ID,
+		Name:   name,
+	})
+	require.NoError(t, err)
+	require.Equal(t, name, res.Conversation.Name)
+	require.True(t, res.SystemMessage.IsSystem)
+	require.Equal(t, conv.ID, res.SystemMessage.ConvID)
+	require.Equal(t, user.ID, res.SystemMessage.SenderID.Int64)
+	require.Contains(t, res.SystemMessage.Content, name.String)
+
+	_, err = store.UpdateConvTx(context.Background(), UpdateConvParams{ID: conv.ID + 1000000, Name: name})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestDeleteConvTx(t *testing.T) {
+	store := NewStore(testDB)
+	msg := createRandMessage(t)
+	_, err := store.CreateUser_conversation(context.Background(), CreateUser_conversationParams{
+		UserID: msg.SenderID.Int64,
+		ConvID: msg.ConvID,
+	})
+	require.NoError(t, err)
+
+	err = store.DeleteConvTx(context.Background(), msg.ConvID)
+	require.NoError(t, err)
+
+	_, err = store.GetConversation(context.Background(), msg.ConvID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.GetMessage(context.Background(), msg.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	members, err := store.ListConvMembers(context.Background(), msg.ConvID)
+	require.NoError(t, err)
+	require.Empty(t, members)
+}
+
+func TestRotateSessionTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	first := createRandSession(t, user, time.Now().Add(time.Hour))
+
+	next := func() CreateSessionParams {
+		return CreateSessionParams{
+			ID:           uuid.New(),
+			Email:        user.Email,
+			UserID:       user.ID,
+			RefreshToken: util.RandomString(32),
+			UserAgent:    util.RandomString(10),
+			ClientIp:     "127.0.0.1",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			CreatedAt:    time.Now(),
+		}
+	}
+
+	second, err := store.RotateSessionTx(context.Background(), RotateSessionParams{OldID: first.ID, Next: next()})
+	require.NoError(t, err)
+	require.Equal(t, first.FamilyID, second.FamilyID)
+	require.False(t, second.RotatedAt.Valid)
+
+	old, err := store.GetSession(context.Background(), first.ID)
+	require.NoError(t, err)
+	require.True(t, old.RotatedAt.Valid)
+
+	// rotating the same session twice is reuse and creates nothing
+	attempt := next()
+	_, err = store.RotateSessionTx(context.Background(), RotateSessionParams{OldID: first.ID, Next: attempt})
+	require.ErrorIs(t, err, ErrSessionReused)
+	_, err = store.GetSession(context.Background(), attempt.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	require.NoError(t, store.BlockSessionFamily(context.Background(), first.FamilyID))
+	blocked, err := store.GetSession(context.Background(), second.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	session := createRandSession(t, user, time.Now().Add(time.Hour))
+
+	create := func(hash string, expires time.Time) {
+		_, err := store.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: expires,
+		})
+		require.NoError(t, err)
+	}
+	used, other, expired := util.RandomString(64), util.RandomString(64), util.RandomString(64)
+	create(used, time.Now().Add(time.Hour))
+	create(other, time.Now().Add(time.Hour))
+	create(expired, time.Now().Add(-time.Minute))
+
+	_, err := store.ResetPasswordTx(context.Background(), ResetPasswordParams{TokenHash: expired, HashedPw: "x"})
+	require.ErrorIs(t, err, ErrResetTokenInvalid)
+
+	hashed, err := util.HashPassword(util.RandomString(10))
+	require.NoError(t, err)
+	userID, err := store.ResetPasswordTx(context.Background(), ResetPasswordParams{TokenHash: used, HashedPw: hashed})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, userID)
+
+	got, err := store.GetUserByEmail(context.Background(), user.Email)
+	require.NoError(t, err)
+	require.Equal(t, hashed, got.HashedPw)
+
+	blocked, err := store.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+
+	// single use, and every other outstanding token dies with it
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordParams{TokenHash: used, HashedPw: hashed})
+	require.ErrorIs(t, err, ErrResetTokenInvalid)
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordParams{TokenHash: other, HashedPw: hashed})
+	require.ErrorIs(t, err, ErrResetTokenInvalid)
+}
+
+func TestUpdateUserRoleTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	session := createRandSession(t, user, time.Now().Add(time.Hour))
+
+	updated, err := store.UpdateUserRoleTx(context.Background(), UpdateUserRoleParams{ID: user.ID, Role: "admin"})
+	require.NoError(t, err)
+	require.Equal(t, "admin", updated.Role)
+
+	// the old session cannot renew into tokens with the old role
+	blocked, err := store.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+
+	_, err = store.UpdateUserRoleTx(context.Background(), UpdateUserRoleParams{ID: user.ID, Role: "owner"})
+	require.Error(t, err)
+	got, err := store.GetUser(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "admin", got.Role)
+}
+
+func TestChangePasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	session := createRandSession(t, user, time.Now().Add(time.Hour))
+
+	hash := util.RandomString(64)
+	_, err := store.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	hashed, err := util.HashPassword(util.RandomString(10))
+	require.NoError(t, err)
+	err = store.ChangePasswordTx(context.Background(), ChangePasswordParams{UserID: user.ID, HashedPw: hashed})
+	require.NoError(t, err)
+
+	got, err := store.GetUserPassword(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, hashed, got)
+
+	blocked, err := store.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+
+	// a reset link mailed before the change no longer works
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordParams{TokenHash: hash, HashedPw: hashed})
+	require.ErrorIs(t, err, ErrResetTokenInvalid)
+}
+
+func TestCreateOIDCUserTx(t *testing.T) {
+	store := NewStore(testDB)
+	hashed, err := util.HashPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	arg := CreateOIDCUserParams{
+		CreateUserParams: CreateUserParams{
+			Name:     util.RandomUserGen(),
+			Email:    util.RandomEmail(),
+			HashedPw: hashed,
+		},
+		Provider: "mock",
+		Subject:  util.RandomString(16),
+	}
+	user, err := store.CreateOIDCUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Email, user.Email)
+	// the provider vouched for the address
+	require.True(t, user.EmailVerifiedAt.Valid)
+
+	identity, err := store.GetUserIdentity(context.Background(), GetUserIdentityParams{Provider: arg.Provider, Subject: arg.Subject})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, identity.UserID)
+	require.Equal(t, arg.Email, identity.Email)
+
+	// nothing is left behind when the address is taken
+	taken := arg
+	taken.Subject = util.RandomString(16)
+	_, err = store.CreateOIDCUserTx(context.Background(), taken)
+	require.Error(t, err)
+	_, err = store.GetUserIdentity(context.Background(), GetUserIdentityParams{Provider: taken.Provider, Subject: taken.Subject})
+	require.ErrorIs(t, err, sql.ErrNoRows)
 }
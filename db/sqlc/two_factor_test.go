@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartUserTOTP(t *testing.T) {
+	user := createRandomUser(t)
+
+	first, err := testQueries.StartUserTOTP(context.Background(), StartUserTOTPParams{UserID: user.ID, Secret: util.RandomString(32)})
+	require.NoError(t, err)
+	require.False(t, first.ConfirmedAt.Valid)
+
+	// starting over replaces a pending secret
+	second, err := testQueries.StartUserTOTP(context.Background(), StartUserTOTPParams{UserID: user.ID, Secret: util.RandomString(32)})
+	require.NoError(t, err)
+	require.NotEqual(t, first.Secret, second.Secret)
+
+	confirmed, err := testQueries.ConfirmUserTOTP(context.Background(), ConfirmUserTOTPParams{UserID: user.ID, LastUsedStep: 100})
+	require.NoError(t, err)
+	require.True(t, confirmed.ConfirmedAt.Valid)
+
+	// but never a confirmed one
+	_, err = testQueries.StartUserTOTP(context.Background(), StartUserTOTPParams{UserID: user.ID, Secret: util.RandomString(32)})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	got, err := testQueries.GetUserTOTP(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, second.Secret, got.Secret)
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	user := createRandomUser(t)
+	_, err := testQueries.StartUserTOTP(context.Background(), StartUserTOTPParams{UserID: user.ID, Secret: util.RandomString(32)})
+	require.NoError(t, err)
+
+	// pending enrollments cannot log in
+	_, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{UserID: user.ID, LastUsedStep: 100})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.ConfirmUserTOTP(context.Background(), ConfirmUserTOTPParams{UserID: user.ID, LastUsedStep: 100})
+	require.NoError(t, err)
+
+	_, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{UserID: user.ID, LastUsedStep: 100})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	used, err := testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{UserID: user.ID, LastUsedStep: 101})
+	require.NoError(t, err)
+	require.Equal(t, int64(101), used.LastUsedStep)
+}
+
+func TestConfirmTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	_, err := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPParams{UserID: user.ID, Step: 1})
+	require.ErrorIs(t, err, ErrTOTPNotPending)
+
+	_, err = store.StartUserTOTP(context.Background(), StartUserTOTPParams{UserID: user.ID, Secret: util.RandomString(32)})
+	require.NoError(t, err)
+
+	hashes := []string{util.RandomString(64), util.RandomString(64)}
+	confirmed, err := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPParams{UserID: user.ID, Step: 5, CodeHashes: hashes})
+	require.NoError(t, err)
+	require.True(t, confirmed.ConfirmedAt.Valid)
+	require.Equal(t, int64(5), confirmed.LastUsedStep)
+
+	_, err = store.ConfirmTOTPTx(context.Background(), ConfirmTOTPParams{UserID: user.ID, Step: 6})
+	require.ErrorIs(t, err, ErrTOTPNotPending)
+
+	// recovery codes work once each
+	code, err := store.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{UserID: user.ID, CodeHash: hashes[0]})
+	require.NoError(t, err)
+	require.True(t, code.UsedAt.Valid)
+	_, err = store.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{UserID: user.ID, CodeHash: hashes[0]})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	other := createRandomUser(t)
+	_, err = store.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{UserID: other.ID, CodeHash: hashes[1]})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
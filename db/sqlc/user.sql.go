@@ -20,7 +20,7 @@ INSERT INTO "Users" (
     status
   )
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, name, email, hashed_pw, image, status, created_at
+RETURNING id, name, email, hashed_pw, image, status, created_at, hide_email, role, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -48,6 +48,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Image,
 		&i.Status,
 		&i.CreatedAt,
+		&i.HideEmail,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -68,19 +71,25 @@ SELECT id,
   email,
   image,
   status,
-  created_at
+  created_at,
+  hide_email,
+  role,
+  email_verified_at
 FROM "Users"
 WHERE id = $1
 LIMIT 1
 `
 
 type GetUserRow struct {
-	ID        int64          `json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	Image     sql.NullString `json:"image"`
-	Status    sql.NullString `json:"status"`
-	CreatedAt time.Time      `json:"createdAt"`
+	ID              int64          `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	Image           sql.NullString `json:"image"`
+	Status          sql.NullString `json:"status"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	HideEmail       bool           `json:"hideEmail"`
+	Role            string         `json:"role"`
+	EmailVerifiedAt sql.NullTime   `json:"emailVerifiedAt"`
 }
 
 func (q *Queries) GetUser(ctx context.Context, id int64) (GetUserRow, error) {
@@ -93,12 +102,15 @@ func (q *Queries) GetUser(ctx context.Context, id int64) (GetUserRow, error) {
 		&i.Image,
 		&i.Status,
 		&i.CreatedAt,
+		&i.HideEmail,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, name, email, hashed_pw, image, status, created_at
+SELECT id, name, email, hashed_pw, image, status, created_at, hide_email, role, email_verified_at
 FROM "Users"
 WHERE email = $1
 LIMIT 1
@@ -115,13 +127,30 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Image,
 		&i.Status,
 		&i.CreatedAt,
+		&i.HideEmail,
+		&i.Role,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
+const getUserPassword = `-- name: GetUserPassword :one
+SELECT hashed_pw
+FROM "Users"
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserPassword(ctx context.Context, id int64) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserPassword, id)
+	var hashed_pw string
+	err := row.Scan(&hashed_pw)
+	return hashed_pw, err
+}
+
 const listConvFromUser = `-- name: ListConvFromUser :many
 SELECT 
-"Conversation".id,"Conversation".name
+"Conversation".id,"Conversation".name,"Conversation".topic,"Conversation".description,"Conversation".created_at,"Conversation".kind,"Conversation".dm_user_low,"Conversation".dm_user_high
 FROM
 "Users"
 INNER JOIN "user_conversation" on "Users".id = "user_conversation".user_id
@@ -138,7 +167,16 @@ func (q *Queries) ListConvFromUser(ctx context.Context, id int64) ([]Conversatio
 	items := []Conversation{}
 	for rows.Next() {
 		var i Conversation
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Topic,
+			&i.Description,
+			&i.CreatedAt,
+			&i.Kind,
+			&i.DmUserLow,
+			&i.DmUserHigh,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -208,7 +246,8 @@ SELECT id,
   name,
   email,
   image,
-  status
+  status,
+  hide_email
 FROM "Users"
 ORDER BY id
 LIMIT $1 OFFSET $2
@@ -220,11 +259,12 @@ type ListUsersParams struct {
 }
 
 type ListUsersRow struct {
-	ID     int64          `json:"id"`
-	Name   string         `json:"name"`
-	Email  string         `json:"email"`
-	Image  sql.NullString `json:"image"`
-	Status sql.NullString `json:"status"`
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Image     sql.NullString `json:"image"`
+	Status    sql.NullString `json:"status"`
+	HideEmail bool           `json:"hideEmail"`
 }
 
 func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
@@ -242,6 +282,120 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUse
 			&i.Email,
 			&i.Image,
 			&i.Status,
+			&i.HideEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :one
+UPDATE "Users"
+SET email_verified_at = now()
+WHERE id = $1
+  and email = $2
+  and email_verified_at IS NULL
+RETURNING id, name, email, hashed_pw, image, status, created_at, hide_email, role, email_verified_at
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.HashedPw,
+		&i.Image,
+		&i.Status,
+		&i.CreatedAt,
+		&i.HideEmail,
+		&i.Role,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id,
+  name,
+  email,
+  image,
+  status,
+  hide_email
+FROM "Users"
+WHERE id <> $1
+  and (
+    name ILIKE $2
+    OR name % $3
+    OR (
+      hide_email = false
+      and (
+        email ILIKE $2
+        OR email % $3
+      )
+    )
+  )
+ORDER BY name ILIKE $2
+  OR (
+    hide_email = false
+    and email ILIKE $2
+  ) DESC,
+  similarity(name, $3) DESC,
+  id
+LIMIT $4
+`
+
+type SearchUsersParams struct {
+	UserID int64  `json:"userID"`
+	Prefix string `json:"prefix"`
+	Query  string `json:"query"`
+	Limit  int32  `json:"limit"`
+}
+
+type SearchUsersRow struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Image     sql.NullString `json:"image"`
+	Status    sql.NullString `json:"status"`
+	HideEmail bool           `json:"hideEmail"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.UserID,
+		arg.Prefix,
+		arg.Query,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchUsersRow{}
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Image,
+			&i.Status,
+			&i.HideEmail,
 		); err != nil {
 			return nil, err
 		}
@@ -263,32 +417,40 @@ SET
     email = coalesce($2, email),
     image = coalesce($3, image),
     status = coalesce($4, status),
-    hashed_pw = coalesce($5, hashed_pw)
+    hide_email = coalesce($5, hide_email),
+    -- a new address has to be verified again
+    email_verified_at = CASE
+      WHEN coalesce($2, email) = email THEN email_verified_at
+    END
 where id = $6
 RETURNING id,
   name,
   email,
   image,
   status,
-  created_at
+  created_at,
+  hide_email,
+  email_verified_at
 `
 
 type UpdateUserInfoParams struct {
-	Name     sql.NullString `json:"name"`
-	Email    sql.NullString `json:"email"`
-	Image    sql.NullString `json:"image"`
-	Status   sql.NullString `json:"status"`
-	HashedPw sql.NullString `json:"hashedPw"`
-	ID       int64          `json:"id"`
+	Name      sql.NullString `json:"name"`
+	Email     sql.NullString `json:"email"`
+	Image     sql.NullString `json:"image"`
+	Status    sql.NullString `json:"status"`
+	HideEmail sql.NullBool   `json:"hideEmail"`
+	ID        int64          `json:"id"`
 }
 
 type UpdateUserInfoRow struct {
-	ID        int64          `json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	Image     sql.NullString `json:"image"`
-	Status    sql.NullString `json:"status"`
-	CreatedAt time.Time      `json:"createdAt"`
+	ID              int64          `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	Image           sql.NullString `json:"image"`
+	Status          sql.NullString `json:"status"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	HideEmail       bool           `json:"hideEmail"`
+	EmailVerifiedAt sql.NullTime   `json:"emailVerifiedAt"`
 }
 
 func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams) (UpdateUserInfoRow, error) {
@@ -297,7 +459,7 @@ func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams)
 		arg.Email,
 		arg.Image,
 		arg.Status,
-		arg.HashedPw,
+		arg.HideEmail,
 		arg.ID,
 	)
 	var i UpdateUserInfoRow
@@ -308,6 +470,58 @@ func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams)
 		&i.Image,
 		&i.Status,
 		&i.CreatedAt,
+		&i.HideEmail,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE "Users"
+SET hashed_pw = $2
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID       int64  `json:"id"`
+	HashedPw string `json:"hashedPw"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPw)
+	return err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE "Users"
+SET role = $2
+WHERE id = $1
+RETURNING id,
+  name,
+  email,
+  role
+`
+
+type UpdateUserRoleParams struct {
+	ID   int64  `json:"id"`
+	Role string `json:"role"`
+}
+
+type UpdateUserRoleRow struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (UpdateUserRoleRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i UpdateUserRoleRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Role,
 	)
 	return i, err
 }
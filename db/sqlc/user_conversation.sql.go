@@ -7,12 +7,38 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
+const createConvMember = `-- name: CreateConvMember :one
+INSERT INTO "user_conversation" (user_id, conv_id, role)
+VALUES($1, $2, $3)
+RETURNING id, user_id, conv_id, role, last_read_message_id
+`
+
+type CreateConvMemberParams struct {
+	UserID int64  `json:"userID"`
+	ConvID int64  `json:"convID"`
+	Role   string `json:"role"`
+}
+
+func (q *Queries) CreateConvMember(ctx context.Context, arg CreateConvMemberParams) (UserConversation, error) {
+	row := q.db.QueryRowContext(ctx, createConvMember, arg.UserID, arg.ConvID, arg.Role)
+	var i UserConversation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
+	return i, err
+}
+
 const createUser_conversation = `-- name: CreateUser_conversation :one
 INSERT INTO "user_conversation" (user_id, conv_id)
 VALUES($1, $2)
-RETURNING id, user_id, conv_id
+RETURNING id, user_id, conv_id, role, last_read_message_id
 `
 
 type CreateUser_conversationParams struct {
@@ -23,7 +49,13 @@ type CreateUser_conversationParams struct {
 func (q *Queries) CreateUser_conversation(ctx context.Context, arg CreateUser_conversationParams) (UserConversation, error) {
 	row := q.db.QueryRowContext(ctx, createUser_conversation, arg.UserID, arg.ConvID)
 	var i UserConversation
-	err := row.Scan(&i.ID, &i.UserID, &i.ConvID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
 	return i, err
 }
 
@@ -53,8 +85,35 @@ func (q *Queries) DeleteUser_conversation_by_id(ctx context.Context, id int64) e
 	return err
 }
 
+const getConvSuccessor = `-- name: GetConvSuccessor :one
+SELECT id, user_id, conv_id, role, last_read_message_id
+from "user_conversation"
+WHERE conv_id = $1
+  and user_id <> $2
+ORDER BY role = 'admin' DESC, id
+LIMIT 1
+`
+
+type GetConvSuccessorParams struct {
+	ConvID int64 `json:"convID"`
+	UserID int64 `json:"userID"`
+}
+
+func (q *Queries) GetConvSuccessor(ctx context.Context, arg GetConvSuccessorParams) (UserConversation, error) {
+	row := q.db.QueryRowContext(ctx, getConvSuccessor, arg.ConvID, arg.UserID)
+	var i UserConversation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
+	return i, err
+}
+
 const getUser_conv_by_id = `-- name: GetUser_conv_by_id :one
-SELECT id, user_id, conv_id
+SELECT id, user_id, conv_id, role, last_read_message_id
 from "user_conversation"
 WHERE id = $1
 `
@@ -62,12 +121,18 @@ WHERE id = $1
 func (q *Queries) GetUser_conv_by_id(ctx context.Context, id int64) (UserConversation, error) {
 	row := q.db.QueryRowContext(ctx, getUser_conv_by_id, id)
 	var i UserConversation
-	err := row.Scan(&i.ID, &i.UserID, &i.ConvID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
 	return i, err
 }
 
 const getUser_conversation = `-- name: GetUser_conversation :one
-SELECT id, user_id, conv_id
+SELECT id, user_id, conv_id, role, last_read_message_id
 from "user_conversation"
 WHERE user_id = $1
   and conv_id = $2
@@ -81,12 +146,48 @@ type GetUser_conversationParams struct {
 func (q *Queries) GetUser_conversation(ctx context.Context, arg GetUser_conversationParams) (UserConversation, error) {
 	row := q.db.QueryRowContext(ctx, getUser_conversation, arg.UserID, arg.ConvID)
 	var i UserConversation
-	err := row.Scan(&i.ID, &i.UserID, &i.ConvID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
 	return i, err
 }
 
+const listConvMembers = `-- name: ListConvMembers :many
+SELECT user_id
+from "user_conversation"
+WHERE conv_id = $1
+ORDER BY user_id
+`
+
+func (q *Queries) ListConvMembers(ctx context.Context, convID int64) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listConvMembers, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var user_id int64
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUser_conversationByUser = `-- name: ListUser_conversationByUser :many
-SELECT id, user_id, conv_id
+SELECT id, user_id, conv_id, role, last_read_message_id
 from "user_conversation"
 WHERE user_id = $1
 ORDER BY user_id
@@ -101,7 +202,13 @@ func (q *Queries) ListUser_conversationByUser(ctx context.Context, userID int64)
 	items := []UserConversation{}
 	for rows.Next() {
 		var i UserConversation
-		if err := rows.Scan(&i.ID, &i.UserID, &i.ConvID); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ConvID,
+			&i.Role,
+			&i.LastReadMessageID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -116,7 +223,7 @@ func (q *Queries) ListUser_conversationByUser(ctx context.Context, userID int64)
 }
 
 const listUser_conversations = `-- name: ListUser_conversations :many
-SELECT id, user_id, conv_id
+SELECT id, user_id, conv_id, role, last_read_message_id
 from "user_conversation"
 ORDER BY id
 `
@@ -130,7 +237,13 @@ func (q *Queries) ListUser_conversations(ctx context.Context) ([]UserConversatio
 	items := []UserConversation{}
 	for rows.Next() {
 		var i UserConversation
-		if err := rows.Scan(&i.ID, &i.UserID, &i.ConvID); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ConvID,
+			&i.Role,
+			&i.LastReadMessageID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -143,3 +256,68 @@ func (q *Queries) ListUser_conversations(ctx context.Context) ([]UserConversatio
 	}
 	return items, nil
 }
+
+const markConvRead = `-- name: MarkConvRead :one
+UPDATE "user_conversation"
+SET last_read_message_id = GREATEST(
+    last_read_message_id,
+    (
+      SELECT max(id)
+      FROM "Message"
+      WHERE "Message".conv_id = $1
+        and (
+          $2::bigint IS NULL
+          OR "Message".id <= $2
+        )
+    )
+  )
+WHERE user_id = $3
+  and conv_id = $1
+RETURNING id, user_id, conv_id, role, last_read_message_id
+`
+
+type MarkConvReadParams struct {
+	ConvID    int64         `json:"convID"`
+	MessageID sql.NullInt64 `json:"messageID"`
+	UserID    int64         `json:"userID"`
+}
+
+func (q *Queries) MarkConvRead(ctx context.Context, arg MarkConvReadParams) (UserConversation, error) {
+	row := q.db.QueryRowContext(ctx, markConvRead, arg.ConvID, arg.MessageID, arg.UserID)
+	var i UserConversation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
+	return i, err
+}
+
+const updateConvMemberRole = `-- name: UpdateConvMemberRole :one
+UPDATE "user_conversation"
+SET role = $3
+WHERE user_id = $1
+  and conv_id = $2
+RETURNING id, user_id, conv_id, role, last_read_message_id
+`
+
+type UpdateConvMemberRoleParams struct {
+	UserID int64  `json:"userID"`
+	ConvID int64  `json:"convID"`
+	Role   string `json:"role"`
+}
+
+func (q *Queries) UpdateConvMemberRole(ctx context.Context, arg UpdateConvMemberRoleParams) (UserConversation, error) {
+	row := q.db.QueryRowContext(ctx, updateConvMemberRole, arg.UserID, arg.ConvID, arg.Role)
+	var i UserConversation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ConvID,
+		&i.Role,
+		&i.LastReadMessageID,
+	)
+	return i, err
+}
@@ -6,6 +6,7 @@ import (
 
 	"testing"
 
+	"github.com/rjriverac/messaging-server/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,6 +25,7 @@ func createRndUsrConv(t *testing.T) UserConversation {
 	require.NotEmpty(t, uconv)
 	require.Equal(t, arg.UserID, uconv.UserID)
 	require.Equal(t, arg.UserID, uconv.UserID)
+	require.Equal(t, RoleMember, uconv.Role)
 
 	return uconv
 }
@@ -117,4 +119,102 @@ func TestDelUConvById(t *testing.T) {
 	require.Empty(t,conv2)
 	require.Error(t,nerr)
 	require.EqualError(t,nerr,sql.ErrNoRows.Error())
-}
\ No newline at end of file
+}
+func TestListConvMembers(t *testing.T) {
+	conv := createRandConv(t)
+
+	n := 5
+	for i := 0; i < n; i++ {
+		user := createRandomUser(t)
+		_, err := testQueries.CreateUser_conversation(context.Background(), CreateUser_conversationParams{UserID: user.ID, ConvID: conv.ID})
+		require.NoError(t, err)
+	}
+
+	members, err := testQueries.ListConvMembers(context.Background(), conv.ID)
+	require.NoError(t, err)
+	require.Len(t, members, n)
+	for _, id := range members {
+		require.NotZero(t, id)
+	}
+}
+
+func TestCreateConvMemberUnique(t *testing.T) {
+	member := createRndUsrConv(t)
+
+	_, err := testQueries.CreateConvMember(context.Background(), CreateConvMemberParams{
+		UserID: member.UserID,
+		ConvID: member.ConvID,
+		Role:   RoleAdmin,
+	})
+	require.Error(t, err)
+
+	updated, err := testQueries.UpdateConvMemberRole(context.Background(), UpdateConvMemberRoleParams{
+		UserID: member.UserID,
+		ConvID: member.ConvID,
+		Role:   RoleAdmin,
+	})
+	require.NoError(t, err)
+	require.Equal(t, member.ID, updated.ID)
+	require.Equal(t, RoleAdmin, updated.Role)
+
+	_, err = testQueries.UpdateConvMemberRole(context.Background(), UpdateConvMemberRoleParams{
+		UserID: member.UserID,
+		ConvID: member.ConvID,
+		Role:   "superuser",
+	})
+	require.Error(t, err)
+}
+
+func TestMarkConvRead(t *testing.T) {
+	member := createRndUsrConv(t)
+	other := createRandConv(t)
+
+	msgs := make([]Message, 3)
+	for i := range msgs {
+		var err error
+		msgs[i], err = testQueries.CreateMessage(context.Background(), CreateMessageParams{
+			From:    util.RandomString(6),
+			Content: util.RandomString(20),
+			ConvID:  member.ConvID,
+		})
+		require.NoError(t, err)
+	}
+	elsewhere, err := testQueries.CreateMessage(context.Background(), CreateMessageParams{
+		From:    util.RandomString(6),
+		Content: util.RandomString(20),
+		ConvID:  other.ID,
+	})
+	require.NoError(t, err)
+
+	read, err := testQueries.MarkConvRead(context.Background(), MarkConvReadParams{
+		ConvID:    member.ConvID,
+		MessageID: sql.NullInt64{Int64: msgs[1].ID, Valid: true},
+		UserID:    member.UserID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, msgs[1].ID, read.LastReadMessageID.Int64)
+
+	// the marker never moves backwards
+	read, err = testQueries.MarkConvRead(context.Background(), MarkConvReadParams{
+		ConvID:    member.ConvID,
+		MessageID: sql.NullInt64{Int64: msgs[0].ID, Valid: true},
+		UserID:    member.UserID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, msgs[1].ID, read.LastReadMessageID.Int64)
+
+	// ids from other conversations are clamped to this one's messages
+	read, err = testQueries.MarkConvRead(context.Background(), MarkConvReadParams{
+		ConvID:    member.ConvID,
+		MessageID: sql.NullInt64{Int64: elsewhere.ID, Valid: true},
+		UserID:    member.UserID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, msgs[2].ID, read.LastReadMessageID.Int64)
+
+	_, err = testQueries.MarkConvRead(context.Background(), MarkConvReadParams{
+		ConvID: other.ID,
+		UserID: member.UserID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserIdentity(t *testing.T) {
+	user := createRandomUser(t)
+	arg := CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: "mock",
+		Subject:  util.RandomString(16),
+		Email:    user.Email,
+	}
+
+	identity, err := testQueries.CreateUserIdentity(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, identity.UserID)
+	require.Equal(t, arg.Subject, identity.Subject)
+	require.NotZero(t, identity.CreatedAt)
+
+	got, err := testQueries.GetUserIdentity(context.Background(), GetUserIdentityParams{Provider: arg.Provider, Subject: arg.Subject})
+	require.NoError(t, err)
+	require.Equal(t, identity, got)
+
+	// a subject is only unique within its provider
+	_, err = testQueries.GetUserIdentity(context.Background(), GetUserIdentityParams{Provider: "other", Subject: arg.Subject})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = testQueries.CreateUserIdentity(context.Background(), arg)
+	require.Error(t, err)
+
+	// identities go with the account
+	require.NoError(t, testQueries.DeleteUser(context.Background(), user.ID))
+	_, err = testQueries.GetUserIdentity(context.Background(), GetUserIdentityParams{Provider: arg.Provider, Subject: arg.Subject})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -31,6 +31,8 @@ func createRandomUser(t *testing.T) User {
 
 	require.NotZero(t, user.ID)
 	require.NotZero(t, user.CreatedAt)
+	require.Equal(t, "user", user.Role)
+	require.False(t, user.EmailVerifiedAt.Valid)
 
 	return user
 }
@@ -71,12 +73,11 @@ func TestUpdateUser(t *testing.T) {
 	user := createRandomUser(t)
 
 	arg := UpdateUserInfoParams{
-		ID:       user.ID,
-		Name:     util.NullStrGen(5),
-		Email:    sql.NullString{String: util.RandomEmail(), Valid: true},
-		Image:    util.NullStrGen(15),
-		Status:   util.NullStrGen(15),
-		HashedPw: sql.NullString{String: "", Valid: false},
+		ID:     user.ID,
+		Name:   util.NullStrGen(5),
+		Email:  sql.NullString{String: util.RandomEmail(), Valid: true},
+		Image:  util.NullStrGen(15),
+		Status: util.NullStrGen(15),
 	}
 
 	user2, err := testQueries.UpdateUserInfo(context.Background(), arg)
@@ -105,6 +106,49 @@ func TestUpdateUser(t *testing.T) {
 
 }
 
+func TestUpdateUserRole(t *testing.T) {
+	user := createRandomUser(t)
+
+	updated, err := testQueries.UpdateUserRole(context.Background(), UpdateUserRoleParams{ID: user.ID, Role: "admin"})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, updated.ID)
+	require.Equal(t, "admin", updated.Role)
+
+	got, err := testQueries.GetUser(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "admin", got.Role)
+
+	// only known roles pass the check constraint
+	_, err = testQueries.UpdateUserRole(context.Background(), UpdateUserRoleParams{ID: user.ID, Role: "owner"})
+	require.Error(t, err)
+}
+
+func TestMarkEmailVerified(t *testing.T) {
+	user := createRandomUser(t)
+
+	// a code for an address the user no longer has is rejected
+	_, err := testQueries.MarkEmailVerified(context.Background(), MarkEmailVerifiedParams{ID: user.ID, Email: util.RandomEmail()})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	verified, err := testQueries.MarkEmailVerified(context.Background(), MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})
+	require.NoError(t, err)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+	require.WithinDuration(t, time.Now(), verified.EmailVerifiedAt.Time, time.Second)
+
+	// single use
+	_, err = testQueries.MarkEmailVerified(context.Background(), MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// keeping the address keeps it verified, a new one has to be confirmed
+	updated, err := testQueries.UpdateUserInfo(context.Background(), UpdateUserInfoParams{ID: user.ID, Email: sql.NullString{String: user.Email, Valid: true}})
+	require.NoError(t, err)
+	require.True(t, updated.EmailVerifiedAt.Valid)
+
+	updated, err = testQueries.UpdateUserInfo(context.Background(), UpdateUserInfoParams{ID: user.ID, Email: sql.NullString{String: util.RandomEmail(), Valid: true}})
+	require.NoError(t, err)
+	require.False(t, updated.EmailVerifiedAt.Valid)
+}
+
 func TestDeleteUser(t *testing.T) {
 	user := createRandomUser(t)
 	err := testQueries.DeleteUser(context.Background(), user.ID)
@@ -242,3 +286,57 @@ func TestListConvFromUser(t *testing.T) {
 	}
 
 }
+
+func TestSearchUsers(t *testing.T) {
+	searcher := createRandomUser(t)
+	prefix := util.RandomString(8)
+
+	create := func(name, email string) User {
+		hashedPw, err := util.HashPassword(util.RandomString(10))
+		require.NoError(t, err)
+		user, err := testQueries.CreateUser(context.Background(), CreateUserParams{
+			Name:     name,
+			Email:    email,
+			HashedPw: hashedPw,
+		})
+		require.NoError(t, err)
+		return user
+	}
+	byName := create(prefix+" smith", util.RandomEmail())
+	byEmail := create(util.RandomUserGen(), prefix+"@example.com")
+	hidden := create(util.RandomUserGen(), prefix+"x@example.com")
+	_, err := testQueries.UpdateUserInfo(context.Background(), UpdateUserInfoParams{
+		ID:        hidden.ID,
+		HideEmail: sql.NullBool{Bool: true, Valid: true},
+	})
+	require.NoError(t, err)
+
+	found, err := testQueries.SearchUsers(context.Background(), SearchUsersParams{
+		UserID: searcher.ID,
+		Prefix: prefix + "%",
+		Query:  prefix,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+
+	ids := make([]int64, len(found))
+	for i, user := range found {
+		ids[i] = user.ID
+	}
+	require.Contains(t, ids, byName.ID)
+	require.Contains(t, ids, byEmail.ID)
+	// opted out, so the email cannot be used to find them
+	require.NotContains(t, ids, hidden.ID)
+
+	// the searcher never finds themselves
+	self, err := testQueries.SearchUsers(context.Background(), SearchUsersParams{
+		UserID: byName.ID,
+		Prefix: prefix + "%",
+		Query:  prefix,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	for _, user := range self {
+		require.NotEqual(t, byName.ID, user.ID)
+	}
+}
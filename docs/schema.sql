@@ -9,7 +9,10 @@ CREATE TABLE "Users" (
   "hashed_pw" varchar NOT NULL,
   "image" varchar,
   "status" varchar,
-  "created_at" timestamptz NOT NULL DEFAULT (now())
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "hide_email" bool NOT NULL DEFAULT (false),
+  "role" varchar NOT NULL DEFAULT 'user',
+  "email_verified_at" timestamptz
 );
 
 CREATE TABLE "Message" (
@@ -17,18 +20,37 @@ CREATE TABLE "Message" (
   "from" varchar NOT NULL,
   "content" varchar NOT NULL,
   "created_at" timestamptz DEFAULT (now()),
-  "conv_id" bigint
+  "conv_id" bigint,
+  "edited_at" timestamptz,
+  "deleted_at" timestamptz,
+  "sender_id" bigint,
+  "is_system" bool NOT NULL DEFAULT (false)
+);
+
+CREATE TABLE "message_edits" (
+  "id" bigserial PRIMARY KEY,
+  "message_id" bigint NOT NULL,
+  "content" varchar NOT NULL,
+  "edited_at" timestamptz NOT NULL DEFAULT (now())
 );
 
 CREATE TABLE "Conversation" (
   "id" bigserial UNIQUE PRIMARY KEY,
-  "name" varchar
+  "name" varchar,
+  "topic" varchar,
+  "description" varchar,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "kind" varchar NOT NULL DEFAULT 'group',
+  "dm_user_low" bigint,
+  "dm_user_high" bigint
 );
 
 CREATE TABLE "user_conversation" (
   "id" bigserial PRIMARY KEY,
   "user_id" bigint,
-  "conv_id" bigint
+  "conv_id" bigint,
+  "role" varchar NOT NULL DEFAULT 'member',
+  "last_read_message_id" bigint
 );
 
 CREATE TABLE "sessions" (
@@ -40,17 +62,114 @@ CREATE TABLE "sessions" (
   "client_ip" varchar NOT NULL,
   "is_blocked" bool NOT NULL DEFAULT (false),
   "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "family_id" uuid NOT NULL,
+  "rotated_at" timestamptz
+);
+
+CREATE TABLE "revoked_tokens" (
+  "id" uuid PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "revoked_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_token_cutoffs" (
+  "user_id" bigint PRIMARY KEY,
+  "revoked_before" timestamptz NOT NULL
+);
+
+CREATE TABLE "password_resets" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
   "created_at" timestamptz NOT NULL DEFAULT (now())
 );
 
-CREATE INDEX ON "user_conversation" ("user_id", "conv_id");
+CREATE TABLE "user_totp" (
+  "user_id" bigint PRIMARY KEY,
+  "secret" varchar NOT NULL,
+  "confirmed_at" timestamptz,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz
+);
+
+CREATE TABLE "user_identities" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "provider" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX "Users_name_trgm_idx" ON "Users" USING GIN ("name" gin_trgm_ops);
+
+CREATE INDEX "Users_email_trgm_idx" ON "Users" USING GIN ("email" gin_trgm_ops);
+
+CREATE INDEX ON "Message" ("conv_id", "created_at", "id");
+
+CREATE INDEX ON "Message" ("sender_id");
+
+CREATE INDEX "Message_content_search_idx" ON "Message" USING GIN (to_tsvector('english', "content"));
+
+CREATE INDEX ON "message_edits" ("message_id");
+
+CREATE UNIQUE INDEX ON "user_conversation" ("user_id", "conv_id");
+
+CREATE UNIQUE INDEX ON "Conversation" ("dm_user_low", "dm_user_high");
+
+CREATE INDEX ON "sessions" ("family_id");
+
+CREATE INDEX ON "revoked_tokens" ("expires_at");
+
+CREATE INDEX ON "password_resets" ("user_id");
+
+CREATE INDEX ON "password_resets" ("expires_at");
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("user_id", "code_hash");
+
+CREATE UNIQUE INDEX ON "user_identities" ("provider", "subject");
+
+CREATE INDEX ON "user_identities" ("user_id");
 
 ALTER TABLE "Message" ADD FOREIGN KEY ("conv_id") REFERENCES "Conversation" ("id");
 
+ALTER TABLE "Message" ADD FOREIGN KEY ("sender_id") REFERENCES "Users" ("id") ON DELETE SET NULL;
+
+ALTER TABLE "message_edits" ADD FOREIGN KEY ("message_id") REFERENCES "Message" ("id") ON DELETE CASCADE;
+
 ALTER TABLE "user_conversation" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id");
 
 ALTER TABLE "user_conversation" ADD FOREIGN KEY ("conv_id") REFERENCES "Conversation" ("id");
 
+ALTER TABLE "Conversation" ADD FOREIGN KEY ("dm_user_low") REFERENCES "Users" ("id");
+
+ALTER TABLE "Conversation" ADD FOREIGN KEY ("dm_user_high") REFERENCES "Users" ("id");
+
+ALTER TABLE "user_conversation" ADD FOREIGN KEY ("last_read_message_id") REFERENCES "Message" ("id") ON DELETE SET NULL;
+
 ALTER TABLE "sessions" ADD FOREIGN KEY ("email") REFERENCES "Users" ("email");
 
 ALTER TABLE "sessions" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id");
+
+ALTER TABLE "revoked_tokens" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_token_cutoffs" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_totp" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("user_id") REFERENCES "Users" ("id") ON DELETE CASCADE;
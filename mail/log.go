@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const logSeparator = "----"
+
+// LogMailer writes every message to w instead of delivering it, for local
+// development and tests.
+type LogMailer struct {
+	mu   sync.Mutex
+	from string
+	w    io.Writer
+}
+
+func NewLogMailer(w io.Writer, from string) Mailer {
+	return &LogMailer{w: w, from: from}
+}
+
+// NewFileMailer appends messages to the file at path, creating it if needed.
+func NewFileMailer(path, from string) (Mailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open mail log: %w", err)
+	}
+	return NewLogMailer(file, from), nil
+}
+
+func (mailer *LogMailer) Send(_ context.Context, msg Message) error {
+	data, err := format(mailer.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	_, err = fmt.Fprintf(mailer.w, "%s\r\n%s", logSeparator, data)
+	return err
+}
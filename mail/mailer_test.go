@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer(&buf, "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "line one\nline two",
+	})
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, logSeparator))
+	require.Contains(t, out, "From: no-reply@example.com\r\n")
+	require.Contains(t, out, "To: user@example.com\r\n")
+	require.Contains(t, out, "Subject: Hello\r\n")
+	require.Contains(t, out, "\r\n\r\nline one\r\nline two\r\n")
+}
+
+func TestHeaderInjection(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer(&buf, "no-reply@example.com")
+
+	for _, msg := range []Message{
+		{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hello"},
+		{To: "user@example.com", Subject: "Hello\nBcc: other@example.com"},
+	} {
+		err := mailer.Send(context.Background(), msg)
+		require.ErrorIs(t, err, ErrInvalidHeader)
+	}
+	require.Zero(t, buf.Len())
+}
+
+// fakeSMTP accepts a single message and hands its DATA section to received.
+func fakeSMTP(t *testing.T, received chan<- string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSMTPMailer(t *testing.T) {
+	received := make(chan string, 1)
+	addr := fakeSMTP(t, received)
+
+	mailer, err := NewSMTPMailer(addr, "", "", "no-reply@example.com")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = mailer.Send(ctx, Message{To: "user@example.com", Subject: "Hello", Body: "hi"})
+	require.NoError(t, err)
+
+	data := <-received
+	require.Contains(t, data, "To: user@example.com\r\n")
+	require.Contains(t, data, "\r\n\r\nhi\r\n")
+}
+
+func TestSMTPMailerBadAddr(t *testing.T) {
+	_, err := NewSMTPMailer("localhost", "", "", "no-reply@example.com")
+	require.Error(t, err)
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/rjriverac/messaging-server/api"
@@ -27,8 +33,20 @@ func main() {
 		log.Fatal("error creating server:", err)
 	}
 
-	err = server.StartServer(config.ServerAddr)
-	if err != nil {
-		log.Fatal("cannot start server:", err)
+	go func() {
+		err := server.StartServer(config.ServerAddr)
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("cannot start server:", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatal("cannot shut down server:", err)
 	}
 }
@@ -0,0 +1,69 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// JWK is the public half of a signing key as described by RFC 7517, only
+// the members needed for RSA and Ed25519 keys are present.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyPublisher is implemented by makers whose tokens can be verified by
+// anyone holding the published public keys.
+type KeyPublisher interface {
+	JWKS() JWKSet
+	Algorithms() []string
+}
+
+// jwtMethod picks the JWS algorithm matching the type of key.
+func jwtMethod(key Key) (jwt.SigningMethod, error) {
+	switch private := key.Private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("key %q: RSA keys must be at least 2048 bits", key.ID)
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("key %q: unsupported key type %T", key.ID, key.Private)
+}
+
+func newJWK(key Key, method jwt.SigningMethod) JWK {
+	jwk := JWK{
+		Use: "sig",
+		Alg: method.Alg(),
+		Kid: key.ID,
+	}
+	enc := base64.RawURLEncoding
+	switch public := key.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(public.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = enc.EncodeToString(public)
+	}
+	return jwk
+}
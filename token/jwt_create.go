@@ -3,6 +3,7 @@ package token
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -10,22 +11,45 @@ import (
 
 const minSecretKeyLen = 32
 
+// jwtClaims adds the registered claims that generic JWT libraries check to
+// the payload: exp, iat and nbf as NumericDate seconds and the user as sub.
+// The payload's own fields stay so PASETO and JWT tokens carry the same data.
+type jwtClaims struct {
+	*Payload
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func newJWTClaims(payload *Payload) jwtClaims {
+	return jwtClaims{
+		Payload:   payload,
+		Subject:   strconv.FormatInt(payload.User, 10),
+		IssuedAt:  payload.CreatedAt.Unix(),
+		NotBefore: payload.CreatedAt.Unix(),
+		ExpiresAt: payload.Expires.Unix(),
+	}
+}
+
 type JWTMaker struct {
 	secretKey string
+	rules     claimRules
 }
 
-func NewJWT(secretkey string) (Maker, error) {
+func NewJWT(secretkey string, opts ...Option) (Maker, error) {
 	if len(secretkey) < minSecretKeyLen {
 		return nil, fmt.Errorf("invalid key: must be at least %d characters", minSecretKeyLen)
 	}
-	return &JWTMaker{secretkey}, nil
+	return &JWTMaker{secretkey, newClaimRules(opts)}, nil
 }
-func (maker *JWTMaker) CreateToken(id int64, duration time.Duration) (string, *Payload, error) {
-	payload, err := CreatePayload(id, duration)
+func (maker *JWTMaker) CreateToken(id int64, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	payload, err := CreatePayload(id, role, scopes, duration)
 	if err != nil {
 		return "", payload, err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	maker.rules.stamp(payload)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newJWTClaims(payload))
 	finalToken, err := token.SignedString([]byte(maker.secretKey))
 	return finalToken, payload, err
 }
@@ -37,17 +61,17 @@ func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
 		return []byte(maker.secretKey), nil
 
 	}
-	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
-	if err != nil {
+	claims := &jwtClaims{Payload: &Payload{}}
+	if _, err := jwt.ParseWithClaims(token, claims, keyFunc); err != nil {
 		verr, ok := err.(*jwt.ValidationError)
 		if ok && errors.Is(verr.Inner, ErrorExpiredToken) {
 			return nil, ErrorExpiredToken
 		}
 		return nil, ErrInvalidToken
 	}
-	payload, ok := jwtToken.Claims.(*Payload)
-	if !ok {
-		return nil, ErrInvalidToken
+	payload := claims.Payload
+	if err := maker.rules.check(payload); err != nil {
+		return nil, err
 	}
 	return payload, nil
 }
@@ -19,7 +19,8 @@ func TestJWTMaker(t *testing.T) {
 	issuedAt := time.Now()
 	expires := time.Now().Add(duration)
 
-	token, payload, err := maker.CreateToken(id, duration)
+	scopes := ScopesForRole(RoleAdmin)
+	token, payload, err := maker.CreateToken(id, RoleAdmin, scopes, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -30,6 +31,9 @@ func TestJWTMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, id, payload.User)
+	require.Equal(t, RoleAdmin, payload.Role)
+	require.Equal(t, scopes, payload.Scopes)
+	require.True(t, payload.HasScope(ScopeAdmin))
 	require.WithinDuration(t, issuedAt, payload.CreatedAt, time.Second)
 	require.WithinDuration(t, expires, payload.Expires, time.Second)
 }
@@ -37,7 +41,7 @@ func TestJWTExpired(t *testing.T) {
 	maker, err := NewJWT(util.RandomString(32))
 	require.NoError(t, err)
 
-	token, payload, err := maker.CreateToken(util.RandomInt(0, 1000), -time.Hour)
+	token, payload, err := maker.CreateToken(util.RandomInt(0, 1000), RoleUser, ScopesForRole(RoleUser), -time.Hour)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -49,7 +53,7 @@ func TestJWTExpired(t *testing.T) {
 }
 
 func TestInvalidTokenBadHeader(t *testing.T) {
-	payload, err := CreatePayload(util.RandomInt(0, 1000), time.Hour)
+	payload, err := CreatePayload(util.RandomInt(0, 1000), RoleUser, ScopesForRole(RoleUser), time.Hour)
 	require.NoError(t, err)
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
@@ -0,0 +1,104 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type jwtKey struct {
+	key    Key
+	method jwt.SigningMethod
+}
+
+// JWTPublicMaker signs JWTs with RS256 or EdDSA depending on the key, the kid
+// header names the key so other services can verify against the JWKS.
+type JWTPublicMaker struct {
+	signing jwtKey
+	// ordered like KeySet.Keys so the published set is stable
+	ordered []jwtKey
+	keys    map[string]jwtKey
+	rules   claimRules
+}
+
+func NewJWTPublicMaker(keys *KeySet, opts ...Option) (Maker, error) {
+	maker := &JWTPublicMaker{
+		keys:  map[string]jwtKey{},
+		rules: newClaimRules(opts),
+	}
+	for _, key := range keys.Keys {
+		method, err := jwtMethod(key)
+		if err != nil {
+			return nil, err
+		}
+		maker.keys[key.ID] = jwtKey{key: key, method: method}
+		maker.ordered = append(maker.ordered, maker.keys[key.ID])
+	}
+	maker.signing = maker.keys[keys.Signing.ID]
+	return maker, nil
+}
+
+func (maker *JWTPublicMaker) CreateToken(id int64, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	payload, err := CreatePayload(id, role, scopes, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	maker.rules.stamp(payload)
+	token := jwt.NewWithClaims(maker.signing.method, newJWTClaims(payload))
+	token.Header["kid"] = maker.signing.key.ID
+	finalToken, err := token.SignedString(maker.signing.key.Private)
+	return finalToken, payload, err
+}
+
+func (maker *JWTPublicMaker) VerifyToken(token string) (*Payload, error) {
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := maker.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// never let the token choose a weaker algorithm than its key's
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.key.Private.Public(), nil
+	}
+	claims := &jwtClaims{Payload: &Payload{}}
+	if _, err := jwt.ParseWithClaims(token, claims, keyFunc); err != nil {
+		verr, ok := err.(*jwt.ValidationError)
+		if ok && errors.Is(verr.Inner, ErrorExpiredToken) {
+			return nil, ErrorExpiredToken
+		}
+		if ok && errors.Is(verr.Inner, ErrUnknownKey) {
+			return nil, ErrUnknownKey
+		}
+		return nil, ErrInvalidToken
+	}
+	payload := claims.Payload
+	if err := maker.rules.check(payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (maker *JWTPublicMaker) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range maker.ordered {
+		set.Keys = append(set.Keys, newJWK(key.key, key.method))
+	}
+	return set
+}
+
+func (maker *JWTPublicMaker) Algorithms() []string {
+	var algs []string
+	seen := map[string]bool{}
+	for _, key := range maker.ordered {
+		alg := key.method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
@@ -0,0 +1,172 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRSAKeyFile(t *testing.T, dir, id string, bits int) {
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+keyFileExt), data, 0600))
+}
+
+func newJWTPublicMaker(t *testing.T, dir string) *JWTPublicMaker {
+	keys, err := LoadKeyDir(dir)
+	require.NoError(t, err)
+	maker, err := NewJWTPublicMaker(keys)
+	require.NoError(t, err)
+	return maker.(*JWTPublicMaker)
+}
+
+func TestJWTPublicMaker(t *testing.T) {
+	testCases := []struct {
+		name    string
+		alg     string
+		makeKey func(t *testing.T, dir string)
+	}{{
+		name: "RS256",
+		alg:  "RS256",
+		makeKey: func(t *testing.T, dir string) {
+			writeRSAKeyFile(t, dir, "2024-01-01", 2048)
+		},
+	}, {
+		name: "EdDSA",
+		alg:  "EdDSA",
+		makeKey: func(t *testing.T, dir string) {
+			require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.makeKey(t, dir)
+			maker := newJWTPublicMaker(t, dir)
+
+			id := util.RandomInt(1, 1000)
+			token, payload, err := maker.CreateToken(id, RoleUser, ScopesForRole(RoleUser), time.Minute)
+			require.NoError(t, err)
+			require.NotEmpty(t, payload)
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, id, payload.User)
+			require.Equal(t, []string{tc.alg}, maker.Algorithms())
+
+			expired, _, err := maker.CreateToken(id, RoleUser, ScopesForRole(RoleUser), -time.Minute)
+			require.NoError(t, err)
+			_, err = maker.VerifyToken(expired)
+			require.EqualError(t, err, ErrorExpiredToken.Error())
+		})
+	}
+}
+
+// TestJWKSVerify checks a downstream service can verify tokens using nothing
+// but the published key set.
+func TestJWKSVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKeyFile(t, dir, "2024-01-01", 2048)
+	require.NoError(t, GenerateKeyFile(dir, "2024-02-01"))
+	maker := newJWTPublicMaker(t, dir)
+
+	set := maker.JWKS()
+	require.Len(t, set.Keys, 2)
+	require.Equal(t, "RSA", set.Keys[0].Kty)
+	require.Equal(t, "RS256", set.Keys[0].Alg)
+	require.Equal(t, "OKP", set.Keys[1].Kty)
+	require.Equal(t, "Ed25519", set.Keys[1].Crv)
+
+	publicKey := func(t *testing.T, jwk JWK) interface{} {
+		enc := base64.RawURLEncoding
+		switch jwk.Kty {
+		case "RSA":
+			n, err := enc.DecodeString(jwk.N)
+			require.NoError(t, err)
+			e, err := enc.DecodeString(jwk.E)
+			require.NoError(t, err)
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		default:
+			x, err := enc.DecodeString(jwk.X)
+			require.NoError(t, err)
+			return ed25519.PublicKey(x)
+		}
+	}
+
+	token, _, err := maker.CreateToken(7, RoleUser, ScopesForRole(RoleUser), time.Minute)
+	require.NoError(t, err)
+
+	keyFunc := func(tok *jwt.Token) (interface{}, error) {
+		for _, jwk := range set.Keys {
+			if jwk.Kid == tok.Header["kid"] {
+				return publicKey(t, jwk), nil
+			}
+		}
+		return nil, ErrUnknownKey
+	}
+	// standard claims are all a generic verifier looks at
+	parsed, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, keyFunc)
+	require.NoError(t, err)
+	claims := parsed.Claims.(*jwt.StandardClaims)
+	require.Equal(t, "7", claims.Subject)
+	require.NotZero(t, claims.IssuedAt)
+	require.NotZero(t, claims.NotBefore)
+	require.WithinDuration(t, time.Now().Add(time.Minute), time.Unix(claims.ExpiresAt, 0), 2*time.Second)
+
+	// and a generic verifier rejects an expired token on exp alone
+	expired, _, err := maker.CreateToken(7, RoleUser, ScopesForRole(RoleUser), -time.Minute)
+	require.NoError(t, err)
+	_, err = jwt.ParseWithClaims(expired, &jwt.StandardClaims{}, keyFunc)
+	var verr *jwt.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.NotZero(t, verr.Errors&jwt.ValidationErrorExpired)
+}
+
+func TestJWTPublicRejects(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKeyFile(t, dir, "2024-01-01", 2048)
+	maker := newJWTPublicMaker(t, dir)
+	payload, err := CreatePayload(1, RoleAdmin, ScopesForRole(RoleAdmin), time.Minute)
+	require.NoError(t, err)
+
+	// HS256 keyed with the public key must not pass as RS256
+	public := maker.JWKS().Keys[0]
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	forged.Header["kid"] = public.Kid
+	token, err := forged.SignedString([]byte(public.N))
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, payload)
+	unknown.Header["kid"] = "other"
+	token, err = unknown.SignedString(maker.signing.key.Private)
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrUnknownKey.Error())
+
+	// weak RSA keys are refused outright
+	weakDir := t.TempDir()
+	writeRSAKeyFile(t, weakDir, "weak", 1024)
+	keys, err := LoadKeyDir(weakDir)
+	require.NoError(t, err)
+	_, err = NewJWTPublicMaker(keys)
+	require.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const keyFileExt = ".pem"
+
+var ErrUnknownKey = errors.New("token signed with unknown key")
+
+// Key is a private key loaded from a key directory, ID travels with every
+// token it signs so verifiers can pick the matching public key.
+type Key struct {
+	ID      string
+	Private crypto.Signer
+}
+
+// KeySet holds every active key of a key directory. Tokens are signed with
+// Signing and verified with any key of Keys.
+type KeySet struct {
+	Signing Key
+	Keys    []Key
+}
+
+// LoadKeyDir reads every <kid>.pem file in dir as a PKCS#8 private key. The
+// key with the greatest id signs new tokens, so ids should sort by creation
+// (e.g. 2024-06-01). Rotating is adding a newer key; once tokens signed with
+// the old one have expired its file is retired by renaming or removing it,
+// anything without the .pem extension is ignored.
+func LoadKeyDir(dir string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key dir: %w", err)
+	}
+
+	var keys []Key
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != keyFileExt {
+			continue
+		}
+		key, err := readKeyFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, Key{ID: strings.TrimSuffix(name, keyFileExt), Private: key})
+	}
+	return NewKeySet(keys)
+}
+
+func NewKeySet(keys []Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no active signing keys")
+	}
+	sorted := append([]Key(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].ID == sorted[i-1].ID {
+			return nil, fmt.Errorf("duplicate key id %q", sorted[i].ID)
+		}
+	}
+	return &KeySet{
+		Signing: sorted[len(sorted)-1],
+		Keys:    sorted,
+	}, nil
+}
+
+func (set *KeySet) Find(id string) (Key, bool) {
+	for _, key := range set.Keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+func readKeyFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+	return signer, nil
+}
+
+// GenerateKeyFile writes a new Ed25519 key named id into dir.
+func GenerateKeyFile(dir, id string) error {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(filepath.Join(dir, id+keyFileExt), data, 0600)
+}
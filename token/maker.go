@@ -3,6 +3,6 @@ package token
 import "time"
 
 type Maker interface {
-	CreateToken(id int64, duration time.Duration) (string, *Payload, error)
+	CreateToken(id int64, role string, scopes []string, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
 }
@@ -0,0 +1,45 @@
+package token
+
+// Option configures the registered claims a Maker stamps on every token and
+// insists on when verifying.
+type Option func(*claimRules)
+
+type claimRules struct {
+	issuer   string
+	audience string
+}
+
+// WithIssuer sets the iss claim, tokens from any other issuer are rejected.
+func WithIssuer(issuer string) Option {
+	return func(r *claimRules) {
+		r.issuer = issuer
+	}
+}
+
+// WithAudience sets the aud claim, tokens meant for any other audience are
+// rejected.
+func WithAudience(audience string) Option {
+	return func(r *claimRules) {
+		r.audience = audience
+	}
+}
+
+func newClaimRules(opts []Option) claimRules {
+	var rules claimRules
+	for _, opt := range opts {
+		opt(&rules)
+	}
+	return rules
+}
+
+func (r claimRules) stamp(payload *Payload) {
+	payload.Issuer = r.issuer
+	payload.Audience = r.audience
+}
+
+func (r claimRules) check(payload *Payload) error {
+	if payload.Issuer != r.issuer || payload.Audience != r.audience {
+		return ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimRules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+	keys, err := LoadKeyDir(dir)
+	require.NoError(t, err)
+	secret := util.RandomString(32)
+
+	makers := map[string]func(opts ...Option) (Maker, error){
+		"paseto-local": func(opts ...Option) (Maker, error) {
+			return NewPasetoMaker(secret, opts...)
+		},
+		"paseto-public": func(opts ...Option) (Maker, error) {
+			return NewPasetoPublicMaker(keys, opts...)
+		},
+		"jwt": func(opts ...Option) (Maker, error) {
+			return NewJWT(secret, opts...)
+		},
+		"jwt-public": func(opts ...Option) (Maker, error) {
+			return NewJWTPublicMaker(keys, opts...)
+		},
+	}
+	for name, newMaker := range makers {
+		newMaker := newMaker
+
+		t.Run(name, func(t *testing.T) {
+			maker, err := newMaker(WithIssuer("https://chat.example.com"), WithAudience("gateway"))
+			require.NoError(t, err)
+
+			token, payload, err := maker.CreateToken(1, RoleUser, ScopesForRole(RoleUser), time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, "https://chat.example.com", payload.Issuer)
+			require.Equal(t, "gateway", payload.Audience)
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "gateway", payload.Audience)
+
+			// same keys, different expectations
+			for _, opts := range [][]Option{
+				{WithIssuer("https://other.example.com"), WithAudience("gateway")},
+				{WithIssuer("https://chat.example.com"), WithAudience("billing")},
+				nil,
+			} {
+				other, err := newMaker(opts...)
+				require.NoError(t, err)
+				_, err = other.VerifyToken(token)
+				require.EqualError(t, err, ErrInvalidToken.Error())
+			}
+		})
+	}
+}
@@ -10,9 +10,10 @@ import (
 
 type PasetoMaker struct {
 	symmetricKey paseto.V4SymmetricKey
+	rules        claimRules
 }
 
-func NewPasetoMaker(symmetricKey string) (Maker, error) {
+func NewPasetoMaker(symmetricKey string, opts ...Option) (Maker, error) {
 	if len(symmetricKey) < 32 {
 		return nil, fmt.Errorf("invalid key size: key must be exactly %d characters in length", 32)
 	}
@@ -25,14 +26,16 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 	maker := &PasetoMaker{
 		// paseto:       paseto.V4Local,
 		symmetricKey: pKey,
+		rules:        newClaimRules(opts),
 	}
 	return maker, nil
 }
-func (maker *PasetoMaker) CreateToken(id int64, duration time.Duration) (string, *Payload, error) {
-	payload, err := CreatePayload(id, duration)
+func (maker *PasetoMaker) CreateToken(id int64, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	payload, err := CreatePayload(id, role, scopes, duration)
 	if err != nil {
 		return "", payload, err
 	}
+	maker.rules.stamp(payload)
 
 	marshalled, err := json.Marshal(payload)
 	if err != nil {
@@ -61,19 +64,27 @@ func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
 
 	payload, err := parser.ParseV4Local(maker.symmetricKey, token, nil)
 	if err != nil {
-		switch err.Error() {
-		case "the ValidAt time is after this token expires":
-			return nil, ErrorExpiredToken
-		}
-		return nil, ErrInvalidToken
+		return nil, pasetoError(err)
 	}
 
 	var unmarshalled *Payload
 	if err := json.Unmarshal(payload.ClaimsJSON(), &unmarshalled); err != nil {
 		return nil, err
 	}
+	if err := maker.rules.check(unmarshalled); err != nil {
+		return nil, err
+	}
 
 	// fmt.Printf("payload in verify:%+v\n", unmarshalled)
 	return unmarshalled, nil
 
 }
+
+// pasetoError maps parser failures onto the errors every Maker returns.
+func pasetoError(err error) error {
+	switch err.Error() {
+	case "the ValidAt time is after this token expires":
+		return ErrorExpiredToken
+	}
+	return ErrInvalidToken
+}
@@ -18,7 +18,8 @@ func TestPasetoMaker(t *testing.T) {
 	issuedAt := time.Now()
 	expires := time.Now().Add(duration)
 
-	token, payload, err := maker.CreateToken(id, duration)
+	scopes := ScopesForRole(RoleAdmin)
+	token, payload, err := maker.CreateToken(id, RoleAdmin, scopes, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -29,6 +30,9 @@ func TestPasetoMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, id, payload.User)
+	require.Equal(t, RoleAdmin, payload.Role)
+	require.Equal(t, scopes, payload.Scopes)
+	require.True(t, payload.HasScope(ScopeAdmin))
 	require.WithinDuration(t, issuedAt, payload.CreatedAt, time.Second)
 	require.WithinDuration(t, expires, payload.Expires, time.Second)
 }
@@ -36,7 +40,7 @@ func TestPasetoExpired(t *testing.T) {
 	maker, err := NewPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	token, payload, err := maker.CreateToken(util.RandomInt(0, 1000), -time.Hour)
+	token, payload, err := maker.CreateToken(util.RandomInt(0, 1000), RoleUser, ScopesForRole(RoleUser), -time.Hour)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
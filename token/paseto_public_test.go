@@ -0,0 +1,131 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newPublicMaker(t *testing.T, dir string) Maker {
+	keys, err := LoadKeyDir(dir)
+	require.NoError(t, err)
+	maker, err := NewPasetoPublicMaker(keys)
+	require.NoError(t, err)
+	return maker
+}
+
+func TestPasetoPublicMaker(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+	maker := newPublicMaker(t, dir)
+
+	id := util.RandomInt(1, 1000)
+	duration := time.Minute
+
+	issuedAt := time.Now()
+	expires := time.Now().Add(duration)
+
+	token, payload, err := maker.CreateToken(id, RoleUser, ScopesForRole(RoleUser), duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotZero(t, payload.ID)
+	require.Equal(t, id, payload.User)
+	require.Equal(t, RoleUser, payload.Role)
+	require.WithinDuration(t, issuedAt, payload.CreatedAt, time.Second)
+	require.WithinDuration(t, expires, payload.Expires, time.Second)
+}
+
+func TestPasetoPublicExpired(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+	maker := newPublicMaker(t, dir)
+
+	token, _, err := maker.CreateToken(util.RandomInt(1, 1000), RoleUser, ScopesForRole(RoleUser), -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.EqualError(t, err, ErrorExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestPasetoPublicRotation(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+	oldMaker := newPublicMaker(t, dir)
+	oldToken, _, err := oldMaker.CreateToken(1, RoleUser, ScopesForRole(RoleUser), time.Minute)
+	require.NoError(t, err)
+
+	// a newer key takes over signing, tokens from the old one stay valid
+	require.NoError(t, GenerateKeyFile(dir, "2024-02-01"))
+	maker := newPublicMaker(t, dir)
+	_, err = maker.VerifyToken(oldToken)
+	require.NoError(t, err)
+
+	newToken, _, err := maker.CreateToken(1, RoleUser, ScopesForRole(RoleUser), time.Minute)
+	require.NoError(t, err)
+	_, err = oldMaker.VerifyToken(newToken)
+	require.EqualError(t, err, ErrUnknownKey.Error())
+
+	// retiring the old key rejects what it signed
+	require.NoError(t, os.Rename(filepath.Join(dir, "2024-01-01.pem"), filepath.Join(dir, "2024-01-01.pem.retired")))
+	maker = newPublicMaker(t, dir)
+	_, err = maker.VerifyToken(oldToken)
+	require.EqualError(t, err, ErrUnknownKey.Error())
+	_, err = maker.VerifyToken(newToken)
+	require.NoError(t, err)
+}
+
+func TestPasetoPublicWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(dir, "2024-01-01"))
+	maker := newPublicMaker(t, dir)
+
+	// same key id, different key material
+	otherDir := t.TempDir()
+	require.NoError(t, GenerateKeyFile(otherDir, "2024-01-01"))
+	forged, _, err := newPublicMaker(t, otherDir).CreateToken(1, RoleAdmin, ScopesForRole(RoleAdmin), time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(forged)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	// local tokens are not accepted either
+	local, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	token, _, err := local.CreateToken(1, RoleUser, ScopesForRole(RoleUser), time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	_, err := LoadKeyDir(t.TempDir())
+	require.Error(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a key"), 0600))
+	_, err = LoadKeyDir(dir)
+	require.Error(t, err)
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys, err := NewKeySet([]Key{{ID: "b", Private: private}, {ID: "a", Private: private}})
+	require.NoError(t, err)
+	require.Equal(t, "b", keys.Signing.ID)
+	_, ok := keys.Find("a")
+	require.True(t, ok)
+
+	_, err = NewKeySet([]Key{{ID: "a", Private: private}, {ID: "a", Private: private}})
+	require.Error(t, err)
+}
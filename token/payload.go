@@ -12,14 +12,42 @@ var (
 	ErrInvalidToken   = errors.New("token is invalid")
 )
 
+// roles match the values allowed in the Users.role column
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// ScopesForRole is what a login grants, narrower tokens such as read-only
+// integrations pass a subset of it to CreateToken.
+func ScopesForRole(role string) []string {
+	switch role {
+	case RoleAdmin:
+		return []string{ScopeRead, ScopeWrite, ScopeAdmin}
+	case RoleUser:
+		return []string{ScopeRead, ScopeWrite}
+	}
+	return nil
+}
+
 type Payload struct {
 	ID        uuid.UUID `json:"uuid"`
 	User      int64     `json:"user_id"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes"`
+	Issuer    string    `json:"iss,omitempty"`
+	Audience  string    `json:"aud,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	Expires   time.Time `json:"expires"`
 }
 
-func CreatePayload(id int64, duration time.Duration) (*Payload, error) {
+func CreatePayload(id int64, role string, scopes []string, duration time.Duration) (*Payload, error) {
 	ID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -27,12 +55,23 @@ func CreatePayload(id int64, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		ID:        ID,
 		User:      id,
+		Role:      role,
+		Scopes:    scopes,
 		CreatedAt: time.Now(),
 		Expires:   time.Now().Add(duration),
 	}
 	return payload, nil
 }
 
+func (p *Payload) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Payload) Valid() error {
 	if time.Now().After(p.Expires) {
 		return ErrorExpiredToken
@@ -0,0 +1,99 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+var ErrRevokedToken = errors.New("token has been revoked")
+
+// Revoker tracks tokens that have to be rejected before they expire.
+type Revoker interface {
+	// Revoke rejects the single token described by payload.
+	Revoke(ctx context.Context, payload *Payload) error
+	// RevokeUser rejects every token issued to user before the given time.
+	RevokeUser(ctx context.Context, user int64, before time.Time) error
+	IsRevoked(ctx context.Context, payload *Payload) (bool, error)
+}
+
+type revokedEntry struct {
+	revoked bool
+	checked time.Time
+}
+
+type cachedRevoker struct {
+	backend Revoker
+	ttl     time.Duration
+	tokens  *lru.Cache[uuid.UUID, revokedEntry]
+	cutoffs *lru.Cache[int64, time.Time]
+}
+
+// NewCachedRevoker keeps recent answers of a backend Revoker in memory so most
+// requests do not need a round trip. Revocations never become valid again and
+// are cached until evicted, while negative answers are only trusted for ttl,
+// which bounds how long a revocation made by another instance goes unnoticed.
+// With a nil backend it is a purely in-memory denylist.
+func NewCachedRevoker(backend Revoker, size int, ttl time.Duration) (Revoker, error) {
+	tokens, err := lru.New[uuid.UUID, revokedEntry](size)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token cache: %w", err)
+	}
+	cutoffs, err := lru.New[int64, time.Time](size)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create user cache: %w", err)
+	}
+	return &cachedRevoker{
+		backend: backend,
+		ttl:     ttl,
+		tokens:  tokens,
+		cutoffs: cutoffs,
+	}, nil
+}
+
+func (r *cachedRevoker) Revoke(ctx context.Context, payload *Payload) error {
+	if r.backend != nil {
+		if err := r.backend.Revoke(ctx, payload); err != nil {
+			return err
+		}
+	}
+	r.tokens.Add(payload.ID, revokedEntry{revoked: true, checked: time.Now()})
+	return nil
+}
+
+func (r *cachedRevoker) RevokeUser(ctx context.Context, user int64, before time.Time) error {
+	if r.backend != nil {
+		if err := r.backend.RevokeUser(ctx, user, before); err != nil {
+			return err
+		}
+	}
+	if cutoff, ok := r.cutoffs.Get(user); ok && cutoff.After(before) {
+		return nil
+	}
+	r.cutoffs.Add(user, before)
+	return nil
+}
+
+func (r *cachedRevoker) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	if cutoff, ok := r.cutoffs.Get(payload.User); ok && payload.CreatedAt.Before(cutoff) {
+		return true, nil
+	}
+	entry, ok := r.tokens.Get(payload.ID)
+	if ok && (entry.revoked || r.backend == nil || time.Since(entry.checked) < r.ttl) {
+		return entry.revoked, nil
+	}
+	if r.backend == nil {
+		return false, nil
+	}
+
+	revoked, err := r.backend.IsRevoked(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+	r.tokens.Add(payload.ID, revokedEntry{revoked: revoked, checked: time.Now()})
+	return revoked, nil
+}
@@ -0,0 +1,120 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRevoker is an in-memory backend that records how often it is asked.
+type countingRevoker struct {
+	revoked map[uuid.UUID]bool
+	cutoffs map[int64]time.Time
+	checks  int
+}
+
+func newCountingRevoker() *countingRevoker {
+	return &countingRevoker{
+		revoked: map[uuid.UUID]bool{},
+		cutoffs: map[int64]time.Time{},
+	}
+}
+
+func (r *countingRevoker) Revoke(_ context.Context, payload *Payload) error {
+	r.revoked[payload.ID] = true
+	return nil
+}
+
+func (r *countingRevoker) RevokeUser(_ context.Context, user int64, before time.Time) error {
+	r.cutoffs[user] = before
+	return nil
+}
+
+func (r *countingRevoker) IsRevoked(_ context.Context, payload *Payload) (bool, error) {
+	r.checks++
+	return r.revoked[payload.ID] || payload.CreatedAt.Before(r.cutoffs[payload.User]), nil
+}
+
+func randomPayload(t *testing.T) *Payload {
+	payload, err := CreatePayload(util.RandomInt(1, 1000), RoleUser, ScopesForRole(RoleUser), time.Minute)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestCachedRevoker(t *testing.T) {
+	backend := newCountingRevoker()
+	revoker, err := NewCachedRevoker(backend, 16, time.Hour)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	payload := randomPayload(t)
+	revoked, err := revoker.IsRevoked(ctx, payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	// the negative answer is served from the cache
+	revoked, err = revoker.IsRevoked(ctx, payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+	require.Equal(t, 1, backend.checks)
+
+	require.NoError(t, revoker.Revoke(ctx, payload))
+	require.True(t, backend.revoked[payload.ID])
+
+	revoked, err = revoker.IsRevoked(ctx, payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 1, backend.checks)
+}
+
+func TestCachedRevokerExpiredNegative(t *testing.T) {
+	backend := newCountingRevoker()
+	revoker, err := NewCachedRevoker(backend, 16, -time.Second)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	payload := randomPayload(t)
+	revoked, err := revoker.IsRevoked(ctx, payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	// revoked by another instance, the stale negative must not be trusted
+	backend.revoked[payload.ID] = true
+	revoked, err = revoker.IsRevoked(ctx, payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 2, backend.checks)
+}
+
+func TestCachedRevokerUser(t *testing.T) {
+	revoker, err := NewCachedRevoker(nil, 16, time.Minute)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	old := randomPayload(t)
+	other := randomPayload(t)
+	other.User = old.User + 1
+
+	require.NoError(t, revoker.RevokeUser(ctx, old.User, time.Now()))
+	// an earlier cutoff must not undo a later one
+	require.NoError(t, revoker.RevokeUser(ctx, old.User, time.Now().Add(-time.Hour)))
+
+	revoked, err := revoker.IsRevoked(ctx, old)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = revoker.IsRevoked(ctx, other)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	fresh := randomPayload(t)
+	fresh.User = old.User
+	fresh.CreatedAt = time.Now().Add(time.Second)
+	revoked, err = revoker.IsRevoked(ctx, fresh)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
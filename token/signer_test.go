@@ -0,0 +1,63 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjriverac/messaging-server/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	code, err := signer.Sign(PurposeVerifyEmail, "12:user@example.com", time.Minute)
+	require.NoError(t, err)
+
+	subject, err := signer.Verify(PurposeVerifyEmail, code)
+	require.NoError(t, err)
+	require.Equal(t, "12:user@example.com", subject)
+
+	// bound to its purpose
+	_, err = signer.Verify("reset-password", code)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestSignerExpired(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	code, err := signer.Sign(PurposeVerifyEmail, "12:user@example.com", -time.Minute)
+	require.NoError(t, err)
+
+	_, err = signer.Verify(PurposeVerifyEmail, code)
+	require.ErrorIs(t, err, ErrorExpiredToken)
+}
+
+func TestSignerTampered(t *testing.T) {
+	secret := util.RandomString(32)
+	signer, err := NewSigner(secret)
+	require.NoError(t, err)
+	other, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	code, err := signer.Sign(PurposeVerifyEmail, "12:user@example.com", time.Minute)
+	require.NoError(t, err)
+
+	_, err = other.Verify(PurposeVerifyEmail, code)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	forged, err := other.Sign(PurposeVerifyEmail, "13:user@example.com", time.Minute)
+	require.NoError(t, err)
+	_, err = signer.Verify(PurposeVerifyEmail, forged)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	for _, bad := range []string{"", "abc", code + "x", "." + code} {
+		_, err = signer.Verify(PurposeVerifyEmail, bad)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	}
+
+	_, err = NewSigner(util.RandomString(31))
+	require.Error(t, err)
+}
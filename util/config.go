@@ -1,6 +1,8 @@
 package util
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,9 +12,35 @@ type Config struct {
 	DBDriver             string        `mapstructure:"DB_DRIVER"`
 	DBSource             string        `mapstructure:"DB_SOURCE"`
 	ServerAddr           string        `mapstructure:"SERVER_ADDRESS"`
+	TokenType            string        `mapstructure:"TOKEN_TYPE"`
 	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenKeyDir          string        `mapstructure:"TOKEN_KEY_DIR"`
+	TokenIssuer          string        `mapstructure:"TOKEN_ISSUER"`
+	TokenAudience        string        `mapstructure:"TOKEN_AUDIENCE"`
 	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	AppURL               string        `mapstructure:"APP_URL"`
+	TrustedProxies       []string      `mapstructure:"TRUSTED_PROXIES"`
+	Mailer               string        `mapstructure:"MAILER"`
+	MailFrom             string        `mapstructure:"MAIL_FROM"`
+	MailLogFile          string        `mapstructure:"MAIL_LOG_FILE"`
+	SMTPAddr             string        `mapstructure:"SMTP_ADDRESS"`
+	SMTPUsername         string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword         string        `mapstructure:"SMTP_PASSWORD"`
+	OIDCProviderNames    []string      `mapstructure:"OIDC_PROVIDERS"`
+
+	// OIDCProviders is built from OIDCProviderNames by LoadConfig.
+	OIDCProviders []OIDCProvider `mapstructure:"-"`
+}
+
+// OIDCProvider is an OpenID Connect identity provider users can sign in
+// with. Every name listed in OIDC_PROVIDERS is read from OIDC_<NAME>_ISSUER,
+// OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET.
+type OIDCProvider struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -27,5 +55,31 @@ func LoadConfig(path string) (config Config, err error) {
 		return
 	}
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+	config.OIDCProviders, err = loadOIDCProviders(config.OIDCProviderNames)
 	return
 }
+
+func loadOIDCProviders(names []string) ([]OIDCProvider, error) {
+	var providers []OIDCProvider
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			IssuerURL:    viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+		}
+		if provider.IssuerURL == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %q needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOIDCProviders(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "Google, corp")
+	t.Setenv("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("OIDC_GOOGLE_CLIENT_SECRET", "google-secret")
+	t.Setenv("OIDC_CORP_ISSUER", "https://sso.example.com")
+	t.Setenv("OIDC_CORP_CLIENT_ID", "corp-client")
+
+	config, err := LoadConfig("..")
+	require.NoError(t, err)
+	require.Equal(t, []OIDCProvider{
+		{Name: "google", IssuerURL: "https://accounts.google.com", ClientID: "google-client", ClientSecret: "google-secret"},
+		{Name: "corp", IssuerURL: "https://sso.example.com", ClientID: "corp-client"},
+	}, config.OIDCProviders)
+
+	// a provider without an issuer cannot be used, fail at startup instead
+	t.Setenv("OIDC_CORP_ISSUER", "")
+	_, err = LoadConfig("..")
+	require.Error(t, err)
+}
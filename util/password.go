@@ -1,7 +1,10 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,4 +19,41 @@ func HashPassword (password string) (string, error){
 
 func CheckPassword (password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword),[]byte(password))
-}
\ No newline at end of file
+}
+
+const (
+	minPasswordLen = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordBytes = 72
+)
+
+var (
+	ErrPasswordTooShort  = fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	ErrPasswordTooLong   = fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	ErrPasswordTooSimple = errors.New("password must mix letters with digits or symbols")
+)
+
+// ValidatePassword enforces the strength rules for new passwords. Existing
+// passwords are never checked against it, so tightening the rules does not
+// lock anyone out.
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLen {
+		return ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+	var letter, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case !unicode.IsSpace(r):
+			other = true
+		}
+	}
+	if !letter || !other {
+		return ErrPasswordTooSimple
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -26,3 +27,22 @@ func TestPassword(t *testing.T) {
 	require.NotEmpty(t, hashedPw2)
 	require.NotEqual(t, hashedPw1, hashedPw2)
 }
+
+func TestValidatePassword(t *testing.T) {
+	testCases := []struct {
+		password string
+		err      error
+	}{
+		{RandomPassword(), nil},
+		{"correct horse 1", nil},
+		{"pässwörd!", nil},
+		{"abc12", ErrPasswordTooShort},
+		{"abcdefghijkl", ErrPasswordTooSimple},
+		{"1234567890", ErrPasswordTooSimple},
+		{"abcd    efgh", ErrPasswordTooSimple},
+		{strings.Repeat("a1", 37), ErrPasswordTooLong},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.err, ValidatePassword(tc.password), tc.password)
+	}
+}
@@ -72,6 +72,11 @@ func RandomHashedPW() string {
 	return str.String()
 }
 
+// RandomPassword always passes ValidatePassword.
+func RandomPassword() string {
+	return RandomString(8) + strconv.Itoa(rand.Intn(900)+100)
+}
+
 func NullStrGen(n int) sql.NullString {
 	var str sql.NullString
 	str.String = RandomString(n)